@@ -92,7 +92,7 @@ func main() {
 
 	// Listen for SIGINT and SIGTERM signals
 	quit := make(chan os.Signal, 1)
-	dEvent := devents.NewDEvents(context.TODO(), dbClient)
+	dEvent := devents.NewDEvents(context.TODO(), dbClient, dCache)
 	exp := explorer.NewExplorer(rpcClient, dbClient, &cfg, dCache, dEvent, quit)
 	go exp.Scan()
 	go exp.Index()
@@ -103,6 +103,9 @@ func main() {
 
 	// notify service stopped
 	exp.Stop()
+	if err = dbClient.Close(); err != nil {
+		xylog.Logger.Errorf("close db client err:%v", err)
+	}
 	xylog.Logger.Infof("service stopped")
 }
 
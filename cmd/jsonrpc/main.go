@@ -56,6 +56,12 @@ func main() {
 		log.Fatalf("initialize db client err:%v", err)
 		return
 	}
+	defer func() {
+		if err := dbc.Close(); err != nil {
+			log.Printf("close db client err:%v", err)
+		}
+	}()
+
 	//init server
 	server, err := jsonrpc.NewRPCServer(dbc, cfg.CacheStore)
 	if err != nil {
@@ -55,9 +55,35 @@ type IndexFilter struct {
 
 // DatabaseConfig database config
 type DatabaseConfig struct {
-	Type      string `json:"type"`
-	Dsn       string `json:"dsn"`
-	EnableLog bool   `json:"enable_log"`
+	Type            string   `json:"type"`
+	Dsn             string   `json:"dsn"`
+	EnableLog       bool     `json:"enable_log"`        // log every query
+	SlowThresholdMs uint32   `json:"slow_threshold_ms"` // queries slower than it are logged at warn level
+	EnableMetrics   bool     `json:"enable_metrics"`    // record the storage query metrics
+	SslMode         string   `json:"ssl_mode"`          // postgres only, disable / require / verify-ca / verify-full
+	Replicas        []string `json:"replicas"`          // read replica dsn list, queries are routed to the replicas
+	QueryTimeoutMs  uint32   `json:"query_timeout_ms"`  // server side statement timeout set on every connection, 0 disables it
+	BatchSize       int      `json:"batch_size"`        // rows of a single batch INSERT, 0 falls back to the storage default
+	MaxStatementKB  int      `json:"max_statement_kb"`  // size limit of a single batch UPDATE, 0 falls back to the storage default
+	TablePrefix     string   `json:"table_prefix"`      // prepended to all the table names, for several indexers in one database
+	PrepareStmt     bool     `json:"prepare_stmt"`      // cache the prepared statements of the queries, see the storage package
+
+	ValidateBalanceTxs bool `json:"validate_balance_txs"` // reject the balance txs without address or tick before inserting them
+
+	// client side deadlines of the storage read methods called without a context, 0 disables them
+	FastQueryTimeoutMs       uint32 `json:"fast_query_timeout_ms"`       // point lookups and index backed listings
+	AnalyticalQueryTimeoutMs uint32 `json:"analytical_query_timeout_ms"` // aggregations such as the rich list
+
+	// sqlite only, the pragmas set on every new connection, empty values fall back to the defaults of the storage package
+	SqliteJournalMode   string `json:"sqlite_journal_mode"`    // WAL by default, readers do not block the writer
+	SqliteSynchronous   string `json:"sqlite_synchronous"`     // NORMAL by default, safe with WAL
+	SqliteBusyTimeoutMs uint32 `json:"sqlite_busy_timeout_ms"` // wait for the lock of another connection, query_timeout_ms when 0
+
+	// connection pool, zero values fall back to the defaults of the storage package
+	MaxOpenConns    int    `json:"max_open_conns"`
+	MaxIdleConns    int    `json:"max_idle_conns"`
+	ConnMaxLifetime uint32 `json:"conn_max_lifetime"`  // seconds
+	ConnMaxIdleTime uint32 `json:"conn_max_idle_time"` // seconds
 }
 
 type ProfileConfig struct {
@@ -21,9 +21,12 @@ CREATE TABLE `inscriptions`
     `transfer_type`  tinyint(1)                                                    NOT NULL, -- transfer type
     `created_at`     timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at`     timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `deleted_at`     timestamp                                                     NULL     DEFAULT NULL, -- soft deleted time
+    `extra`          json                                                          NULL     DEFAULT NULL, -- protocol specific deploy metadata
     PRIMARY KEY (`id`),
     UNIQUE KEY `uq_chain_protocol_name` (`chain`, `protocol`, `tick`),
-    UNIQUE KEY `uq_chain_sid` (`chain`, `sid`)
+    UNIQUE KEY `uq_chain_sid` (`chain`, `sid`),
+    KEY `idx_inscriptions_deleted_at` (`deleted_at`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_general_ci;
@@ -73,7 +76,10 @@ CREATE TABLE `txs`
     `created_at`        timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at`        timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
-    KEY `idx_tx_hash_chain` (`tx_hash`(12), `chain`(4))
+    UNIQUE KEY `uq_txs_chain_tx_hash` (`chain`, `tx_hash`),
+    KEY `idx_txs_tx_hash` (`tx_hash`, `chain`, `protocol`, `tick`),
+    KEY `idx_txs_chain_block` (`chain`, `block_height`, `position_in_block`),
+    KEY `idx_txs_chain_block_time` (`chain`, `block_time`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_general_ci;
@@ -89,11 +95,14 @@ CREATE TABLE `balances`
     `tick`       varchar(32) COLLATE utf8mb4_0900_bin    NOT NULL COMMENT 'inscription code',
     `available`  DECIMAL(38, 18)                         NOT NULL COMMENT 'available',
     `balance`    DECIMAL(38, 18)                         NOT NULL COMMENT 'balance',
+    `version`    int unsigned                            NOT NULL DEFAULT '0' COMMENT 'optimistic lock version',
     `created_at` timestamp                               NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at` timestamp                               NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
     UNIQUE KEY `address` (`address`, `chain`, `protocol`, `tick`),
-    UNIQUE KEY `uqx_chain_sid` (`chain`, `sid`)
+    UNIQUE KEY `uqx_chain_sid` (`chain`, `sid`),
+    KEY `idx_balances_chain_updated_at` (`chain`, `updated_at`),
+    KEY `idx_balances_tick_balance` (`chain`, `protocol`, `tick`, `balance`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_general_ci;
@@ -113,8 +122,8 @@ CREATE TABLE `address_txs`
     `created_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
-    KEY `idx_tx_hash` (`tx_hash`(12)),
-    KEY `idx_address` (`address`(12))
+    KEY `idx_address_txs_tx_hash` (`tx_hash`, `chain`),
+    KEY `idx_address_txs_address` (`address`, `chain`, `protocol`, `tick`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_general_ci;
@@ -132,6 +141,7 @@ CREATE TABLE `balance_txn`
     `available`  DECIMAL(38, 18)                                               NOT NULL COMMENT 'available',
     `balance`    DECIMAL(38, 18)                                               NOT NULL,
     `tx_hash`    varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+    `block_number` bigint unsigned                                             NOT NULL DEFAULT '0' COMMENT 'height of the tx',
     `created_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
@@ -153,11 +163,28 @@ CREATE TABLE `utxos`
     `amount`     DECIMAL(38, 18)                                               NOT NULL,
     `root_hash`  varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
     `tx_hash`    varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+    `vout`       int unsigned                                                  NOT NULL DEFAULT '0' COMMENT 'output index in the tx',
     `status`     tinyint(1)                                                    NOT NULL COMMENT 'tx status',
     `created_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
     `updated_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
-    KEY `idx_address` (`address`)
+    UNIQUE KEY `uq_utxos_chain_tx_vout` (`chain`, `tx_hash`, `vout`),
+    KEY `idx_utxos_address` (`address`, `chain`, `protocol`, `tick`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE `address_labels`
+(
+    `id`         bigint unsigned                                               NOT NULL AUTO_INCREMENT,
+    `chain`      varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci  NOT NULL,
+    `address`    varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+    `label`      varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'display name',
+    `category`   varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci  NOT NULL COMMENT 'exchange / burn / deployer',
+    `created_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_address_labels_chain_address` (`chain`, `address`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_general_ci;
@@ -168,9 +195,21 @@ CREATE TABLE `block`
     `block_hash`   varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
     `block_number` bigint                                                        NOT NULL,
     `block_time`   timestamp                                                     NOT NULL,
+    `indexed_at`   timestamp                                                     NULL     DEFAULT NULL COMMENT 'when the block was stored',
     `updated_at`   timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`chain`) USING BTREE,
     UNIQUE KEY `uqx_chain` (`chain`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
-  COLLATE = utf8mb4_general_ci;
\ No newline at end of file
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE `schema_version`
+(
+    `version`    int unsigned NOT NULL,
+    `applied_at` timestamp    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`version`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+INSERT INTO `schema_version` (`version`) VALUES (13);
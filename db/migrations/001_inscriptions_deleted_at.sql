@@ -0,0 +1,7 @@
+-- soft delete (tombstone) column of inscriptions ---------
+-- nullable column with a NULL default, mysql adds it in place without rewriting the existing rows
+ALTER TABLE `inscriptions`
+    ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL,
+    ADD KEY `idx_inscriptions_deleted_at` (`deleted_at`),
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
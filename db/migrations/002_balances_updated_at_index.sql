@@ -0,0 +1,5 @@
+-- recently updated balances of a chain, used by the incremental exports ---------
+ALTER TABLE `balances`
+    ADD KEY `idx_balances_chain_updated_at` (`chain`, `updated_at`),
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
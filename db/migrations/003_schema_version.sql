@@ -0,0 +1,11 @@
+-- schema versions applied by the storage migration runner ---------
+CREATE TABLE `schema_version`
+(
+    `version`    int unsigned NOT NULL,
+    `applied_at` timestamp    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`version`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+INSERT INTO `schema_version` (`version`) VALUES (3);
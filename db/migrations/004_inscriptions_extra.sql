@@ -0,0 +1,7 @@
+-- protocol specific deploy metadata of inscriptions ---------
+-- nullable column without a default, mysql adds it instantly without rewriting the existing rows
+ALTER TABLE `inscriptions`
+    ADD COLUMN `extra` json NULL DEFAULT NULL,
+    ALGORITHM = INSTANT;
+
+INSERT INTO `schema_version` (`version`) VALUES (4);
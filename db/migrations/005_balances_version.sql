@@ -0,0 +1,7 @@
+-- optimistic lock version of balances ---------
+-- BatchUpdateBalances only updates a balance whose version is unchanged since it was read and bumps it
+ALTER TABLE `balances`
+    ADD COLUMN `version` int unsigned NOT NULL DEFAULT '0' COMMENT 'optimistic lock version',
+    ALGORITHM = INSTANT;
+
+INSERT INTO `schema_version` (`version`) VALUES (5);
@@ -0,0 +1,7 @@
+-- txs of a chain by block position, used by the position lookup & the rollback ---------
+ALTER TABLE `txs`
+    ADD KEY `idx_txs_chain_block` (`chain`, `block_height`, `position_in_block`),
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+INSERT INTO `schema_version` (`version`) VALUES (6);
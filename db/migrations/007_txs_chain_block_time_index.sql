@@ -0,0 +1,7 @@
+-- txs of a chain by block time, used by the activity metrics ---------
+ALTER TABLE `txs`
+    ADD KEY `idx_txs_chain_block_time` (`chain`, `block_time`),
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+INSERT INTO `schema_version` (`version`) VALUES (7);
@@ -0,0 +1,16 @@
+-- outpoint (chain, tx_hash, vout) of utxos ---------
+-- the existing rows are numbered in the order of their id within their tx, the unique key is added once they are
+ALTER TABLE `utxos`
+    ADD COLUMN `vout` int unsigned NOT NULL DEFAULT '0' COMMENT 'output index in the tx' AFTER `tx_hash`;
+
+UPDATE `utxos` u
+    JOIN (SELECT a.`id`, COUNT(*) AS `vout`
+          FROM `utxos` a
+                   JOIN `utxos` b ON b.`chain` = a.`chain` AND b.`tx_hash` = a.`tx_hash` AND b.`id` < a.`id`
+          GROUP BY a.`id`) n ON n.`id` = u.`id`
+SET u.`vout` = n.`vout`;
+
+ALTER TABLE `utxos`
+    ADD UNIQUE KEY `uq_utxos_chain_tx_vout` (`chain`, `tx_hash`, `vout`);
+
+INSERT INTO `schema_version` (`version`) VALUES (8);
@@ -0,0 +1,31 @@
+-- composite indexes of the query paths, they replace the prefix indexes on the hashes & the addresses ---------
+-- txs by hash, used by the join of the address txs & the lookups by hash
+ALTER TABLE `txs`
+    ADD KEY `idx_txs_tx_hash` (`tx_hash`, `chain`, `protocol`, `tick`),
+    DROP KEY `idx_tx_hash_chain`,
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+-- holders of a tick by balance
+ALTER TABLE `balances`
+    ADD KEY `idx_balances_tick_balance` (`chain`, `protocol`, `tick`, `balance`),
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+-- txs of an address & address txs by hash, used by the rollback
+ALTER TABLE `address_txs`
+    ADD KEY `idx_address_txs_address` (`address`, `chain`, `protocol`, `tick`),
+    ADD KEY `idx_address_txs_tx_hash` (`tx_hash`, `chain`),
+    DROP KEY `idx_address`,
+    DROP KEY `idx_tx_hash`,
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+-- utxos of an address
+ALTER TABLE `utxos`
+    ADD KEY `idx_utxos_address` (`address`, `chain`, `protocol`, `tick`),
+    DROP KEY `idx_address`,
+    ALGORITHM = INPLACE,
+    LOCK = NONE;
+
+INSERT INTO `schema_version` (`version`) VALUES (9);
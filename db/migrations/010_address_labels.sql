@@ -0,0 +1,17 @@
+-- labels of the known addresses ---------
+CREATE TABLE `address_labels`
+(
+    `id`         bigint unsigned                                               NOT NULL AUTO_INCREMENT,
+    `chain`      varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci  NOT NULL,
+    `address`    varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+    `label`      varchar(128) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'display name',
+    `category`   varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci  NOT NULL COMMENT 'exchange / burn / deployer',
+    `created_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp                                                     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_address_labels_chain_address` (`chain`, `address`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+INSERT INTO `schema_version` (`version`) VALUES (10);
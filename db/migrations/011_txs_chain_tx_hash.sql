@@ -0,0 +1,10 @@
+-- unique tx hash of a chain ---------
+-- the duplicates stored by a reprocessed block have to be removed first, the row with the lowest id is kept
+DELETE t1
+FROM `txs` t1
+         JOIN `txs` t2 ON t1.`chain` = t2.`chain` AND t1.`tx_hash` = t2.`tx_hash` AND t1.`id` > t2.`id`;
+
+ALTER TABLE `txs`
+    ADD UNIQUE KEY `uq_txs_chain_tx_hash` (`chain`, `tx_hash`);
+
+INSERT INTO `schema_version` (`version`) VALUES (11);
@@ -0,0 +1,7 @@
+-- indexing time of the last block ---------
+-- set by SaveLastBlock & SaveLastBlockMonotonic, GetIndexingLag measures the lag of a chain from it
+ALTER TABLE `block`
+    ADD COLUMN `indexed_at` timestamp NULL DEFAULT NULL COMMENT 'when the block was stored' AFTER `block_time`,
+    ALGORITHM = INSTANT;
+
+INSERT INTO `schema_version` (`version`) VALUES (12);
@@ -0,0 +1,12 @@
+-- block of the balance changes ---------
+-- GetBalanceAtBlock filters on the height of the balance txs instead of joining their txs, the existing rows take the
+-- height of their tx
+ALTER TABLE `balance_txn`
+    ADD COLUMN `block_number` bigint unsigned NOT NULL DEFAULT '0' COMMENT 'height of the tx' AFTER `tx_hash`;
+
+UPDATE `balance_txn` b
+    JOIN `txs` t ON t.`chain` = b.`chain` AND t.`tx_hash` = b.`tx_hash`
+SET b.`block_number` = t.`block_height`
+WHERE b.`block_number` = 0;
+
+INSERT INTO `schema_version` (`version`) VALUES (13);
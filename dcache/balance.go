@@ -35,14 +35,16 @@ import (
  * Mainly used for real-time verification of data
  ****************************************************/
 type Balance struct {
-	sid   uint64
-	ticks *sync.Map
+	sid      uint64
+	ticks    *sync.Map
+	versions sync.RWMutex // guards the Version of the items, written by the db sink
 }
 
 type BalanceItem struct {
 	SID       uint64
 	Available decimal.Decimal
 	Overall   decimal.Decimal
+	Version   uint // version of the stored balance row, read & written through Balance.Version / SetVersion
 }
 
 func NewBalance() *Balance {
@@ -88,6 +90,7 @@ func (d *Balance) Create(protocol, tick string, addr string, b *BalanceItem) *Ba
 		SID:       b.SID,
 		Available: b.Available,
 		Overall:   b.Overall,
+		Version:   b.Version,
 	}
 
 	idx := d.idx(protocol, tick, addr)
@@ -115,3 +118,33 @@ func (d *Balance) Get(protocol, tick string, addr string) (ok bool, val *Balance
 	//addr = strings.ToLower(addr)
 	return true, balances.(*BalanceItem)
 }
+
+// Version
+/***************************************
+ * version of the stored balance row of addr tick
+ ***************************************/
+func (d *Balance) Version(protocol, tick string, addr string) (uint, bool) {
+	ok, balanceItem := d.Get(protocol, tick, addr)
+	if !ok {
+		return 0, false
+	}
+
+	d.versions.RLock()
+	defer d.versions.RUnlock()
+	return balanceItem.Version, true
+}
+
+// SetVersion
+/***************************************
+ * record the version of the balance row committed by the db sink
+ ***************************************/
+func (d *Balance) SetVersion(protocol, tick string, addr string, version uint) {
+	ok, balanceItem := d.Get(protocol, tick, addr)
+	if !ok {
+		return
+	}
+
+	d.versions.Lock()
+	defer d.versions.Unlock()
+	balanceItem.Version = version
+}
@@ -173,6 +173,7 @@ func (h *Manager) initBalanceCache(chain string) {
 				SID:       v.SID,
 				Available: v.Available,
 				Overall:   v.Balance,
+				Version:   v.Version,
 			})
 
 			if v.SID > maxSid {
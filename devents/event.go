@@ -24,6 +24,7 @@ package devents
 
 import (
 	"context"
+	"github.com/uxuycom/indexer/dcache"
 	"github.com/uxuycom/indexer/storage"
 	"github.com/uxuycom/indexer/xylog"
 	"gorm.io/gorm"
@@ -43,12 +44,16 @@ type DEvent struct {
 	ctx    context.Context
 	events chan *Event
 	db     *storage.DBClient
+
+	// the balance cache keeps the versions of the stored balance rows, the sink bumps them after every commit
+	cache *dcache.Manager
 }
 
-func NewDEvents(ctx context.Context, db *storage.DBClient) *DEvent {
+func NewDEvents(ctx context.Context, db *storage.DBClient, cache *dcache.Manager) *DEvent {
 	return &DEvent{
 		ctx:    ctx,
 		db:     db,
+		cache:  cache,
 		events: make(chan *Event, 1024),
 	}
 }
@@ -144,10 +149,14 @@ func (h *DEvent) Sink(db *storage.DBClient) bool {
 	err := db.SqlDB.Transaction(func(tx *gorm.DB) error {
 		// insert inscriptions
 		if items := dm.Inscriptions[DBActionCreate]; len(items) > 0 {
-			if err := db.BatchAddInscription(tx, items); err != nil {
+			skipped, err := db.BatchAddInscription(tx, items)
+			if err != nil {
 				xylog.Logger.Errorf("failed to save the inscription. err=%s", err)
 				return err
 			}
+			for _, item := range skipped {
+				xylog.Logger.Warnf("inscription already deployed & ignore. chain[%s] protocol[%s] tick[%s]", item.Chain, item.Protocol, item.Tick)
+			}
 		}
 
 		// update inscriptions
@@ -205,10 +214,14 @@ func (h *DEvent) Sink(db *storage.DBClient) bool {
 
 		// insert transactions
 		if len(dm.Txs) > 0 {
-			if err := db.BatchAddTransaction(tx, dm.Txs); err != nil {
+			inserted, err := db.BatchAddTransaction(tx, dm.Txs)
+			if err != nil {
 				xylog.Logger.Errorf("failed to create transactions. err=%s", err)
 				return err
 			}
+			if skipped := int64(len(dm.Txs)) - inserted; skipped > 0 {
+				xylog.Logger.Warnf("transactions already stored & ignore. chain[%s] skipped[%d]", chain, skipped)
+			}
 		}
 
 		// insert address transactions
@@ -235,8 +248,14 @@ func (h *DEvent) Sink(db *storage.DBClient) bool {
 			}
 		}
 
-		// update inscriptions
+		// update balances
 		if items := dm.Balances[DBActionUpdate]; len(items) > 0 {
+			// the events may be built before the previous flush committed, the version is read at flush time
+			for _, item := range items {
+				if version, ok := h.cache.Balance.Version(item.Protocol, item.Tick, item.Address); ok {
+					item.Version = version
+				}
+			}
 			err := db.BatchUpdateBalances(tx, chain, items)
 			if err != nil {
 				xylog.Logger.Errorf("failed update balances records. err=%s", err)
@@ -245,10 +264,14 @@ func (h *DEvent) Sink(db *storage.DBClient) bool {
 		}
 
 		// record block status
-		if err := db.SaveLastBlock(tx, dm.BlockStatus); err != nil {
+		applied, err := db.SaveLastBlockMonotonic(tx, dm.BlockStatus)
+		if err != nil {
 			xylog.Logger.Errorf("failed to save block information. err=%s", err)
 			return err
 		}
+		if !applied {
+			xylog.Logger.Warnf("stale block status & ignore. chain[%s] block[%d]", chain, dm.BlockStatus.BlockNumber)
+		}
 		return nil
 	})
 
@@ -256,6 +279,11 @@ func (h *DEvent) Sink(db *storage.DBClient) bool {
 		xylog.Logger.Errorf("flush db error. err=%s, cost:%v", err, time.Since(startTs))
 		return false
 	}
+
+	// the versions are only recorded once committed, BatchUpdateBalances bumped the ones of the updated items
+	for _, item := range dm.Balances[DBActionUpdate] {
+		h.cache.Balance.SetVersion(item.Protocol, item.Tick, item.Address, item.Version)
+	}
 	xylog.Logger.Infof("flush db success, cost:%v", time.Since(startTs))
 	return true
 }
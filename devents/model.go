@@ -62,8 +62,7 @@ func (tc *TxResultHandler) BuildInscription(e *TxResult) map[DBAction]*model.Ins
 	}
 
 	_, d := tc.cache.Inscription.Get(e.MD.Protocol, e.MD.Tick)
-	ret := make(map[DBAction]*model.Inscriptions, 1)
-	ret[DBActionCreate] = &model.Inscriptions{
+	ins := &model.Inscriptions{
 		SID:          d.SID,
 		Chain:        e.MD.Chain,
 		Protocol:     e.MD.Protocol,
@@ -76,6 +75,14 @@ func (tc *TxResultHandler) BuildInscription(e *TxResult) map[DBAction]*model.Ins
 		DeployTime:   time.Unix(int64(e.Block.Time), 0),
 		Decimals:     e.Deploy.Decimal,
 	}
+	for key, value := range e.Deploy.Extra {
+		if err := ins.SetExtraValue(key, value); err != nil {
+			xylog.Logger.Warnf("invalid deploy extra & ignore. tick[%s] key[%s] err[%v]", e.MD.Tick, key, err)
+		}
+	}
+
+	ret := make(map[DBAction]*model.Inscriptions, 1)
+	ret[DBActionCreate] = ins
 	return ret
 }
 
@@ -267,16 +274,17 @@ func (tc *TxResultHandler) BuildBalance(e *TxResult) (txns []*model.BalanceTxn,
 	balances = make(map[DBAction][]*model.Balances, 2)
 	for _, event := range balanceTxEvents {
 		txns = append(txns, &model.BalanceTxn{
-			Chain:     e.MD.Chain,
-			Protocol:  e.MD.Protocol,
-			Event:     tc.getEventByOperate(e.MD.Operate),
-			Address:   event.Address,
-			Tick:      e.MD.Tick,
-			Amount:    event.Amount,
-			Balance:   event.OverallBalance,
-			Available: event.AvailableBalance,
-			TxHash:    e.Tx.Hash,
-			CreatedAt: time.Unix(int64(e.Block.Time), 0),
+			Chain:       e.MD.Chain,
+			Protocol:    e.MD.Protocol,
+			Event:       tc.getEventByOperate(e.MD.Operate),
+			Address:     event.Address,
+			Tick:        e.MD.Tick,
+			Amount:      event.Amount,
+			Balance:     event.OverallBalance,
+			Available:   event.AvailableBalance,
+			TxHash:      e.Tx.Hash,
+			BlockNumber: e.Tx.BlockNumber.Uint64(),
+			CreatedAt:   time.Unix(int64(e.Block.Time), 0),
 		})
 
 		if _, ok := balances[event.Action]; !ok {
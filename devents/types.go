@@ -59,6 +59,7 @@ type Deploy struct {
 	MaxSupply decimal.Decimal
 	MintLimit decimal.Decimal
 	Decimal   int8
+	Extra     map[string]interface{} // protocol specific deploy params, stored as the inscription extra
 }
 
 type Mint struct {
@@ -97,7 +97,7 @@ func (e *Explorer) Scan() {
 	}
 
 	startBlock := e.config.Scan.StartBlock
-	if blockNum.Uint64() > 0 {
+	if blockNum != nil && blockNum.Uint64() > 0 {
 		startBlock = blockNum.Uint64() + 1
 	}
 
@@ -25,14 +25,21 @@ type FindAllInscriptionsCmd struct {
 }
 
 type IndsGetTicksCmd struct {
-	Limit    int    `json:"limit"`
-	Offset   int    `json:"offset"`
-	Chain    string `json:"chain"`
-	Protocol string `json:"protocol"`
-	Tick     string `json:"tick"`
-	DeployBy string `json:"deploy_by"`
-	Sort     int    `json:"sort"`
-	SortMode int    `json:"sort_mode"`
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+	Chain      string   `json:"chain"`
+	Chains     []string `json:"chains"` // several chains, along with chain both filters apply
+	Protocol   string   `json:"protocol"`
+	Tick       string   `json:"tick"`
+	DeployBy   string   `json:"deploy_by"`
+	Sort       int      `json:"sort"`
+	SortMode   int      `json:"sort_mode"`
+	TickLike   *string  `json:"tick_like"`
+	MintStatus *int     `json:"mint_status"` // 0: all 1: minting 2: completed
+	FromMinted *string  `json:"from_minted"` // minted amount lower bound, inclusive
+	ToMinted   *string  `json:"to_minted"`   // minted amount upper bound, inclusive
+
+	SinceDeployTime *int64 `json:"since_deploy_time"` // unix seconds, the ticks deployed at or after it
 }
 
 type FindAllInscriptionsResponse struct {
@@ -146,6 +153,10 @@ type BalanceBrief struct {
 	TransferType int8         `json:"transfer_type"`
 	Utxos        []*UTXOBrief `json:"utxos,omitempty"`
 	DeployHash   string       `json:"deploy_hash"`
+
+	// the unspent utxos of the address, the list is truncated at balanceUtxosLimit when it is above
+	UtxoTotal      int64 `json:"utxo_total,omitempty"`
+	UtxosTruncated bool  `json:"utxos_truncated,omitempty"`
 }
 
 type UTXOBrief struct {
@@ -174,12 +185,13 @@ type FindTickHoldersCmd struct {
 }
 
 type IndsGetHoldersByTickCmd struct {
-	Limit    int
-	Offset   int
-	Chain    string
-	Protocol string
-	Tick     string
-	SortMode int
+	Limit      int
+	Offset     int
+	Chain      string
+	Protocol   string
+	Tick       string
+	SortMode   int
+	MinBalance *string `json:"min_balance"`
 }
 
 type GetTickBriefsCmd struct {
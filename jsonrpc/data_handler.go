@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/shopspring/decimal"
 	"github.com/uxuycom/indexer/model"
+	"github.com/uxuycom/indexer/storage"
 	"strings"
 )
 
@@ -46,16 +47,22 @@ func findAddressBalances(s *RpcServer, limit, offset int, address, chain, protoc
 	return resp, nil
 }
 
-func findInsciptions(s *RpcServer, limit, offset int, chain, protocol, tick, deployBy string, sort, sortMode int) (interface{}, error) {
-	protocol = strings.ToLower(protocol)
-	tick = strings.ToLower(tick)
-	cacheKey := fmt.Sprintf("all_ins_%d_%d_%s_%s_%s_%s_%d_%d", limit, offset, chain, protocol, tick, deployBy, sort, sortMode)
+func findInsciptions(s *RpcServer, limit, offset int, filter storage.InscriptionFilter, sort, sortMode int) (interface{}, error) {
+	filter.Protocol = strings.ToLower(filter.Protocol)
+	filter.Tick = strings.ToLower(filter.Tick)
+	filter.TickLike = strings.ToLower(filter.TickLike)
+	var since int64
+	if !filter.SinceDeployTime.IsZero() {
+		since = filter.SinceDeployTime.Unix()
+	}
+	cacheKey := fmt.Sprintf("all_ins_%d_%d_%s_%s_%s_%s_%s_%s_%d_%s_%s_%d_%d_%d", limit, offset, filter.Chain, strings.Join(filter.Chains, ","),
+		filter.Protocol, filter.Tick, filter.TickLike, filter.DeployBy, filter.MintStatus, filter.FromMinted, filter.ToMinted, since, sort, sortMode)
 	if ins, ok := s.cacheStore.Get(cacheKey); ok {
 		if allIns, ok := ins.(*FindAllInscriptionsResponse); ok {
 			return allIns, nil
 		}
 	}
-	inscriptions, total, err := s.dbc.GetInscriptions(limit, offset, chain, protocol, tick, deployBy, sort, sortMode)
+	inscriptions, total, err := s.dbc.GetInscriptions(limit, offset, filter, storage.SortField(sort), sortMode)
 	if err != nil {
 		return ErrRPCInternal, err
 	}
@@ -107,17 +114,17 @@ func findInsciptions(s *RpcServer, limit, offset int, chain, protocol, tick, dep
 	return resp, nil
 }
 
-func findTickHolders(s *RpcServer, limit int, offset int, chain, protocol, tick string, sortMode int) (interface{}, error) {
+func findTickHolders(s *RpcServer, limit int, offset int, chain, protocol, tick, minBalance string, sortMode int) (interface{}, error) {
 	protocol = strings.ToLower(protocol)
 	tick = strings.ToLower(tick)
-	cacheKey := fmt.Sprintf("all_ins_%d_%d_%s_%s_%s_%d", limit, offset, chain, protocol, tick, sortMode)
+	cacheKey := fmt.Sprintf("all_ins_%d_%d_%s_%s_%s_%s_%d", limit, offset, chain, protocol, tick, minBalance, sortMode)
 	if ins, ok := s.cacheStore.Get(cacheKey); ok {
 		if allIns, ok := ins.(*FindTickHoldersResponse); ok {
 			return allIns, nil
 		}
 	}
 
-	holders, total, err := s.dbc.GetHoldersByTick(limit, offset, chain, protocol, tick, sortMode)
+	holders, total, err := s.dbc.GetHoldersByTick(limit, offset, chain, protocol, tick, minBalance, sortMode)
 	if err != nil {
 		return ErrRPCInternal, err
 	}
@@ -2,7 +2,9 @@ package jsonrpc
 
 import (
 	"errors"
+	"github.com/uxuycom/indexer/storage"
 	"github.com/uxuycom/indexer/xylog"
+	"time"
 )
 
 var rpcHandlersBeforeInitV2 = map[string]commandHandler{
@@ -24,7 +26,24 @@ func indsGetTicks(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (int
 	}
 
 	xylog.Logger.Infof("find all Inscriptions cmd params:%v", req)
-	return findInsciptions(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, req.DeployBy, req.Sort, req.SortMode)
+	filter := storage.InscriptionFilter{Chain: req.Chain, Chains: req.Chains, Protocol: req.Protocol, Tick: req.Tick,
+		DeployBy: req.DeployBy, MintStatus: storage.MintStatusAll}
+	if req.TickLike != nil {
+		filter.TickLike = *req.TickLike
+	}
+	if req.MintStatus != nil {
+		filter.MintStatus = *req.MintStatus
+	}
+	if req.FromMinted != nil {
+		filter.FromMinted = *req.FromMinted
+	}
+	if req.ToMinted != nil {
+		filter.ToMinted = *req.ToMinted
+	}
+	if req.SinceDeployTime != nil {
+		filter.SinceDeployTime = time.Unix(*req.SinceDeployTime, 0)
+	}
+	return findInsciptions(s, req.Limit, req.Offset, filter, req.Sort, req.SortMode)
 }
 
 func indsGetBalanceByAddress(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -44,5 +63,9 @@ func indsGetHoldersByTick(s *RpcServer, cmd interface{}, closeChan <-chan struct
 	}
 	xylog.Logger.Infof("find user balances cmd params:%v", req)
 
-	return findTickHolders(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, req.SortMode)
+	minBalance := ""
+	if req.MinBalance != nil {
+		minBalance = *req.MinBalance
+	}
+	return findTickHolders(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, minBalance, req.SortMode)
 }
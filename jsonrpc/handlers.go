@@ -32,6 +32,10 @@ import (
 	"strings"
 )
 
+// balanceUtxosLimit max unspent utxos listed in the balance of an address, the response reports the total & whether
+// the list is truncated
+const balanceUtxosLimit = 1000
+
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"inscription.All":           handleFindAllInscriptions,
 	"inscription.Tick":          handleFindInscriptionTick,
@@ -51,7 +55,9 @@ func handleFindAllInscriptions(s *RpcServer, cmd interface{}, closeChan <-chan s
 		return ErrRPCInvalidParams, errors.New("invalid params")
 	}
 	xylog.Logger.Infof("find all Inscriptions cmd params:%v", req)
-	return findInsciptions(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, req.DeployBy, req.Sort, storage.OrderByModeDesc)
+	filter := storage.InscriptionFilter{Chain: req.Chain, Protocol: req.Protocol, Tick: req.Tick, DeployBy: req.DeployBy,
+		MintStatus: storage.MintStatusAll}
+	return findInsciptions(s, req.Limit, req.Offset, filter, req.Sort, storage.OrderByModeDesc)
 }
 
 func handleFindInscriptionTick(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -230,7 +236,7 @@ func handleFindAddressBalance(s *RpcServer, cmd interface{}, closeChan <-chan st
 	switch inscription.TransferType {
 	case model.TransferTypeHash:
 		// transfer with hash
-		result, err := s.dbc.GetUtxosByAddress(req.Address, req.Chain, req.Protocol, req.Tick)
+		result, err := s.dbc.GetUtxosByAddress(balanceUtxosLimit, 0, req.Address, req.Chain, req.Protocol, req.Tick)
 		if err != nil {
 			return ErrRPCInternal, err
 		}
@@ -243,6 +249,16 @@ func handleFindAddressBalance(s *RpcServer, cmd interface{}, closeChan <-chan st
 			})
 		}
 		resp.Utxos = utxos
+
+		// the count is only needed when the list may be truncated
+		resp.UtxoTotal = int64(len(result))
+		if len(result) >= balanceUtxosLimit {
+			resp.UtxoTotal, err = s.dbc.GetUTXOCount(req.Address, req.Chain, req.Protocol, req.Tick)
+			if err != nil {
+				return ErrRPCInternal, err
+			}
+		}
+		resp.UtxosTruncated = resp.UtxoTotal > int64(len(utxos))
 	}
 	s.cacheStore.Set(cacheKey, resp)
 	return resp, nil
@@ -254,7 +270,7 @@ func handleFindTickHolders(s *RpcServer, cmd interface{}, closeChan <-chan struc
 		return ErrRPCInvalidParams, errors.New("invalid params")
 	}
 	xylog.Logger.Infof("find tick holders cmd params:%v", req)
-	return findTickHolders(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, storage.OrderByModeDesc)
+	return findTickHolders(s, req.Limit, req.Offset, req.Chain, req.Protocol, req.Tick, "", storage.OrderByModeDesc)
 }
 
 func handleGetLastBlockNumber(s *RpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -428,7 +444,7 @@ func handleGetTickBriefs(s *RpcServer, cmd interface{}, closeChan <-chan struct{
 				Decimals:     dbTick.Decimals,
 				CreatedAt:    dbTick.CreatedAt,
 			}
-			stat, _ := s.dbc.FindInscriptionsStatsByTick(dbTick.Chain, dbTick.Protocol, dbTick.Tick)
+			stat, _ := s.dbc.FindInscriptionStatsByTick(dbTick.Chain, dbTick.Protocol, dbTick.Tick)
 			if stat != nil {
 				overview.Holders = stat.Holders
 				overview.Minted = stat.Minted
@@ -7,6 +7,7 @@ package jsonrpc
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -37,6 +38,9 @@ const (
 	// RPC server is allowed to stay open without authenticating before it
 	// is closed.
 	rpcAuthTimeoutSeconds = 10
+
+	// healthCheckTimeout bounds the database check of the health endpoint
+	healthCheckTimeout = 3 * time.Second
 )
 
 var (
@@ -108,7 +112,7 @@ type RpcServer struct {
 	wg                     sync.WaitGroup
 	requestProcessShutdown chan struct{}
 	quit                   chan int
-	dbc                    *storage.DBClient
+	dbc                    storage.Store
 	cacheConfig            *config.CacheConfig
 	cacheStore             *cache_store.CacheStore
 }
@@ -653,6 +657,8 @@ func (s *RpcServer) Start() {
 		s.setRule(w, r)
 	})
 
+	rpcServeMux.HandleFunc("/health", s.handleHealth)
+
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		rpcHandlers = rpcHandlersBeforeInit
 		s.setRule(w, r)
@@ -687,6 +693,22 @@ func (s *RpcServer) setRule(w http.ResponseWriter, r *http.Request) {
 	s.jsonRPCRead(w, r, true)
 }
 
+// handleHealth is the readiness probe, it reports the database health and answers 503 when the database is unavailable.
+func (s *RpcServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	status, err := s.dbc.HealthCheck(ctx)
+	if err != nil {
+		rpcsLog.Errorf("health check failed: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err = json.NewEncoder(w).Encode(status); err != nil {
+		rpcsLog.Errorf("Failed to write health status: %v", err)
+	}
+}
+
 // RpcServerConfig is a descriptor containing the RPC server configuration.
 type RpcServerConfig struct {
 	// Listeners defines a slice of listeners for which the RPC server will
@@ -701,7 +723,7 @@ type RpcServerConfig struct {
 }
 
 // NewRPCServer returns a new instance of the RpcServer struct.
-func NewRPCServer(dbc *storage.DBClient, cacheConfig *config.CacheConfig) (*RpcServer, error) {
+func NewRPCServer(dbc storage.Store, cacheConfig *config.CacheConfig) (*RpcServer, error) {
 	//load cfg
 	loadCfg()
 
@@ -0,0 +1,124 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/cache_store"
+	"github.com/uxuycom/indexer/model"
+	"github.com/uxuycom/indexer/storage"
+	"github.com/uxuycom/indexer/xylog"
+)
+
+func init() {
+	xylog.InitLog(logrus.InfoLevel, "")
+}
+
+// fakeStore a storage.Store answering the health check, the methods it does not override panic on the nil Store
+type fakeStore struct {
+	storage.Store
+	status *storage.HealthStatus
+	err    error
+}
+
+func (f *fakeStore) HealthCheck(context.Context) (*storage.HealthStatus, error) {
+	return f.status, f.err
+}
+
+func TestHandleHealth(t *testing.T) {
+	cases := []struct {
+		name  string
+		store *fakeStore
+		code  int
+	}{
+		{"healthy", &fakeStore{status: &storage.HealthStatus{Healthy: true, OpenConnections: 2, Idle: 2}}, http.StatusOK},
+		{"unavailable", &fakeStore{status: &storage.HealthStatus{Error: "connection refused"}, err: errors.New("connection refused")},
+			http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		s := &RpcServer{dbc: c.store}
+		w := httptest.NewRecorder()
+		s.handleHealth(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.Equal(t, c.code, w.Code, c.name)
+
+		var status storage.HealthStatus
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status), c.name)
+		assert.Equal(t, *c.store.status, status, c.name)
+	}
+}
+
+// fakeBalanceStore a storage.Store holding the unspent utxos of a tick transferred with hash
+type fakeBalanceStore struct {
+	storage.Store
+	utxos int
+}
+
+func (f *fakeBalanceStore) FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error) {
+	return &model.Inscriptions{Chain: chain, Protocol: protocol, Tick: tick, TransferType: model.TransferTypeHash}, nil
+}
+
+func (f *fakeBalanceStore) FindUserBalanceByTick(chain, protocol, tick, address string) (*model.Balances, error) {
+	return &model.Balances{Chain: chain, Protocol: protocol, Tick: tick, Address: address, Balance: decimal.NewFromInt(int64(f.utxos))}, nil
+}
+
+func (f *fakeBalanceStore) GetUtxosByAddress(limit, offset int, address, chain, protocol, tick string) ([]*model.UTXO, error) {
+	utxos := make([]*model.UTXO, 0, limit)
+	for i := offset; i < f.utxos && len(utxos) < limit; i++ {
+		utxos = append(utxos, &model.UTXO{Chain: chain, Tick: tick, Address: address, Amount: decimal.NewFromInt(1)})
+	}
+	return utxos, nil
+}
+
+func (f *fakeBalanceStore) GetUTXOCount(address, chain, protocol, tick string) (int64, error) {
+	return int64(f.utxos), nil
+}
+
+func TestHandleFindAddressBalanceUtxos(t *testing.T) {
+	cases := []struct {
+		utxos     int
+		listed    int
+		truncated bool
+	}{
+		{3, 3, false},
+		{balanceUtxosLimit, balanceUtxosLimit, false},
+		{balanceUtxosLimit + 5, balanceUtxosLimit, true},
+	}
+	for _, c := range cases {
+		s := &RpcServer{dbc: &fakeBalanceStore{utxos: c.utxos}, cacheStore: cache_store.NewCacheStore(10, 60)}
+		resp, err := handleFindAddressBalance(s, &FindUserBalanceCmd{Address: "0xa", Chain: "btc", Protocol: "brc-20", Tick: "ordi"}, nil)
+		assert.Nil(t, err)
+		brief := resp.(*BalanceBrief)
+		assert.Equal(t, c.listed, len(brief.Utxos), c.utxos)
+		assert.Equal(t, int64(c.utxos), brief.UtxoTotal, c.utxos)
+		assert.Equal(t, c.truncated, brief.UtxosTruncated, c.utxos)
+	}
+}
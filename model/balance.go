@@ -33,34 +33,58 @@ const (
 	UTXOStatusSpent   = 2
 )
 
+// Balances the balance of an address per tick. Besides the unique keys the indexes serve:
+//   - idx_balances_chain_updated_at: GetBalancesUpdatedSince
+//   - idx_balances_tick_balance: the holders of a tick by balance, GetHoldersByTick, GetTopHoldersByTick &
+//     GetInscriptionHolderCount
 type Balances struct {
 	ID        uint64          `gorm:"primaryKey" json:"id"`
 	SID       uint64          `json:"sid"  gorm:"column:sid"`
-	Chain     string          `json:"chain" gorm:"column:chain"`
-	Protocol  string          `json:"protocol" gorm:"column:protocol"`
-	Address   string          `json:"address" gorm:"column:address"`
-	Tick      string          `json:"tick" gorm:"column:tick"`
-	Available decimal.Decimal `json:"available" gorm:"column:available;type:decimal(38,18)"` // available balance = overall balance - transferable balance
-	Balance   decimal.Decimal `json:"balance" gorm:"column:balance;type:decimal(38,18)"`     // overall balance
+	Chain     string          `json:"chain" gorm:"column:chain;uniqueIndex:address,priority:2;index:idx_balances_chain_updated_at,priority:1;index:idx_balances_tick_balance,priority:1"`
+	Protocol  string          `json:"protocol" gorm:"column:protocol;uniqueIndex:address,priority:3;index:idx_balances_tick_balance,priority:2"`
+	Address   string          `json:"address" gorm:"column:address;uniqueIndex:address,priority:1"`
+	Tick      string          `json:"tick" gorm:"column:tick;uniqueIndex:address,priority:4;index:idx_balances_tick_balance,priority:3"`
+	Available decimal.Decimal `json:"available" gorm:"column:available;type:decimal(38,18)"`                                        // available balance = overall balance - transferable balance
+	Balance   decimal.Decimal `json:"balance" gorm:"column:balance;type:decimal(38,18);index:idx_balances_tick_balance,priority:4"` // overall balance
+	Version   uint            `json:"version" gorm:"column:version;not null;default:0"`                                             // bumped by every update, guards concurrent writers
 	CreatedAt time.Time       `json:"created_at" gorm:"column:created_at"`
-	UpdatedAt time.Time       `json:"updated_at" gorm:"column:updated_at"`
+	UpdatedAt time.Time       `json:"updated_at" gorm:"column:updated_at;index:idx_balances_chain_updated_at,priority:2"`
+	Label     string          `json:"label,omitempty" gorm:"-"` // the address label, only set by the holders listings
 }
 
 func (Balances) TableName() string {
 	return "balances"
 }
 
+// TickHolder holder balance with the rank by balance of the tick, holders with the same balance share the rank
+type TickHolder struct {
+	Balances
+	Rank int64 `json:"rank" gorm:"-"`
+}
+
+// AddressHolding the balance of an address summed over all the ticks of a protocol
+type AddressHolding struct {
+	Address string          `json:"address" gorm:"column:address"`
+	Total   decimal.Decimal `json:"total" gorm:"column:total"` // sum of the balances of the ticks
+	Ticks   int64           `json:"ticks" gorm:"column:ticks"` // number of ticks with a positive balance
+	Label   string          `json:"label,omitempty" gorm:"column:label"`
+}
+
+// UTXO an output of a utxo protocol. Besides the outpoint unique key the indexes serve:
+//   - idx_utxos_address: the utxos of an address, GetUtxosByAddress, GetUTXOCount, SumUTXOValue, SelectUTXOs &
+//     GetUTXOsByAddressTick
 type UTXO struct {
 	ID        uint64          `gorm:"primaryKey" json:"id"`
 	Sn        string          `json:"sn" gorm:"column:sn"`
-	Chain     string          `json:"chain" gorm:"column:chain"`
-	Protocol  string          `json:"protocol" gorm:"column:protocol"`
-	Address   string          `json:"address" gorm:"column:address"`
-	Tick      string          `json:"tick" gorm:"column:tick"`
+	Chain     string          `json:"chain" gorm:"column:chain;uniqueIndex:uq_utxos_chain_tx_vout,priority:1;index:idx_utxos_address,priority:2"`
+	Protocol  string          `json:"protocol" gorm:"column:protocol;index:idx_utxos_address,priority:3"`
+	Address   string          `json:"address" gorm:"column:address;index:idx_utxos_address,priority:1"`
+	Tick      string          `json:"tick" gorm:"column:tick;index:idx_utxos_address,priority:4"`
 	Amount    decimal.Decimal `json:"amount" gorm:"column:amount;type:decimal(38,18)"` // amount
 	RootHash  string          `json:"root_hash" gorm:"column:root_hash"`
-	TxHash    string          `json:"tx_hash" gorm:"column:tx_hash"`
-	Status    int8            `json:"status" gorm:"column:status"` // tx status
+	TxHash    string          `json:"tx_hash" gorm:"column:tx_hash;uniqueIndex:uq_utxos_chain_tx_vout,priority:2"`
+	Vout      uint32          `json:"vout" gorm:"column:vout;not null;default:0;uniqueIndex:uq_utxos_chain_tx_vout,priority:3"` // output index in the tx
+	Status    int8            `json:"status" gorm:"column:status"`                                                              // tx status
 	CreatedAt time.Time       `json:"created_at" gorm:"column:created_at"`
 	UpdatedAt time.Time       `json:"updated_at" gorm:"column:updated_at"`
 }
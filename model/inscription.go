@@ -23,9 +23,12 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 const (
@@ -42,9 +45,9 @@ type ChainInfo struct {
 type Inscriptions struct {
 	ID           uint32          `gorm:"primaryKey" json:"id"` // ID
 	SID          uint32          `json:"sid"  gorm:"column:sid"`
-	Chain        string          `json:"chain" gorm:"column:chain"`
-	Protocol     string          `json:"protocol" gorm:"column:protocol"`
-	Tick         string          `json:"tick" gorm:"column:tick"`
+	Chain        string          `json:"chain" gorm:"column:chain;uniqueIndex:uq_chain_protocol_name,priority:1"`
+	Protocol     string          `json:"protocol" gorm:"column:protocol;uniqueIndex:uq_chain_protocol_name,priority:2"`
+	Tick         string          `json:"tick" gorm:"column:tick;uniqueIndex:uq_chain_protocol_name,priority:3"`
 	Name         string          `json:"name" gorm:"column:name"`
 	LimitPerMint decimal.Decimal `gorm:"column:limit_per_mint;type:decimal(38,18)" json:"limit_per_mint"`
 	DeployBy     string          `json:"deploy_by" gorm:"column:deploy_by"`
@@ -55,19 +58,67 @@ type Inscriptions struct {
 	CreatedAt    time.Time       `json:"created_at" gorm:"column:created_at"`
 	UpdatedAt    time.Time       `json:"updated_at" gorm:"column:updated_at"`
 	Decimals     int8            `json:"decimals" gorm:"column:decimals"`
+	DeletedAt    gorm.DeletedAt  `json:"-" gorm:"column:deleted_at;index"`    // soft deleted (tombstone) time
+	Extra        datatypes.JSON  `json:"extra,omitempty" gorm:"column:extra"` // protocol specific deploy metadata
 }
 
 func (Inscriptions) TableName() string {
 	return "inscriptions"
 }
 
+// ExtraValue decodes the value of the key of the protocol metadata into v, false when the key is absent
+func (ins *Inscriptions) ExtraValue(key string, v interface{}) (bool, error) {
+	extra, err := decodeExtra(ins.Extra)
+	if err != nil {
+		return false, err
+	}
+
+	raw, ok := extra[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// SetExtraValue sets the key of the protocol metadata to the json encoding of v, the other keys are kept
+func (ins *Inscriptions) SetExtraValue(key string, v interface{}) error {
+	extra, err := decodeExtra(ins.Extra)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	extra[key] = raw
+
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return err
+	}
+	ins.Extra = data
+	return nil
+}
+
+func decodeExtra(data datatypes.JSON) (map[string]json.RawMessage, error) {
+	extra := make(map[string]json.RawMessage)
+	if len(data) == 0 || string(data) == "null" {
+		return extra, nil
+	}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
 // InscriptionsStats inscriptions statistics
 type InscriptionsStats struct {
 	ID                uint32          `gorm:"primaryKey" json:"id"`
 	SID               uint32          `json:"sid"  gorm:"column:sid"`
-	Chain             string          `json:"chain" gorm:"column:chain"`
-	Protocol          string          `json:"protocol" gorm:"column:protocol"`
-	Tick              string          `json:"tick" gorm:"column:tick"`
+	Chain             string          `json:"chain" gorm:"column:chain;uniqueIndex:uq_stats_chain_protocol_tick,priority:1"`
+	Protocol          string          `json:"protocol" gorm:"column:protocol;uniqueIndex:uq_stats_chain_protocol_tick,priority:2"`
+	Tick              string          `json:"tick" gorm:"column:tick;uniqueIndex:uq_stats_chain_protocol_tick,priority:3"`
 	Minted            decimal.Decimal `gorm:"column:minted;type:decimal(38,18)" json:"minted"`
 	MintCompletedTime *time.Time      `gorm:"column:mint_completed_time" json:"mint_completed_time"`
 	MintFirstBlock    uint64          `gorm:"column:mint_first_block" json:"mint_first_block"`
@@ -101,6 +152,46 @@ type InscriptionOverView struct {
 	Holders      uint64          `json:"holders" gorm:"column:holders"`
 	Minted       decimal.Decimal `gorm:"column:minted;type:decimal(38,18)" json:"minted"`
 	TxCnt        uint64          `gorm:"column:tx_cnt" json:"tx_cnt"`
+	Extra        datatypes.JSON  `json:"extra,omitempty" gorm:"column:extra"`
+}
+
+// InscriptionDeployerView the overview of an inscription along with the current holding of its deployer
+type InscriptionDeployerView struct {
+	InscriptionOverView
+	DeployerBalance decimal.Decimal `json:"deployer_balance" gorm:"column:deployer_balance"` // 0 when the deployer holds none
+	DeployerHolds   bool            `json:"deployer_holds" gorm:"-"`                         // the deployer balance is positive
+}
+
+// TickMarketStats market rollup of a tick
+type TickMarketStats struct {
+	Chain             string          `json:"chain" gorm:"column:chain"`
+	Protocol          string          `json:"protocol" gorm:"column:protocol"`
+	Tick              string          `json:"tick" gorm:"column:tick"`
+	TotalSupply       decimal.Decimal `json:"total_supply" gorm:"column:total_supply"`
+	Minted            decimal.Decimal `json:"minted" gorm:"column:minted"`
+	Progress          decimal.Decimal `json:"progress" gorm:"column:progress"` // minted / total_supply
+	Holders           uint64          `json:"holders" gorm:"column:holders"`
+	TxCnt             uint64          `json:"tx_cnt" gorm:"column:tx_cnt"`
+	DeployTime        time.Time       `json:"deploy_time" gorm:"column:deploy_time"`
+	MintCompletedTime *time.Time      `json:"mint_completed_time" gorm:"column:mint_completed_time"`
+	CirculatingSupply decimal.Decimal `json:"circulating_supply" gorm:"-"` // the balances less the ones of the burn addresses
+}
+
+// ProtocolSummary rollup of all ticks of a protocol
+type ProtocolSummary struct {
+	Chain    string          `json:"chain" gorm:"-"`
+	Protocol string          `json:"protocol" gorm:"-"`
+	Ticks    int64           `json:"ticks" gorm:"column:ticks"` // deployed ticks
+	Minted   decimal.Decimal `json:"minted" gorm:"column:minted"`
+	Holders  uint64          `json:"holders" gorm:"column:holders"`
+}
+
+// DeployerStats rollup of the ticks deployed by an address
+type DeployerStats struct {
+	DeployBy        string    `json:"deploy_by" gorm:"column:deploy_by"`
+	Deploys         int64     `json:"deploys" gorm:"column:deploys"` // deployed ticks
+	FirstDeployTime time.Time `json:"first_deploy_time" gorm:"column:first_deploy_time"`
+	LastDeployTime  time.Time `json:"last_deploy_time" gorm:"column:last_deploy_time"`
 }
 
 type InscriptionBrief struct {
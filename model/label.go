@@ -0,0 +1,47 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package model
+
+import "time"
+
+// the categories of the known address labels
+const (
+	LabelCategoryExchange = "exchange"
+	LabelCategoryBurn     = "burn" // the balances of burn addresses are out of the circulating supply
+	LabelCategoryDeployer = "deployer"
+)
+
+// AddressLabel a label of a known address of a chain, e.g. an exchange hot wallet
+type AddressLabel struct {
+	ID        uint64    `gorm:"primaryKey" json:"id"`
+	Chain     string    `json:"chain" gorm:"column:chain;uniqueIndex:uq_address_labels_chain_address,priority:1"`
+	Address   string    `json:"address" gorm:"column:address;uniqueIndex:uq_address_labels_chain_address,priority:2"`
+	Label     string    `json:"label" gorm:"column:label"`       // display name
+	Category  string    `json:"category" gorm:"column:category"` // one of the LabelCategory* categories
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (AddressLabel) TableName() string {
+	return "address_labels"
+}
@@ -25,12 +25,38 @@ package model
 import "time"
 
 type BlockStatus struct {
-	Chain       string    `json:"chain" gorm:"column:chain"`               // chain name
-	BlockHash   string    `json:"block_hash" gorm:"column:block_hash"`     // block hash
-	BlockNumber uint64    `json:"block_number" gorm:"column:block_number"` // block height
-	BlockTime   time.Time `json:"block_time" gorm:"column:block_time"`     // block time
+	Chain       string     `json:"chain" gorm:"column:chain"`               // chain name
+	BlockHash   string     `json:"block_hash" gorm:"column:block_hash"`     // block hash
+	BlockNumber uint64     `json:"block_number" gorm:"column:block_number"` // block height
+	BlockTime   time.Time  `json:"block_time" gorm:"column:block_time"`     // block time
+	IndexedAt   *time.Time `json:"indexed_at" gorm:"column:indexed_at"`     // when the block was stored, nil before it was tracked
 }
 
 func (BlockStatus) TableName() string {
 	return "block"
 }
+
+// BlockResult the rows produced by indexing blocks of a chain, applied atomically by the storage SaveBlockResult.
+// The *Updates slices hold the rows already stored, the others the new rows.
+type BlockResult struct {
+	Inscriptions            []*Inscriptions
+	InscriptionUpdates      []*Inscriptions
+	InscriptionStats        []*InscriptionsStats
+	InscriptionStatsUpdates []*InscriptionsStats
+	Balances                []*Balances
+	BalanceUpdates          []*Balances
+	Txs                     []*Transaction
+	AddressTxs              []*AddressTxs
+	BalanceTxs              []*BalanceTxn
+	BlockStatus             *BlockStatus // the last block of the result, the chain of the updates
+}
+
+// SchemaVersion a schema version applied by the storage migration runner
+type SchemaVersion struct {
+	Version   uint32    `json:"version" gorm:"column:version;primaryKey;autoIncrement:false"` // schema version
+	AppliedAt time.Time `json:"applied_at" gorm:"column:applied_at"`                          // the time the version was applied
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
@@ -44,6 +44,11 @@ const (
 	TransactionEventExchange TxEvent = 6
 )
 
+// Valid reports whether the event is one of the known transaction events
+func (e TxEvent) Valid() bool {
+	return e >= TransactionEventDeploy && e <= TransactionEventExchange
+}
+
 type TransactionRaw struct {
 	ChainInfo
 	Id              string
@@ -66,17 +71,20 @@ type TransactionRaw struct {
 	UpdatedAt       time.Time
 }
 
+// AddressTxs the txs an address takes part in, the indexes serve:
+//   - idx_address_txs_address: the txs of an address, GetTransactionsByAddress & GetAddressTxs
+//   - idx_address_txs_tx_hash: the address txs of the txs deleted by the rollback above a block
 type AddressTxs struct {
 	ID       uint64          `gorm:"primaryKey" json:"id"`
 	Event    TxEvent         `json:"event" gorm:"column:event"`
-	TxHash   string          `json:"tx_hash" gorm:"column:tx_hash"`
-	Address  string          `json:"address" gorm:"column:address"`
+	TxHash   string          `json:"tx_hash" gorm:"column:tx_hash;index:idx_address_txs_tx_hash,priority:1"`
+	Address  string          `json:"address" gorm:"column:address;index:idx_address_txs_address,priority:1"`
 	Amount   decimal.Decimal `json:"amount" gorm:"column:amount;type:decimal(38,18)"`
-	Tick     string          `json:"tick" gorm:"column:tick"`
-	Protocol string          `json:"protocol" gorm:"column:protocol"`
+	Tick     string          `json:"tick" gorm:"column:tick;index:idx_address_txs_address,priority:4"`
+	Protocol string          `json:"protocol" gorm:"column:protocol;index:idx_address_txs_address,priority:3"`
 	Operate  string          `json:"operate" gorm:"column:operate"`
 	//Desc      string          `json:"desc" gorm:"column:desc"`
-	Chain     string    `json:"chain" gorm:"column:chain"`
+	Chain     string    `json:"chain" gorm:"column:chain;index:idx_address_txs_address,priority:2;index:idx_address_txs_tx_hash,priority:2"`
 	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
 }
@@ -85,41 +93,66 @@ func (AddressTxs) TableName() string {
 	return "address_txs"
 }
 
+// AddressStats activity summary of an address on a chain
+type AddressStats struct {
+	Chain      string `json:"chain" gorm:"-"`
+	Address    string `json:"address" gorm:"-"`
+	Ticks      int64  `json:"ticks" gorm:"column:ticks"`             // ticks with a positive balance
+	TxCnt      int64  `json:"tx_cnt" gorm:"column:tx_cnt"`           // distinct txs involving the address
+	FirstBlock uint64 `json:"first_block" gorm:"column:first_block"` // block of the first tx, 0 without txs
+	LastBlock  uint64 `json:"last_block" gorm:"column:last_block"`   // block of the last tx, 0 without txs
+}
+
+// ActivityMetrics activity of a chain in the time window starting at Since
+type ActivityMetrics struct {
+	Chain           string    `json:"chain" gorm:"-"`
+	Since           time.Time `json:"since" gorm:"-"`
+	TxCnt           int64     `json:"tx_cnt" gorm:"column:tx_cnt"`                     // txs with a block time in the window
+	ActiveAddresses int64     `json:"active_addresses" gorm:"column:active_addresses"` // distinct addresses of the txs
+}
+
 type BalanceTxn struct {
-	ID        uint64          `gorm:"primaryKey" json:"id"`
-	Chain     string          `json:"chain" gorm:"column:chain"`
-	Protocol  string          `json:"protocol" gorm:"column:protocol"`
-	Event     TxEvent         `json:"event" gorm:"column:event"`
-	Address   string          `json:"address" gorm:"column:address"`
-	Tick      string          `json:"tick" gorm:"column:tick"`
-	Amount    decimal.Decimal `json:"amount" gorm:"column:amount;type:decimal(38,18)"`
-	Available decimal.Decimal `json:"available" gorm:"column:available;type:decimal(38,18)"`
-	Balance   decimal.Decimal `json:"balance" gorm:"column:balance;type:decimal(38,18)"`
-	TxHash    string          `json:"tx_hash" gorm:"column:tx_hash"`
-	CreatedAt time.Time       `json:"created_at" gorm:"column:created_at"`
-	UpdatedAt time.Time       `json:"updated_at" gorm:"column:updated_at"`
+	ID          uint64          `gorm:"primaryKey" json:"id"`
+	Chain       string          `json:"chain" gorm:"column:chain"`
+	Protocol    string          `json:"protocol" gorm:"column:protocol"`
+	Event       TxEvent         `json:"event" gorm:"column:event"`
+	Address     string          `json:"address" gorm:"column:address"`
+	Tick        string          `json:"tick" gorm:"column:tick"`
+	Amount      decimal.Decimal `json:"amount" gorm:"column:amount;type:decimal(38,18)"`
+	Available   decimal.Decimal `json:"available" gorm:"column:available;type:decimal(38,18)"`
+	Balance     decimal.Decimal `json:"balance" gorm:"column:balance;type:decimal(38,18)"`
+	TxHash      string          `json:"tx_hash" gorm:"column:tx_hash"`
+	BlockNumber uint64          `json:"block_number" gorm:"column:block_number"` // height of the tx
+	CreatedAt   time.Time       `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"column:updated_at"`
 }
 
 func (BalanceTxn) TableName() string {
 	return "balance_txn"
 }
 
+// Transaction an inscription tx, the unique key (chain, tx_hash) keeps a reprocessed block from storing its txs twice.
+// Besides it the indexes serve:
+//   - idx_txs_tx_hash: the join of the address txs on (tx_hash, chain, protocol, tick) of GetTransactionsByAddress
+//     and the lookups by hash such as GetTxsByHashes
+//   - idx_txs_chain_block: GetTransactionsByBlock, GetTransactionByPosition & the rollback above a block
+//   - idx_txs_chain_block_time: GetActivityMetrics
 type Transaction struct {
 	ID              uint64          `gorm:"primaryKey" json:"id"`
-	Chain           string          `json:"chain" gorm:"column:chain"`                         // chain name
-	Protocol        string          `json:"protocol" gorm:"column:protocol"`                   // protocol name
-	BlockHeight     uint64          `json:"block_height" gorm:"column:block_height"`           // block height
-	PositionInBlock uint64          `json:"position_in_block" gorm:"column:position_in_block"` // Position in Block
-	BlockTime       time.Time       `json:"block_time" gorm:"column:block_time"`               // block time
-	TxHash          string          `json:"tx_hash" gorm:"column:tx_hash"`                     // tx hash
-	From            string          `json:"from" gorm:"column:from"`                           // from address
-	To              string          `json:"to" gorm:"column:to"`                               // to address
-	Op              string          `json:"op" gorm:"column:op"`                               // op code
-	Tick            string          `json:"tick" gorm:"column:tick"`                           // inscription code
-	Amount          decimal.Decimal `json:"amt" gorm:"column:amt;type:decimal(38,18)"`         // balance
-	Gas             int64           `json:"gas" gorm:"column:gas"`                             // gas
-	GasPrice        int64           `json:"gas_price" gorm:"column:gas_price"`                 // gas price
-	Status          int8            `json:"status" gorm:"column:status"`                       // tx status
+	Chain           string          `json:"chain" gorm:"column:chain;uniqueIndex:uq_txs_chain_tx_hash,priority:1;index:idx_txs_chain_block,priority:1;index:idx_txs_chain_block_time,priority:1;index:idx_txs_tx_hash,priority:2"` // chain name
+	Protocol        string          `json:"protocol" gorm:"column:protocol;index:idx_txs_tx_hash,priority:3"`                                                                                                                      // protocol name
+	BlockHeight     uint64          `json:"block_height" gorm:"column:block_height;index:idx_txs_chain_block,priority:2"`                                                                                                          // block height
+	PositionInBlock uint64          `json:"position_in_block" gorm:"column:position_in_block;index:idx_txs_chain_block,priority:3"`                                                                                                // Position in Block
+	BlockTime       time.Time       `json:"block_time" gorm:"column:block_time;index:idx_txs_chain_block_time,priority:2"`                                                                                                         // block time
+	TxHash          string          `json:"tx_hash" gorm:"column:tx_hash;uniqueIndex:uq_txs_chain_tx_hash,priority:2;index:idx_txs_tx_hash,priority:1"`                                                                            // tx hash
+	From            string          `json:"from" gorm:"column:from"`                                                                                                                                                               // from address
+	To              string          `json:"to" gorm:"column:to"`                                                                                                                                                                   // to address
+	Op              string          `json:"op" gorm:"column:op"`                                                                                                                                                                   // op code
+	Tick            string          `json:"tick" gorm:"column:tick;index:idx_txs_tx_hash,priority:4"`                                                                                                                              // inscription code
+	Amount          decimal.Decimal `json:"amt" gorm:"column:amt;type:decimal(38,18)"`                                                                                                                                             // balance
+	Gas             int64           `json:"gas" gorm:"column:gas"`                                                                                                                                                                 // gas
+	GasPrice        int64           `json:"gas_price" gorm:"column:gas_price"`                                                                                                                                                     // gas price
+	Status          int8            `json:"status" gorm:"column:status"`                                                                                                                                                           // tx status
 	CreatedAt       time.Time       `json:"created_at" gorm:"column:created_at"`
 	UpdatedAt       time.Time       `json:"updated_at" gorm:"column:updated_at"`
 }
@@ -73,7 +73,7 @@ func GetProtocol(cfg *config.Config, tx *xycommon.RpcTransaction) (types.IProtoc
 	}
 }
 
-func GetOperateByTxInput(chain, inputData string, db *storage.DBClient) *devents.MetaData {
+func GetOperateByTxInput(chain, inputData string, db storage.Reader) *devents.MetaData {
 	md, _ := ParseMetaData(chain, &xycommon.RpcTransaction{Input: inputData})
 	return md
 }
@@ -0,0 +1,144 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+// ErrStaleBlockResult is returned by SaveBlockResult for a result whose height is not above the stored one
+var ErrStaleBlockResult = errors.New("stale block result")
+
+// SaveBlockResult applies the rows of the indexed blocks and advances the height of the chain in a single transaction,
+// an error of any step rolls back all of them. The inserts are chunked by the batch size of the client and so are the
+// updates. Balance updates are version checked, see BatchUpdateBalances.
+// The height is advanced through SaveLastBlockMonotonic first, a stale or replayed result is skipped as a whole with
+// ErrStaleBlockResult.
+func (conn *DBClient) SaveBlockResult(result *model.BlockResult) (err error) {
+	defer conn.observe("SaveBlockResult", time.Now(), &err)
+
+	if result == nil || result.BlockStatus == nil {
+		return errors.New("block status is required")
+	}
+
+	chain := result.BlockStatus.Chain
+	size := conn.insertBatchSize()
+	return conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+		applied, err := conn.SaveLastBlockMonotonic(tx, result.BlockStatus)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return fmt.Errorf("%w: chain[%s] block[%d]", ErrStaleBlockResult, chain, result.BlockStatus.BlockNumber)
+		}
+
+		skipped, err := conn.BatchAddInscription(tx, result.Inscriptions)
+		if err != nil {
+			return err
+		}
+		for _, item := range skipped {
+			log.Warn("inscription already deployed & ignore", "chain", item.Chain, "protocol", item.Protocol, "tick", item.Tick)
+		}
+		for _, items := range chunks(result.InscriptionUpdates, size) {
+			if err := conn.BatchUpdateInscription(tx, chain, items); err != nil {
+				return err
+			}
+		}
+
+		if err := conn.BatchAddInscriptionStats(tx, result.InscriptionStats); err != nil {
+			return err
+		}
+		for _, items := range chunks(result.InscriptionStatsUpdates, size) {
+			if err := conn.BatchUpdateInscriptionStats(tx, chain, items); err != nil {
+				return err
+			}
+		}
+		if err := conn.updateInscriptionStatsMint(tx, chain, result.InscriptionStatsUpdates); err != nil {
+			return err
+		}
+
+		if _, err := conn.BatchAddTransaction(tx, result.Txs); err != nil {
+			return err
+		}
+		if err := conn.BatchAddAddressTx(tx, result.AddressTxs); err != nil {
+			return err
+		}
+		if err := conn.BatchAddBalanceTx(tx, result.BalanceTxs); err != nil {
+			return err
+		}
+
+		if err := conn.BatchAddBalances(tx, result.Balances); err != nil {
+			return err
+		}
+		for _, items := range chunks(result.BalanceUpdates, size) {
+			if err := conn.BatchUpdateBalances(tx, chain, items); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// updateInscriptionStatsMint writes the mint progress fields of the stats, BatchUpdateInscriptionStats leaves them out.
+// Only the fields set on the item are written.
+func (conn *DBClient) updateInscriptionStatsMint(tx *gorm.DB, chain string, items []*model.InscriptionsStats) error {
+	for _, item := range items {
+		updates := make(map[string]interface{})
+		if item.MintFirstBlock > 0 {
+			updates["mint_first_block"] = item.MintFirstBlock
+		}
+		if item.MintLastBlock > 0 {
+			updates["mint_last_block"] = item.MintLastBlock
+		}
+		if item.MintCompletedTime != nil {
+			updates["mint_completed_time"] = item.MintCompletedTime
+		}
+		if len(updates) < 1 {
+			continue
+		}
+
+		if err := conn.UpdateInscriptionsStatsBySID(tx, chain, item.SID, updates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunks splits the items into slices of at most size items
+func chunks[T any](items []T, size int) [][]T {
+	ret := make([][]T, 0, (len(items)+size-1)/size)
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		ret = append(ret, items[start:end])
+	}
+	return ret
+}
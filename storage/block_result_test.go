@@ -0,0 +1,145 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestSaveBlockResult(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.NewFromInt(100)
+
+	// the deploy block
+	completed := time.Now()
+	err := conn.SaveBlockResult(&model.BlockResult{
+		Inscriptions:     []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount, DeployHash: "0xd1"}},
+		InscriptionStats: []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick}},
+		Txs:              []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xd1", BlockHeight: 1, Op: "deploy"}},
+		BlockStatus:      &model.BlockStatus{Chain: chain, BlockNumber: 1, BlockHash: "0xb1"},
+	})
+	assert.Nil(t, err)
+
+	// the mint block
+	err = conn.SaveBlockResult(&model.BlockResult{
+		InscriptionStatsUpdates: []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount,
+			Holders: 1, TxCnt: 2, MintFirstBlock: 2, MintLastBlock: 2, MintCompletedTime: &completed}},
+		Balances: []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount, Available: amount}},
+		Txs:      []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", BlockHeight: 2, Op: "mint"}},
+		AddressTxs: []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", Address: "0xa", Amount: amount,
+			Event: model.TransactionEventMint}},
+		BalanceTxs: []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", Address: "0xa", Amount: amount,
+			Balance: amount, Available: amount, Event: model.TransactionEventMint}},
+		BlockStatus: &model.BlockStatus{Chain: chain, BlockNumber: 2, BlockHash: "0xb2"},
+	})
+	assert.Nil(t, err)
+
+	stats, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.True(t, stats.Minted.Equal(amount), stats.Minted.String())
+	assert.Equal(t, uint64(2), stats.MintLastBlock)
+	assert.NotNil(t, stats.MintCompletedTime)
+
+	height, err := conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), height.Int64())
+
+	// a replayed or older result is skipped, the height does not move back
+	for _, number := range []uint64{2, 1} {
+		err = conn.SaveBlockResult(&model.BlockResult{
+			Txs:         []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xr", BlockHeight: number, Op: "transfer"}},
+			BlockStatus: &model.BlockStatus{Chain: chain, BlockNumber: number, BlockHash: "0xr"},
+		})
+		assert.ErrorIs(t, err, ErrStaleBlockResult)
+	}
+	status, err := conn.GetBlockStatus(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, "0xb2", status.BlockHash)
+	tx, err := conn.FindTransaction(chain, "0xr")
+	assert.Nil(t, err)
+	assert.Nil(t, tx)
+
+	// the stale balance update fails after the txs were written, none of the block is kept
+	err = conn.SaveBlockResult(&model.BlockResult{
+		InscriptionStatsUpdates: []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount,
+			Holders: 2, TxCnt: 3}},
+		Balances: []*model.Balances{{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xb", Balance: amount, Available: amount}},
+		BalanceUpdates: []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Version: 5,
+			Balance: decimal.Zero, Available: decimal.Zero}},
+		Txs:         []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xt3", BlockHeight: 3, Op: "transfer"}},
+		BlockStatus: &model.BlockStatus{Chain: chain, BlockNumber: 3, BlockHash: "0xb3"},
+	})
+	assert.True(t, errors.Is(err, ErrBalanceVersionConflict), err)
+
+	height, err = conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), height.Int64())
+
+	tx, err = conn.FindTransaction(chain, "0xt3")
+	assert.Nil(t, err)
+	assert.Nil(t, tx)
+	balance, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xb")
+	assert.Nil(t, err)
+	assert.Nil(t, balance)
+	stats, err = conn.FindInscriptionStatsByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), stats.Holders)
+
+	assert.NotNil(t, conn.SaveBlockResult(&model.BlockResult{}))
+}
+
+func TestSaveBlockResultChunks(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{Type: DatabaseTypeSqlite3, Dsn: t.TempDir() + "/indexer.db", BatchSize: 2})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+	chain, protocol := "avalanche", "asc-20"
+
+	balances := make([]*model.Balances, 0, 5)
+	for i := 1; i <= 5; i++ {
+		balances = append(balances, &model.Balances{SID: uint64(i), Chain: chain, Protocol: protocol, Tick: "tick", Address: string(rune('a' + i))})
+	}
+	block := &model.BlockStatus{Chain: chain, BlockNumber: 1}
+	assert.Nil(t, conn.SaveBlockResult(&model.BlockResult{Balances: balances, BlockStatus: block}))
+
+	for _, item := range balances {
+		item.Balance = decimal.NewFromInt(int64(item.SID))
+	}
+	block = &model.BlockStatus{Chain: chain, BlockNumber: 2}
+	assert.Nil(t, conn.SaveBlockResult(&model.BlockResult{BalanceUpdates: balances, BlockStatus: block}))
+
+	holders, total, err := conn.GetHoldersByTick(10, 0, chain, protocol, "tick", "", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), total)
+	for _, holder := range holders {
+		assert.True(t, holder.Balance.Equal(decimal.NewFromInt(int64(holder.SID))), holder.Balance.String())
+		assert.Equal(t, uint(1), holder.Version)
+	}
+}
@@ -0,0 +1,518 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+// DefaultCacheSize the entries of each cache of CachedDBClient when no size is given
+const DefaultCacheSize = 10000
+
+// inscriptionKey the cache key of an inscription and its stats
+type inscriptionKey struct {
+	Chain    string
+	Protocol string
+	Tick     string
+}
+
+// protocolKey the cache key of the aggregations of a protocol
+type protocolKey struct {
+	Chain    string
+	Protocol string
+}
+
+// richListKey the cache key of a page of the rich list
+type richListKey struct {
+	protocolKey
+	Limit  int
+	Offset int
+}
+
+// pendingKey the context key of the invalidations of the writes of a transaction
+type pendingKey struct{}
+
+// pendingInvalidations the invalidations to run again once the transaction ended
+type pendingInvalidations struct {
+	mu    sync.Mutex
+	funcs []func()
+}
+
+// CachedDBClient wraps a DBClient with read-through LRU caches for FindInscriptionByTick, FindInscriptionStatsByTick,
+// GetIndexedChains and GetIndexedProtocols, the other methods are the ones of the DBClient. The writes going through the CachedDBClient invalidate the entries
+// they touch, writes through the wrapped DBClient itself are not seen by the caches.
+// The entries are invalidated when the write is issued and, for the writes in a transaction of RunInTx or WithRetryTx,
+// once more after the transaction ended, so a lookup racing the transaction does not keep the rows from before its
+// commit. Missing rows are not cached.
+// GetProtocolSummary and GetRichList are cached only once SetAggregationTTL enabled it, see there.
+type CachedDBClient struct {
+	*DBClient
+
+	inscriptions *lruCache[inscriptionKey, *model.Inscriptions]
+	stats        *lruCache[inscriptionKey, *model.InscriptionsStats]
+	indexed      *lruCache[string, []string] // the chains under the empty key & the protocols under the key of their chain
+	summaries    *lruCache[protocolKey, *model.ProtocolSummary]
+	richLists    *lruCache[richListKey, []*model.AddressHolding]
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+}
+
+// NewCachedDBClient wraps the client with caches of size entries each, DefaultCacheSize when size < 1
+func NewCachedDBClient(conn *DBClient, size int) *CachedDBClient {
+	if size < 1 {
+		size = DefaultCacheSize
+	}
+	return &CachedDBClient{
+		DBClient:     conn,
+		inscriptions: newLruCache[inscriptionKey, *model.Inscriptions](size),
+		stats:        newLruCache[inscriptionKey, *model.InscriptionsStats](size),
+		indexed:      newLruCache[string, []string](size),
+	}
+}
+
+// SetAggregationTTL caches the results of GetProtocolSummary and GetRichList for ttl, the writes do not invalidate them.
+// The cached indexed chains and protocols expire after ttl as well. Invalidate drops them all, e.g. after the commit
+// of a block. A zero ttl disables the caching of the aggregations and the expiry of the indexed lists.
+// It must be called before the client is shared.
+func (c *CachedDBClient) SetAggregationTTL(ttl time.Duration) {
+	size := c.indexed.size
+	c.indexed = newTtlCache[string, []string](size, ttl)
+	if ttl <= 0 {
+		c.summaries, c.richLists = nil, nil
+		return
+	}
+	c.summaries = newTtlCache[protocolKey, *model.ProtocolSummary](size, ttl)
+	c.richLists = newTtlCache[richListKey, []*model.AddressHolding](size, ttl)
+}
+
+// Invalidate removes the cached aggregations and indexed lists
+func (c *CachedDBClient) Invalidate() {
+	c.indexed.clear()
+	if c.summaries != nil {
+		c.summaries.clear()
+	}
+	if c.richLists != nil {
+		c.richLists.clear()
+	}
+}
+
+// CacheStats returns the number of the lookups served by the caches and of the ones that went to the database
+func (c *CachedDBClient) CacheStats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// record counts the lookup of the cache
+func (c *CachedDBClient) record(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		c.hits.Add(1)
+		result = "hit"
+	} else {
+		c.misses.Add(1)
+	}
+
+	if c.metricsEnabled {
+		cacheLookups.WithLabelValues(cache, result).Inc()
+	}
+}
+
+func (c *CachedDBClient) FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error) {
+	ctx, cancel := c.queryContext(queryFast)
+	defer cancel()
+	return c.FindInscriptionByTickContext(ctx, chain, protocol, tick)
+}
+
+// FindInscriptionByTickContext is the context aware variant of FindInscriptionByTick.
+func (c *CachedDBClient) FindInscriptionByTickContext(ctx context.Context, chain, protocol, tick string) (*model.Inscriptions, error) {
+	key := inscriptionKey{Chain: chain, Protocol: protocol, Tick: tick}
+	if ins, ok := c.inscriptions.get(key); ok {
+		c.record("inscriptions", true)
+		cp := *ins
+		return &cp, nil
+	}
+	c.record("inscriptions", false)
+
+	ins, err := c.DBClient.FindInscriptionByTickContext(ctx, chain, protocol, tick)
+	if err != nil || ins == nil {
+		return ins, err
+	}
+	cp := *ins
+	c.inscriptions.add(key, &cp)
+	return ins, nil
+}
+
+func (c *CachedDBClient) FindInscriptionStatsByTick(chain, protocol, tick string) (*model.InscriptionsStats, error) {
+	ctx, cancel := c.queryContext(queryFast)
+	defer cancel()
+	return c.FindInscriptionStatsByTickContext(ctx, chain, protocol, tick)
+}
+
+// FindInscriptionStatsByTickContext is the context aware variant of FindInscriptionStatsByTick.
+func (c *CachedDBClient) FindInscriptionStatsByTickContext(ctx context.Context, chain, protocol, tick string) (*model.InscriptionsStats, error) {
+	key := inscriptionKey{Chain: chain, Protocol: protocol, Tick: tick}
+	if stats, ok := c.stats.get(key); ok {
+		c.record("inscriptions_stats", true)
+		cp := *stats
+		return &cp, nil
+	}
+	c.record("inscriptions_stats", false)
+
+	stats, err := c.DBClient.FindInscriptionStatsByTickContext(ctx, chain, protocol, tick)
+	if err != nil || stats == nil {
+		return stats, err
+	}
+	cp := *stats
+	c.stats.add(key, &cp)
+	return stats, nil
+}
+
+func (c *CachedDBClient) GetIndexedChains() ([]string, error) {
+	ctx, cancel := c.queryContext(queryFast)
+	defer cancel()
+	return c.GetIndexedChainsContext(ctx)
+}
+
+// GetIndexedChainsContext is the context aware variant of GetIndexedChains.
+func (c *CachedDBClient) GetIndexedChainsContext(ctx context.Context) ([]string, error) {
+	return c.indexedList(ctx, "", c.DBClient.GetIndexedChainsContext)
+}
+
+func (c *CachedDBClient) GetIndexedProtocols(chain string) ([]string, error) {
+	ctx, cancel := c.queryContext(queryFast)
+	defer cancel()
+	return c.GetIndexedProtocolsContext(ctx, chain)
+}
+
+// GetIndexedProtocolsContext is the context aware variant of GetIndexedProtocols.
+func (c *CachedDBClient) GetIndexedProtocolsContext(ctx context.Context, chain string) ([]string, error) {
+	if chain == "" {
+		return c.DBClient.GetIndexedProtocolsContext(ctx, chain)
+	}
+	return c.indexedList(ctx, chain, func(ctx context.Context) ([]string, error) {
+		return c.DBClient.GetIndexedProtocolsContext(ctx, chain)
+	})
+}
+
+func (c *CachedDBClient) GetProtocolSummary(chain, protocol string) (*model.ProtocolSummary, error) {
+	ctx, cancel := c.queryContext(queryAnalytical)
+	defer cancel()
+	return c.GetProtocolSummaryContext(ctx, chain, protocol)
+}
+
+// GetProtocolSummaryContext is the context aware variant of GetProtocolSummary.
+func (c *CachedDBClient) GetProtocolSummaryContext(ctx context.Context, chain, protocol string) (*model.ProtocolSummary, error) {
+	if c.summaries == nil {
+		return c.DBClient.GetProtocolSummaryContext(ctx, chain, protocol)
+	}
+
+	key := protocolKey{Chain: chain, Protocol: protocol}
+	if summary, ok := c.summaries.get(key); ok {
+		c.record("protocol_summary", true)
+		cp := *summary
+		return &cp, nil
+	}
+	c.record("protocol_summary", false)
+
+	summary, err := c.DBClient.GetProtocolSummaryContext(ctx, chain, protocol)
+	if err != nil {
+		return nil, err
+	}
+	cp := *summary
+	c.summaries.add(key, &cp)
+	return summary, nil
+}
+
+func (c *CachedDBClient) GetRichList(chain, protocol string, limit, offset int) ([]*model.AddressHolding, error) {
+	ctx, cancel := c.queryContext(queryAnalytical)
+	defer cancel()
+	return c.GetRichListContext(ctx, chain, protocol, limit, offset)
+}
+
+// GetRichListContext is the context aware variant of GetRichList.
+func (c *CachedDBClient) GetRichListContext(ctx context.Context, chain, protocol string, limit, offset int) ([]*model.AddressHolding, error) {
+	if c.richLists == nil {
+		return c.DBClient.GetRichListContext(ctx, chain, protocol, limit, offset)
+	}
+
+	key := richListKey{protocolKey: protocolKey{Chain: chain, Protocol: protocol}, Limit: limit, Offset: offset}
+	if holdings, ok := c.richLists.get(key); ok {
+		c.record("rich_list", true)
+		return copyHoldings(holdings), nil
+	}
+	c.record("rich_list", false)
+
+	holdings, err := c.DBClient.GetRichListContext(ctx, chain, protocol, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	c.richLists.add(key, copyHoldings(holdings))
+	return holdings, nil
+}
+
+func copyHoldings(holdings []*model.AddressHolding) []*model.AddressHolding {
+	cp := make([]*model.AddressHolding, 0, len(holdings))
+	for _, holding := range holdings {
+		item := *holding
+		cp = append(cp, &item)
+	}
+	return cp
+}
+
+// indexedList serves the list of the key from the cache, loading it on a miss. Empty lists are not cached.
+func (c *CachedDBClient) indexedList(ctx context.Context, key string, load func(ctx context.Context) ([]string, error)) ([]string, error) {
+	if list, ok := c.indexed.get(key); ok {
+		c.record("indexed", true)
+		return append([]string(nil), list...), nil
+	}
+	c.record("indexed", false)
+
+	list, err := load(ctx)
+	if err != nil || len(list) < 1 {
+		return list, err
+	}
+	c.indexed.add(key, append([]string(nil), list...))
+	return list, nil
+}
+
+// invalidateIndexed removes the cached chains and the cached protocols of the chains
+func (c *CachedDBClient) invalidateIndexed(chains ...string) {
+	if len(chains) < 1 {
+		return
+	}
+	c.indexed.remove("")
+	for _, chain := range chains {
+		c.indexed.remove(chain)
+	}
+}
+
+// invalidateInscriptions removes the cached inscriptions of the chain with the sids
+func (c *CachedDBClient) invalidateInscriptions(chain string, sids map[uint32]bool) {
+	if len(sids) < 1 {
+		return
+	}
+	c.inscriptions.removeFunc(func(key inscriptionKey, value *model.Inscriptions) bool {
+		return key.Chain == chain && sids[value.SID]
+	})
+}
+
+// invalidateStats removes the cached stats of the chain with the sids
+func (c *CachedDBClient) invalidateStats(chain string, sids map[uint32]bool) {
+	if len(sids) < 1 {
+		return
+	}
+	c.stats.removeFunc(func(key inscriptionKey, value *model.InscriptionsStats) bool {
+		return key.Chain == chain && sids[value.SID]
+	})
+}
+
+// invalidateChain removes all the cached entries of the chain
+func (c *CachedDBClient) invalidateChain(chain string) {
+	c.inscriptions.removeFunc(func(key inscriptionKey, _ *model.Inscriptions) bool {
+		return key.Chain == chain
+	})
+	c.stats.removeFunc(func(key inscriptionKey, _ *model.InscriptionsStats) bool {
+		return key.Chain == chain
+	})
+	c.invalidateIndexed(chain)
+}
+
+func inscriptionChains(items []*model.Inscriptions) []string {
+	chains := make([]string, 0, 1)
+	for _, item := range items {
+		chains = append(chains, item.Chain)
+	}
+	return chains
+}
+
+func inscriptionSids(items []*model.Inscriptions) map[uint32]bool {
+	sids := make(map[uint32]bool, len(items))
+	for _, item := range items {
+		sids[item.SID] = true
+	}
+	return sids
+}
+
+func statsSids(items []*model.InscriptionsStats) map[uint32]bool {
+	sids := make(map[uint32]bool, len(items))
+	for _, item := range items {
+		sids[item.SID] = true
+	}
+	return sids
+}
+
+// withPending returns the context collecting the invalidations of the writes of a transaction & the func running them
+// once the transaction ended. A context collecting already is kept, the outermost transaction runs them.
+func withPending(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(pendingKey{}).(*pendingInvalidations); ok {
+		return ctx, func() {}
+	}
+
+	pending := &pendingInvalidations{}
+	return context.WithValue(ctx, pendingKey{}, pending), func() {
+		pending.mu.Lock()
+		funcs := pending.funcs
+		pending.funcs = nil
+		pending.mu.Unlock()
+
+		for _, invalidate := range funcs {
+			invalidate()
+		}
+	}
+}
+
+// afterCommit runs the invalidation of a write on dbTx, again after the transaction ended when dbTx is one of
+// RunInTx or WithRetryTx
+func (c *CachedDBClient) afterCommit(dbTx *gorm.DB, invalidate func()) {
+	invalidate()
+	if dbTx == nil || dbTx.Statement == nil || dbTx.Statement.Context == nil {
+		return
+	}
+	if pending, ok := dbTx.Statement.Context.Value(pendingKey{}).(*pendingInvalidations); ok {
+		pending.mu.Lock()
+		pending.funcs = append(pending.funcs, invalidate)
+		pending.mu.Unlock()
+	}
+}
+
+// RunInTx runs fn inside a transaction of the primary like DBClient.RunInTx, the writes of the store go through the
+// CachedDBClient and invalidate the entries they touch.
+func (c *CachedDBClient) RunInTx(ctx context.Context, fn func(store TxStore) error) error {
+	ctx, done := withPending(ctx)
+	defer done()
+	return c.SqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&txStore{conn: c, tx: tx})
+	})
+}
+
+// WithRetryTx is DBClient.WithRetryTx invalidating the entries touched by the writes of fn once more when it ended
+func (c *CachedDBClient) WithRetryTx(fn func(tx *gorm.DB) error, maxRetries int) error {
+	ctx, done := withPending(context.Background())
+	defer done()
+	return c.DBClient.withRetryTx(ctx, fn, maxRetries)
+}
+
+func (c *CachedDBClient) BatchAddInscription(dbTx *gorm.DB, ins []*model.Inscriptions) ([]*model.Inscriptions, error) {
+	chains := inscriptionChains(ins)
+	defer c.afterCommit(dbTx, func() { c.invalidateIndexed(chains...) })
+	return c.DBClient.BatchAddInscription(dbTx, ins)
+}
+
+func (c *CachedDBClient) BatchUpdateInscription(dbTx *gorm.DB, chain string, items []*model.Inscriptions) error {
+	sids := inscriptionSids(items)
+	defer c.afterCommit(dbTx, func() { c.invalidateInscriptions(chain, sids) })
+	return c.DBClient.BatchUpdateInscription(dbTx, chain, items)
+}
+
+func (c *CachedDBClient) SoftDeleteInscription(dbTx *gorm.DB, chain, protocol, tick string) error {
+	defer c.afterCommit(dbTx, func() {
+		c.inscriptions.remove(inscriptionKey{Chain: chain, Protocol: protocol, Tick: tick})
+		c.invalidateIndexed(chain)
+	})
+	return c.DBClient.SoftDeleteInscription(dbTx, chain, protocol, tick)
+}
+
+func (c *CachedDBClient) RestoreInscription(dbTx *gorm.DB, chain, protocol, tick string) error {
+	defer c.afterCommit(dbTx, func() { c.invalidateIndexed(chain) })
+	return c.DBClient.RestoreInscription(dbTx, chain, protocol, tick)
+}
+
+func (c *CachedDBClient) BatchUpdatesBySID(dbTx *gorm.DB, chain string, tblName string, fields map[string]string,
+	values []map[string]interface{}) (error, int64) {
+	sids := make(map[uint32]bool, len(values))
+	for _, value := range values {
+		switch sid := value["sid"].(type) {
+		case uint32:
+			sids[sid] = true
+		case uint64:
+			sids[uint32(sid)] = true
+		case int:
+			sids[uint32(sid)] = true
+		}
+	}
+	defer c.afterCommit(dbTx, func() {
+		switch tblName {
+		case model.Inscriptions{}.TableName():
+			c.invalidateInscriptions(chain, sids)
+		case model.InscriptionsStats{}.TableName():
+			c.invalidateStats(chain, sids)
+		}
+	})
+	return c.DBClient.BatchUpdatesBySID(dbTx, chain, tblName, fields, values)
+}
+
+func (c *CachedDBClient) BatchUpdateInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) error {
+	sids := statsSids(items)
+	defer c.afterCommit(dbTx, func() { c.invalidateStats(chain, sids) })
+	return c.DBClient.BatchUpdateInscriptionStats(dbTx, chain, items)
+}
+
+// BatchUpsertInscriptionStats invalidates the stats by the conflict key of the upsert, an item may not carry the sid of
+// the row it updates
+func (c *CachedDBClient) BatchUpsertInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) error {
+	keys := make([]inscriptionKey, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, inscriptionKey{Chain: item.Chain, Protocol: item.Protocol, Tick: item.Tick})
+	}
+	defer c.afterCommit(dbTx, func() {
+		for _, key := range keys {
+			c.stats.remove(key)
+		}
+	})
+	return c.DBClient.BatchUpsertInscriptionStats(dbTx, chain, items)
+}
+
+func (c *CachedDBClient) UpdateInscriptionsStatsBySID(dbTx *gorm.DB, chain string, id uint32, updates map[string]interface{}) error {
+	defer c.afterCommit(dbTx, func() { c.invalidateStats(chain, map[uint32]bool{id: true}) })
+	return c.DBClient.UpdateInscriptionsStatsBySID(dbTx, chain, id, updates)
+}
+
+func (c *CachedDBClient) RecalculateHolders(dbTx *gorm.DB, chain, protocol, tick string) (int64, error) {
+	defer c.afterCommit(dbTx, func() { c.stats.remove(inscriptionKey{Chain: chain, Protocol: protocol, Tick: tick}) })
+	return c.DBClient.RecalculateHolders(dbTx, chain, protocol, tick)
+}
+
+func (c *CachedDBClient) SaveBlockResult(result *model.BlockResult) error {
+	if result != nil && result.BlockStatus != nil {
+		chain := result.BlockStatus.Chain
+		defer c.invalidateIndexed(inscriptionChains(result.Inscriptions)...)
+		defer c.invalidateInscriptions(chain, inscriptionSids(result.InscriptionUpdates))
+		defer c.invalidateStats(chain, statsSids(result.InscriptionStatsUpdates))
+	}
+	return c.DBClient.SaveBlockResult(result)
+}
+
+func (c *CachedDBClient) DeleteDataAboveBlock(dbTx *gorm.DB, chain string, blockNumber uint64) error {
+	defer c.afterCommit(dbTx, func() { c.invalidateChain(chain) })
+	return c.DBClient.DeleteDataAboveBlock(dbTx, chain, blockNumber)
+}
+
+func (c *CachedDBClient) PurgeChainData(dbTx *gorm.DB, chain string) (map[string]int64, error) {
+	defer c.afterCommit(dbTx, func() { c.invalidateChain(chain) })
+	return c.DBClient.PurgeChainData(dbTx, chain)
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+// countQueries counts the SELECT queries run by the client
+func countQueries(t *testing.T, conn *DBClient) *atomic.Int64 {
+	cnt := new(atomic.Int64)
+	err := conn.SqlDB.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) {
+		cnt.Add(1)
+	})
+	assert.Nil(t, err)
+	return cnt
+}
+
+func TestCachedDBClient(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	for i, tick := range []string{"a", "b"} {
+		addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: tick}})
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: tick, Holders: 1}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	cached := NewCachedDBClient(conn, 10)
+	queries := countQueries(t, conn)
+
+	// the second lookup is served by the cache
+	ins, err := cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), ins.SID)
+	ins.TransferType = 9 // the callers get copies
+	ins, err = cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, int8(0), ins.TransferType)
+	assert.Equal(t, int64(1), queries.Load())
+	hits, misses := cached.CacheStats()
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(1), misses)
+
+	// missing rows are not cached
+	for i := 0; i < 2; i++ {
+		ins, err = cached.FindInscriptionByTick(chain, protocol, "none")
+		assert.Nil(t, err)
+		assert.Nil(t, ins)
+	}
+	assert.Equal(t, int64(3), queries.Load())
+
+	// an update invalidates the entry
+	assert.Nil(t, cached.BatchUpdateInscription(conn.SqlDB, chain, []*model.Inscriptions{{SID: 1, TransferType: model.TransferTypeBalance}}))
+	queries.Store(0)
+	ins, err = cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, int8(model.TransferTypeBalance), ins.TransferType)
+	assert.Equal(t, int64(1), queries.Load())
+
+	assert.Nil(t, cached.SoftDeleteInscription(conn.SqlDB, chain, protocol, "a"))
+	ins, err = cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Nil(t, ins)
+
+	// stats
+	queries.Store(0)
+	for i := 0; i < 2; i++ {
+		stats, err := cached.FindInscriptionStatsByTick(chain, protocol, "b")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), stats.Holders)
+	}
+	assert.Equal(t, int64(1), queries.Load())
+
+	updates := []*model.InscriptionsStats{{SID: 2, Minted: decimal.NewFromInt(10), Holders: 3}}
+	assert.Nil(t, cached.BatchUpdateInscriptionStats(conn.SqlDB, chain, updates))
+	stats, err := cached.FindInscriptionStatsByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), stats.Holders)
+
+	assert.Nil(t, cached.UpdateInscriptionsStatsBySID(conn.SqlDB, chain, 2, map[string]interface{}{"mint_last_block": 7}))
+	stats, err = cached.FindInscriptionStatsByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), stats.MintLastBlock)
+
+	holders, err := cached.RecalculateHolders(conn.SqlDB, chain, protocol, "b")
+	assert.Nil(t, err)
+	stats, err = cached.FindInscriptionStatsByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(holders), stats.Holders)
+
+	// the other chains keep their entries
+	assert.Nil(t, cached.DeleteDataAboveBlock(conn.SqlDB, "bsc", 0))
+	assert.Equal(t, 1, cached.stats.len())
+	assert.Nil(t, cached.DeleteDataAboveBlock(conn.SqlDB, chain, 0))
+	assert.Equal(t, 0, cached.stats.len())
+	assert.Equal(t, 0, cached.inscriptions.len())
+}
+
+func TestCachedDBClientConcurrent(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a"}})
+	cached := NewCachedDBClient(conn, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ins, err := cached.FindInscriptionByTick(chain, protocol, "a")
+				assert.Nil(t, err)
+				assert.Equal(t, "a", ins.Tick)
+			}
+		}()
+	}
+	wg.Wait()
+
+	hits, misses := cached.CacheStats()
+	assert.Equal(t, uint64(400), hits+misses)
+	assert.True(t, hits >= 392, "hits %d", hits)
+}
+
+func TestLruCacheEviction(t *testing.T) {
+	cache := newLruCache[string, int](2)
+	cache.add("a", 1)
+	cache.add("b", 2)
+	_, _ = cache.get("a") // b is the least recently used now
+	cache.add("c", 3)
+
+	_, ok := cache.get("b")
+	assert.False(t, ok)
+	value, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	value, ok = cache.get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+
+	cache.add("a", 10)
+	value, _ = cache.get("a")
+	assert.Equal(t, 10, value)
+	assert.Equal(t, 2, cache.len())
+
+	cache.remove("a")
+	cache.removeFunc(func(key string, value int) bool { return value == 3 })
+	assert.Equal(t, 0, cache.len())
+}
+
+func TestLruCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := newTtlCache[string, int](2, time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.add("a", 1)
+
+	now = now.Add(59 * time.Second)
+	value, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	now = now.Add(time.Second)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.len())
+
+	cache.add("b", 2)
+	cache.clear()
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+}
+
+func TestCachedDBClientAggregationTTL(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a"}})
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x1", Balance: decimal.NewFromInt(100)}}))
+
+	cached := NewCachedDBClient(conn, 10)
+
+	// not cached until enabled
+	for i := 0; i < 2; i++ {
+		_, err := cached.GetProtocolSummary(chain, protocol)
+		assert.Nil(t, err)
+	}
+	hits, misses := cached.CacheStats()
+	assert.Equal(t, uint64(0), hits+misses)
+
+	now := time.Now()
+	cached.SetAggregationTTL(time.Minute)
+	for _, clock := range []*func() time.Time{&cached.indexed.now, &cached.summaries.now, &cached.richLists.now} {
+		*clock = func() time.Time { return now }
+	}
+
+	lookup := func() (*model.ProtocolSummary, []*model.AddressHolding, []string) {
+		summary, err := cached.GetProtocolSummary(chain, protocol)
+		assert.Nil(t, err)
+		holdings, err := cached.GetRichList(chain, protocol, 10, 0)
+		assert.Nil(t, err)
+		chains, err := cached.GetIndexedChains()
+		assert.Nil(t, err)
+		return summary, holdings, chains
+	}
+	summary, holdings, chains := lookup()
+	assert.Equal(t, int64(1), summary.Ticks)
+	assert.Equal(t, 1, len(holdings))
+	assert.Equal(t, []string{chain}, chains)
+	holdings[0].Address = "0xchanged" // the callers get copies
+
+	// the writes through the wrapped client are not seen within the ttl
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 2, Chain: "btc", Protocol: protocol, Tick: "b"}})
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, []*model.Balances{
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x2", Balance: decimal.NewFromInt(50)}}))
+	now = now.Add(30 * time.Second)
+	summary, holdings, chains = lookup()
+	assert.Equal(t, int64(1), summary.Ticks)
+	assert.Equal(t, 1, len(holdings))
+	assert.Equal(t, "0x1", holdings[0].Address)
+	assert.Equal(t, []string{chain}, chains)
+	hits, misses = cached.CacheStats()
+	assert.Equal(t, uint64(3), hits)
+	assert.Equal(t, uint64(3), misses)
+
+	// expired
+	now = now.Add(30 * time.Second)
+	_, holdings, chains = lookup()
+	assert.Equal(t, 2, len(holdings))
+	assert.Equal(t, []string{chain, "btc"}, chains)
+	hits, misses = cached.CacheStats()
+	assert.Equal(t, uint64(3), hits)
+	assert.Equal(t, uint64(6), misses)
+
+	// invalidated by hand
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 3, Chain: chain, Protocol: protocol, Tick: "c"}})
+	cached.Invalidate()
+	summary, _, _ = lookup()
+	assert.Equal(t, int64(2), summary.Ticks)
+	_, misses = cached.CacheStats()
+	assert.Equal(t, uint64(9), misses)
+}
+
+func TestCachedDBClientIndexed(t *testing.T) {
+	conn := newTestClient(t)
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "a"}})
+
+	cached := NewCachedDBClient(conn, 10)
+	queries := countQueries(t, conn)
+
+	for i := 0; i < 2; i++ {
+		chains, err := cached.GetIndexedChains()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"avalanche"}, chains)
+		protocols, err := cached.GetIndexedProtocols("avalanche")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"asc-20"}, protocols)
+	}
+	assert.Equal(t, int64(2), queries.Load())
+
+	// a deploy invalidates the lists of its chain
+	_, err := cached.BatchAddInscription(conn.SqlDB, []*model.Inscriptions{{SID: 2, Chain: "avalanche", Protocol: "asc-721", Tick: "a"}})
+	assert.Nil(t, err)
+	protocols, err := cached.GetIndexedProtocols("avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"asc-20", "asc-721"}, protocols)
+
+	_, err = cached.BatchAddInscription(conn.SqlDB, []*model.Inscriptions{{SID: 3, Chain: "btc", Protocol: "brc-20", Tick: "a"}})
+	assert.Nil(t, err)
+	chains, err := cached.GetIndexedChains()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"avalanche", "btc"}, chains)
+
+	// purging the chain drops it from the lists
+	_, err = cached.PurgeChainData(conn.SqlDB, "btc")
+	assert.Nil(t, err)
+	chains, err = cached.GetIndexedChains()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"avalanche"}, chains)
+	protocols, err = cached.GetIndexedProtocols("btc")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, protocols)
+}
+
+func TestCachedDBClientRunInTx(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a"}})
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Holders: 1}}))
+
+	cached := NewCachedDBClient(conn, 10)
+	_, err := cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	_, err = cached.FindInscriptionStatsByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+
+	// the writes of the store invalidate the cached entries
+	err = cached.RunInTx(context.Background(), func(store TxStore) error {
+		if err := store.BatchUpdateInscription(chain, []*model.Inscriptions{{SID: 1, TransferType: model.TransferTypeBalance}}); err != nil {
+			return err
+		}
+		return store.BatchUpdateInscriptionStats(chain, []*model.InscriptionsStats{{SID: 1, Holders: 3}})
+	})
+	assert.Nil(t, err)
+
+	ins, err := cached.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, int8(model.TransferTypeBalance), ins.TransferType)
+	stats, err := cached.FindInscriptionStatsByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), stats.Holders)
+}
+
+func TestCachedDBClientInvalidateAfterCommit(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Holders: 1}}))
+
+	cached := NewCachedDBClient(conn, 10)
+	// lookup runs outside of the transaction after the update, it caches the row from before the commit
+	lookup := func(holders uint64) {
+		stats, err := cached.FindInscriptionStatsByTick(chain, protocol, "a")
+		assert.Nil(t, err)
+		assert.Equal(t, holders, stats.Holders)
+	}
+
+	err := cached.WithRetryTx(func(tx *gorm.DB) error {
+		if err := cached.BatchUpdateInscriptionStats(tx, chain, []*model.InscriptionsStats{{SID: 1, Holders: 2}}); err != nil {
+			return err
+		}
+		lookup(1)
+		return nil
+	}, 0)
+	assert.Nil(t, err)
+	lookup(2)
+
+	err = cached.RunInTx(context.Background(), func(store TxStore) error {
+		if err := store.BatchUpdateInscriptionStats(chain, []*model.InscriptionsStats{{SID: 1, Holders: 3}}); err != nil {
+			return err
+		}
+		lookup(2)
+		return nil
+	})
+	assert.Nil(t, err)
+	lookup(3)
+}
+
+func TestCachedDBClientUpsertStats(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Holders: 1}}))
+
+	cached := NewCachedDBClient(conn, 10)
+	for holders, sid := range map[uint64]uint32{2: 0, 3: 9} {
+		_, err := cached.FindInscriptionStatsByTick(chain, protocol, "a")
+		assert.Nil(t, err)
+
+		// the upsert matches the row by its tick, whatever the sid of the item
+		items := []*model.InscriptionsStats{{SID: sid, Chain: chain, Protocol: protocol, Tick: "a", Holders: holders}}
+		assert.Nil(t, cached.BatchUpsertInscriptionStats(conn.SqlDB, chain, items))
+		stats, err := cached.FindInscriptionStatsByTick(chain, protocol, "a")
+		assert.Nil(t, err)
+		assert.Equal(t, holders, stats.Holders)
+	}
+}
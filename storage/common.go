@@ -23,57 +23,348 @@
 package storage
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/shopspring/decimal"
 	"github.com/uxuycom/indexer/config"
 	"github.com/uxuycom/indexer/model"
+	"github.com/uxuycom/indexer/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
+	stdlog "log"
 	"math/big"
+	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	DatabaseTypeSqlite3 = "sqlite3"
-	DatabaseTypeMysql   = "mysql"
+	DatabaseTypeSqlite3  = "sqlite3"
+	DatabaseTypeMysql    = "mysql"
+	DatabaseTypePostgres = "postgres"
 )
 
 const DBSessionLockKey = "db_session_global_lock_tx"
 
+// default connection pool settings
+const (
+	DefaultMaxOpenConns    = 50
+	DefaultMaxIdleConns    = 10
+	DefaultConnMaxLifetime = time.Hour
+	DefaultConnMaxIdleTime = 10 * time.Minute
+)
+
+// DefaultBatchSize the default rows of a single INSERT of the Batch* methods, it keeps the statements below the
+// max_allowed_packet and the 65535 placeholders limits of mysql
+const DefaultBatchSize = 500
+
+// DefaultMaxStatementBytes the default size limit of a single batch UPDATE statement of BatchUpdatesBySID, the default
+// max_allowed_packet of mysql 5.7
+const DefaultMaxStatementBytes = 4 << 20
+
+// ErrStatementTooLarge is returned by BatchUpdatesBySID when the statement of a single row exceeds the size limit
+var ErrStatementTooLarge = errors.New("statement too large")
+
+// findByHashesChunkSize the max hashes (or sids) of a single IN query
+const findByHashesChunkSize = 500
+
 const (
 	OrderByModeAsc  = 1
 	OrderByModeDesc = 2
 )
 
+// SortField the sort field of the inscriptions listings
+type SortField int
+
+const (
+	SortById            SortField = 0
+	SortByDeployTime    SortField = 1
+	SortByProgress      SortField = 2
+	SortByHolders       SortField = 3
+	SortByTxCnt         SortField = 4
+	SortByMinted        SortField = 5
+	SortByDeployTimeAsc SortField = 6 // the oldest deploys first, whatever the sort mode
+)
+
+// the sort types of the former int sort argument, untyped so they still pass as an int or a SortField
 const (
-	SortTypeId         = 0
+	// Deprecated: use SortById.
+	SortTypeId = 0
+	// Deprecated: use SortByDeployTime.
 	SortTypeDeployTime = 1
-	SortTpyeProgress   = 2
-	SortTypeHolders    = 3
-	SortTypeTxCnt      = 4
+	// Deprecated: use SortByProgress.
+	SortTpyeProgress = 2
+	// Deprecated: use SortByHolders.
+	SortTypeHolders = 3
+	// Deprecated: use SortByTxCnt.
+	SortTypeTxCnt = 4
+)
+
+// TxSort the sort of the address transactions listings
+type TxSort int
+
+const (
+	TxSortNewest     TxSort = 0 // the latest address txs first
+	TxSortOldest     TxSort = 1 // the earliest address txs first
+	TxSortAmountDesc TxSort = 2 // the largest tx amounts first
+)
+
+// mint status filters of GetInscriptions
+const (
+	MintStatusAll       = 0
+	MintStatusMinting   = 1
+	MintStatusCompleted = 2
 )
 
+// DBClient wraps the gorm db of the indexer. Read methods have a XxxContext variant taking a context.Context,
+// the plain variant runs with the fast or analytical deadline of the method, see SetQueryTimeouts.
 type DBClient struct {
 	SqlDB *gorm.DB
+
+	metricsEnabled    bool         // record the query metrics, see RegisterMetrics
+	batchSize         int          // rows of a single INSERT of the Batch* methods, 0 for DefaultBatchSize
+	maxStatementBytes int          // size of a single BatchUpdatesBySID statement, 0 for DefaultMaxStatementBytes
+	validateBalanceTx bool         // BatchAddBalanceTx rejects the items without address or tick
+	closed            *atomic.Bool // set by Close, shared with the Primary copies
+	optimizing        *atomic.Bool // set while Optimize runs, shared with the Primary copies
+	readOnly          *readOnlyPool
+	tablePrefix       string // prepended to the table names, see useTablePrefix
+
+	fastTimeout       time.Duration // deadline of the fast read methods called without a context, see SetQueryTimeouts
+	analyticalTimeout time.Duration // deadline of the analytical read methods called without a context
 }
 
-// NewDbClient creates a new database client instance.
+// The errors of the client are matched with errors.Is, the database errors are wrapped with the context of the
+// failed operation. The finders report an unknown row as nil, nil.
+var (
+	// ErrClientClosed is returned by the queries of a closed client
+	ErrClientClosed = errors.New("storage client closed")
+	// ErrNilTx is returned by the writers called with a nil dbTx
+	ErrNilTx = errors.New("gorm db is not valid")
+)
+
+// NewDbClient creates a new database client instance. The PrepareStmt of the config enables the prepared statement
+// cache of gorm, see unprepared for the statements left out of it.
 func NewDbClient(cfg *config.DatabaseConfig) (*DBClient, error) {
-	gormCfg := &gorm.Config{}
-	if cfg.EnableLog {
-		gormCfg.Logger = logger.Default.LogMode(logger.Info)
+	gormCfg := &gorm.Config{
+		Logger:      newGormLogger(cfg, stdlog.New(os.Stdout, "\r\n", stdlog.LstdFlags)),
+		PrepareStmt: cfg.PrepareStmt,
 	}
 	switch cfg.Type {
 	case DatabaseTypeSqlite3:
 		return NewSqliteClient(cfg, gormCfg)
 	case DatabaseTypeMysql:
 		return NewMysqlClient(cfg, gormCfg)
+	case DatabaseTypePostgres:
+		return NewPostgresClient(cfg, gormCfg)
 	}
 	return nil, nil
 }
 
+// newGormLogger the query logger of the config, every query is logged when EnableLog is set and the queries slower
+// than SlowThresholdMs are logged at warn level either way. The gorm default logger is used when neither is set.
+func newGormLogger(cfg *config.DatabaseConfig, writer logger.Writer) logger.Interface {
+	if !cfg.EnableLog && cfg.SlowThresholdMs == 0 {
+		return logger.Default
+	}
+
+	logCfg := logger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      logger.Warn,
+		Colorful:      true,
+	}
+	if cfg.EnableLog {
+		logCfg.LogLevel = logger.Info
+	}
+	if cfg.SlowThresholdMs > 0 {
+		logCfg.SlowThreshold = time.Duration(cfg.SlowThresholdMs) * time.Millisecond
+	}
+	return logger.New(writer, logCfg)
+}
+
+// dsnWithParam appends the parameter to the query string of the dsn
+func dsnWithParam(dsn, key, value string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + key + "=" + value
+	}
+	return dsn + "?" + key + "=" + value
+}
+
+// setConnPool applies the pool settings of the config to the underlying sql.DB
+func setConnPool(db *gorm.DB, cfg *config.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	maxOpenConns, maxIdleConns, connMaxLifetime, connMaxIdleTime := poolSettings(cfg)
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(connMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(connMaxIdleTime)
+	return nil
+}
+
+// poolSettings the pool settings of the config, zero values fall back to the defaults
+func poolSettings(cfg *config.DatabaseConfig) (maxOpenConns, maxIdleConns int, connMaxLifetime, connMaxIdleTime time.Duration) {
+	maxOpenConns = DefaultMaxOpenConns
+	if cfg.MaxOpenConns > 0 {
+		maxOpenConns = cfg.MaxOpenConns
+	}
+	maxIdleConns = DefaultMaxIdleConns
+	if cfg.MaxIdleConns > 0 {
+		maxIdleConns = cfg.MaxIdleConns
+	}
+	connMaxLifetime = DefaultConnMaxLifetime
+	if cfg.ConnMaxLifetime > 0 {
+		connMaxLifetime = time.Duration(cfg.ConnMaxLifetime) * time.Second
+	}
+	connMaxIdleTime = DefaultConnMaxIdleTime
+	if cfg.ConnMaxIdleTime > 0 {
+		connMaxIdleTime = time.Duration(cfg.ConnMaxIdleTime) * time.Second
+	}
+	return
+}
+
+// useReplicas registers the read replicas of the config, queries are routed to a random replica while writes and
+// transactions stay on the source database.
+func useReplicas(db *gorm.DB, cfg *config.DatabaseConfig, open func(dsn string) gorm.Dialector) error {
+	if len(cfg.Replicas) < 1 {
+		return nil
+	}
+
+	replicas := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for _, dsn := range cfg.Replicas {
+		replicas = append(replicas, open(dsn))
+	}
+
+	maxOpenConns, maxIdleConns, connMaxLifetime, connMaxIdleTime := poolSettings(cfg)
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxOpenConns(maxOpenConns).
+		SetMaxIdleConns(maxIdleConns).
+		SetConnMaxLifetime(connMaxLifetime).
+		SetConnMaxIdleTime(connMaxIdleTime)
+	return db.Use(resolver)
+}
+
+// guardClosed makes every query of the client fail with ErrClientClosed once the client is closed
+func (conn *DBClient) guardClosed() error {
+	check := func(db *gorm.DB) {
+		if conn.isClosed() {
+			_ = db.AddError(ErrClientClosed)
+		}
+	}
+
+	callback := conn.SqlDB.Callback()
+	for _, err := range []error{
+		callback.Create().Before("*").Register("storage:closed", check),
+		callback.Query().Before("*").Register("storage:closed", check),
+		callback.Update().Before("*").Register("storage:closed", check),
+		callback.Delete().Before("*").Register("storage:closed", check),
+		callback.Row().Before("*").Register("storage:closed", check),
+		callback.Raw().Before("*").Register("storage:closed", check),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isClosed reports whether Close was called on the client
+func (conn *DBClient) isClosed() bool {
+	return conn.closed != nil && conn.closed.Load()
+}
+
+// Close closes the connection pool of the client, later queries fail with ErrClientClosed.
+// The wal of sqlite is checkpointed into the database file before. Closing a closed client is a no-op.
+func (conn *DBClient) Close() error {
+	if conn.closed != nil && !conn.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	sqlDB, err := conn.SqlDB.DB()
+	if err != nil {
+		return err
+	}
+
+	if conn.SqlDB.Dialector.Name() == "sqlite" {
+		if _, err = sqlDB.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			log.Warn("sqlite wal checkpoint failed", "err", err)
+		}
+	}
+
+	if conn.readOnly != nil {
+		if err = conn.readOnly.close(); err != nil {
+			log.Warn("close read only db failed", "err", err)
+		}
+	}
+	return sqlDB.Close()
+}
+
+// Primary returns a client whose reads go to the source database, e.g. for read-after-write consistency when
+// read replicas are configured. Without replicas it behaves like the client itself.
+func (conn *DBClient) Primary() *DBClient {
+	primary := *conn
+	primary.SqlDB = conn.SqlDB.Clauses(dbresolver.Write).Session(&gorm.Session{})
+	return &primary
+}
+
+// scanFirst scans the first row of the query into dest, sql.ErrNoRows when there is none.
+// Unlike Row() the errors of the query callbacks are returned instead of a nil row.
+func scanFirst(query *gorm.DB, dest ...interface{}) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return rows.Scan(dest...)
+}
+
+// quote quotes the identifier (table, column or alias.column) with the quoting style of the database dialect,
+// raw sql must use it instead of hardcoded backticks, postgres only accepts double quotes.
+func (conn *DBClient) quote(name string) string {
+	return conn.SqlDB.Statement.Quote(name)
+}
+
+// isPostgres reports whether the client is connected to a postgres database
+func (conn *DBClient) isPostgres() bool {
+	return conn.SqlDB.Dialector.Name() == DatabaseTypePostgres
+}
+
+// insertBatchSize the rows of a single INSERT of the Batch* methods
+func (conn *DBClient) insertBatchSize() int {
+	if conn.batchSize > 0 {
+		return conn.batchSize
+	}
+	return DefaultBatchSize
+}
+
 func (conn *DBClient) CreateInBatches(dbTx *gorm.DB, value interface{}, batchSize int) error {
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	dbTx = unprepared(dbTx)
 	reflectValue := reflect.Indirect(reflect.ValueOf(value))
 
 	// the reflection type judgment of the optimized value
@@ -83,45 +374,213 @@ func (conn *DBClient) CreateInBatches(dbTx *gorm.DB, value interface{}, batchSiz
 
 	// the reflection length judgment of the optimized value
 	reflectLen := reflectValue.Len()
-	for i := 0; i < reflectLen; i += batchSize {
-		ends := i + batchSize
-		if ends > reflectLen {
-			ends = reflectLen
-		}
+	if reflectLen <= batchSize {
+		return dbTx.Clauses(dbresolver.Write).Create(value).Error
+	}
 
-		subTx := dbTx.Create(reflectValue.Slice(i, ends).Interface())
-		if subTx.Error != nil {
-			return subTx.Error
+	insert := func(tx *gorm.DB) error {
+		for i := 0; i < reflectLen; i += batchSize {
+			ends := i + batchSize
+			if ends > reflectLen {
+				ends = reflectLen
+			}
+
+			subTx := tx.Create(reflectValue.Slice(i, ends).Interface())
+			if subTx.Error != nil {
+				return subTx.Error
+			}
 		}
+		return nil
 	}
-	return nil
+	// a dry run only renders the chunks, there is nothing to roll back
+	if dbTx.DryRun {
+		return insert(dbTx.Clauses(dbresolver.Write))
+	}
+
+	// the chunks are inserted all or nothing, a savepoint when dbTx is already a transaction
+	return dbTx.Clauses(dbresolver.Write).Transaction(insert)
 }
 
+// SaveLastBlock stores the block status of the chain as it is, a lower height overwrites a higher one.
+// Use it to move the height back explicitly, the indexing flow saves through SaveLastBlockMonotonic.
+// The indexed_at of the status is set to the current time.
 func (conn *DBClient) SaveLastBlock(tx *gorm.DB, status *model.BlockStatus) error {
 	if tx == nil {
-		return errors.New("gorm db is not valid")
+		return ErrNilTx
+	}
+	now := time.Now()
+	status.IndexedAt = &now
+	if err := tx.Clauses(dbresolver.Write).Where("chain = ?", status.Chain).Save(status).Error; err != nil {
+		return fmt.Errorf("save block status of chain[%s] failed: %w", status.Chain, err)
+	}
+	return nil
+}
+
+// SaveLastBlockMonotonic stores the block status of the chain only when its height is above the stored one, a stale
+// or repeated height leaves the row untouched. It reports whether the status was applied, the indexed_at of an applied
+// status is set to the current time.
+func (conn *DBClient) SaveLastBlockMonotonic(tx *gorm.DB, status *model.BlockStatus) (bool, error) {
+	if tx == nil {
+		return false, ErrNilTx
+	}
+
+	now := time.Now()
+	status.IndexedAt = &now
+	result := tx.Clauses(dbresolver.Write).Table(conn.table(status)).
+		Where("chain = ? AND block_number < ?", status.Chain, status.BlockNumber).
+		Updates(map[string]interface{}{
+			"block_hash":   status.BlockHash,
+			"block_number": status.BlockNumber,
+			"block_time":   status.BlockTime,
+			"indexed_at":   status.IndexedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("update block status of chain[%s] failed: %w", status.Chain, result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
 	}
-	return tx.Where("chain = ?", status.Chain).Save(status).Error
+
+	// the first block of the chain
+	var cnt int64
+	err := tx.Clauses(dbresolver.Write).Table(conn.table(status)).Where("chain = ?", status.Chain).Count(&cnt).Error
+	if err != nil {
+		return false, fmt.Errorf("count block status of chain[%s] failed: %w", status.Chain, err)
+	}
+	if cnt > 0 {
+		return false, nil
+	}
+	result = tx.Clauses(dbresolver.Write, clause.OnConflict{DoNothing: true}).Create(status)
+	if result.Error != nil {
+		return false, fmt.Errorf("create block status of chain[%s] failed: %w", status.Chain, result.Error)
+	}
+	return result.RowsAffected > 0, nil
 }
 
+// ErrInvalidBlockNumber the block_number of the block status row is not a decimal number
+var ErrInvalidBlockNumber = errors.New("invalid block number")
+
+// QueryLastBlock returns the last indexed block height of the chain, nil when the chain has no block status row yet.
+// A corrupt block_number is reported as ErrInvalidBlockNumber instead of being treated as height 0.
 func (conn *DBClient) QueryLastBlock(chain string) (*big.Int, error) {
-	var blockNumberStr string
-	err := conn.SqlDB.Table(model.BlockStatus{}.TableName()).Where("chain = ?", chain).Pluck("block_number", &blockNumberStr).Error
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.QueryLastBlockContext(ctx, chain)
+}
+
+// QueryLastBlockContext is the context aware variant of QueryLastBlock.
+func (conn *DBClient) QueryLastBlockContext(ctx context.Context, chain string) (*big.Int, error) {
+	status, err := conn.GetBlockStatusContext(ctx, chain)
+	if err != nil || status == nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(status.BlockNumber), nil
+}
+
+// GetBlockStatus returns the block status row of the chain including the block hash, nil when the chain is unknown.
+// A corrupt block_number is reported as ErrInvalidBlockNumber.
+func (conn *DBClient) GetBlockStatus(chain string) (*model.BlockStatus, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBlockStatusContext(ctx, chain)
+}
+
+// GetBlockStatusContext is the context aware variant of GetBlockStatus.
+func (conn *DBClient) GetBlockStatusContext(ctx context.Context, chain string) (*model.BlockStatus, error) {
+	status := &model.BlockStatus{}
+	err := conn.SqlDB.WithContext(ctx).Where("chain = ?", chain).Take(status).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		// the scan error does not tell which value is corrupt, look at the raw block_number
+		if corrupt := conn.checkBlockNumber(ctx, chain); corrupt != nil {
+			return nil, corrupt
+		}
 		return nil, err
 	}
+	return status, nil
+}
+
+// checkBlockNumber the ErrInvalidBlockNumber of the raw block_number of the chain, nil when it is a decimal number or
+// cannot be read
+func (conn *DBClient) checkBlockNumber(ctx context.Context, chain string) error {
+	var raw sql.NullString
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.BlockStatus{})).Select("block_number").Where("chain = ?", chain).Limit(1)
+	if err := scanFirst(query, &raw); err != nil {
+		return nil
+	}
+
+	if raw.String == "" {
+		return fmt.Errorf("%w: chain[%s] block_number is empty", ErrInvalidBlockNumber, chain)
+	}
+	if _, err := utils.ConvetStr(raw.String); err != nil {
+		return fmt.Errorf("%w: chain[%s] block_number[%s] err:%v", ErrInvalidBlockNumber, chain, raw.String, err)
+	}
+	return nil
+}
+
+// ErrChainNotIndexed is returned by GetIndexingLag when the chain has no block status row or its indexing time is not
+// tracked yet
+var ErrChainNotIndexed = errors.New("chain not indexed")
+
+// GetIndexingLag returns the time elapsed since the last block of the chain was stored, for the alerting on a stalled
+// indexer. The lag is measured with the clock of the caller.
+func (conn *DBClient) GetIndexingLag(chain string) (time.Duration, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetIndexingLagContext(ctx, chain)
+}
+
+// GetIndexingLagContext is the context aware variant of GetIndexingLag.
+func (conn *DBClient) GetIndexingLagContext(ctx context.Context, chain string) (time.Duration, error) {
+	status, err := conn.GetBlockStatusContext(ctx, chain)
+	if err != nil {
+		return 0, err
+	}
+	if status == nil || status.IndexedAt == nil {
+		return 0, fmt.Errorf("%w: chain[%s]", ErrChainNotIndexed, chain)
+	}
+	return time.Since(*status.IndexedAt), nil
+}
+
+// LastBlocks returns the last block heights of the chains in one query, unknown chains have height 0
+func (conn *DBClient) LastBlocks(chains []string) (map[string]*big.Int, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.LastBlocksContext(ctx, chains)
+}
+
+// LastBlocksContext is the context aware variant of LastBlocks.
+func (conn *DBClient) LastBlocksContext(ctx context.Context, chains []string) (map[string]*big.Int, error) {
+	blocks := make(map[string]*big.Int, len(chains))
+	if len(chains) < 1 {
+		return blocks, nil
+	}
 
-	if blockNumberStr == "" {
-		return big.NewInt(0), nil
+	var items []*model.BlockStatus
+	err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain IN ?", chains).Find(&items).Error
+	if err != nil {
+		return nil, err
 	}
 
-	blockNumber, _ := big.NewInt(0).SetString(blockNumberStr, 10)
-	return blockNumber, nil
+	for _, chain := range chains {
+		blocks[chain] = big.NewInt(0)
+	}
+	for _, item := range items {
+		blocks[item.Chain] = new(big.Int).SetUint64(item.BlockNumber)
+	}
+	return blocks, nil
 }
 
 func (conn *DBClient) GetLock() (ok bool, err error) {
+	lockSql := "SELECT GET_LOCK(?, 0)"
+	if conn.isPostgres() {
+		lockSql = "SELECT CASE WHEN pg_try_advisory_lock(hashtext(?)) THEN 1 ELSE 0 END"
+	}
+
 	locked := int64(0)
-	err = conn.SqlDB.Table(model.BlockStatus{}.TableName()).Raw("SELECT GET_LOCK(?, 0)", DBSessionLockKey).Scan(&locked).Error
+	err = conn.SqlDB.Table(model.BlockStatus{}.TableName()).Clauses(dbresolver.Write).Raw(lockSql, DBSessionLockKey).Scan(&locked).Error
 	if err != nil {
 		return false, err
 	}
@@ -133,25 +592,52 @@ type CountResult struct {
 }
 
 func (conn *DBClient) ReleaseLock() (cnt int64, err error) {
+	releaseSql := "SELECT RELEASE_LOCK(?) AS cnt"
+	if conn.isPostgres() {
+		releaseSql = "SELECT CASE WHEN pg_advisory_unlock(hashtext(?)) THEN 1 ELSE 0 END AS cnt"
+	}
+
 	ret := &CountResult{}
-	err = conn.SqlDB.Table(model.BlockStatus{}.TableName()).Raw("SELECT RELEASE_LOCK(?) AS cnt", DBSessionLockKey).Take(ret).Error
+	err = conn.SqlDB.Table(model.BlockStatus{}.TableName()).Clauses(dbresolver.Write).Raw(releaseSql, DBSessionLockKey).Take(ret).Error
 	if err != nil {
 		return 0, err
 	}
 	return ret.Count, nil
 }
 
-func (conn *DBClient) BatchAddInscription(dbTx *gorm.DB, ins []*model.Inscriptions) error {
+// BatchAddInscription inserts the deploys and returns the ones skipped because the tick (or the sid) already exists,
+// so a re-deploy is a no-op. The rows are inserted one by one, a multi-row insert can't tell which rows were ignored
+// and would assign the generated ids to the wrong items.
+func (conn *DBClient) BatchAddInscription(dbTx *gorm.DB, ins []*model.Inscriptions) (skipped []*model.Inscriptions, err error) {
+	defer conn.observe("BatchAddInscription", time.Now(), &err)
+
 	if len(ins) < 1 {
-		return nil
+		return nil, nil
+	}
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+
+	dbTx = dbTx.Clauses(dbresolver.Write, clause.OnConflict{DoNothing: true})
+	for _, item := range ins {
+		ret := dbTx.Create(item)
+		if ret.Error != nil {
+			return nil, fmt.Errorf("insert inscription[%s] of chain[%s] failed: %w", item.Tick, item.Chain, ret.Error)
+		}
+		if ret.RowsAffected < 1 {
+			skipped = append(skipped, item)
+		}
 	}
-	return dbTx.Create(ins).Error
+	return skipped, nil
 }
 
 func (conn *DBClient) BatchUpdateInscription(dbTx *gorm.DB, chain string, items []*model.Inscriptions) error {
 	if len(items) < 1 {
 		return nil
 	}
+	if dbTx == nil {
+		return ErrNilTx
+	}
 	fields := map[string]string{
 		"transfer_type": "%d",
 	}
@@ -170,39 +656,182 @@ func (conn *DBClient) BatchUpdateInscription(dbTx *gorm.DB, chain string, items
 	return nil
 }
 
-func (conn *DBClient) BatchUpdatesBySID(dbTx *gorm.DB, chain string, tblName string, fields map[string]string, values []map[string]interface{}) (error, int64) {
+// SoftDeleteInscription hides the inscription from all the finders without removing the row,
+// the row is still available with Unscoped and can be restored by RestoreInscription.
+func (conn *DBClient) SoftDeleteInscription(dbTx *gorm.DB, chain, protocol, tick string) error {
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	err := dbTx.Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Delete(&model.Inscriptions{}).Error
+	if err != nil {
+		return fmt.Errorf("soft delete inscription[%s] of chain[%s] failed: %w", tick, chain, err)
+	}
+	return nil
+}
+
+// RestoreInscription undoes SoftDeleteInscription
+func (conn *DBClient) RestoreInscription(dbTx *gorm.DB, chain, protocol, tick string) error {
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	err := dbTx.Unscoped().Model(&model.Inscriptions{}).Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).
+		Update("deleted_at", nil).Error
+	if err != nil {
+		return fmt.Errorf("restore inscription[%s] of chain[%s] failed: %w", tick, chain, err)
+	}
+	return nil
+}
+
+// BatchUpdatesBySID updates multiple rows of the table in a single statement, every value is bound as a parameter.
+// tblName is the TableName of the model, the table prefix of the client is added to it.
+// A statement above the max_statement_kb of the config is split into several statements of fewer rows, the update of a
+// single row above the limit fails with ErrStatementTooLarge instead of being rejected by the database.
+func (conn *DBClient) BatchUpdatesBySID(dbTx *gorm.DB, chain string, tblName string, fields map[string]string, values []map[string]interface{}) (err error, affected int64) {
+	defer conn.observe("BatchUpdatesBySID", time.Now(), &err)
+	return conn.batchUpdatesBySID(dbTx, chain, tblName, fields, values, false)
+}
+
+// batchUpdatesBySID builds the statement of BatchUpdatesBySID, with versioned a row is only updated when its version
+// column still equals the "version" value of the row, the version is bumped on update.
+func (conn *DBClient) batchUpdatesBySID(dbTx *gorm.DB, chain string, tblName string, fields map[string]string, values []map[string]interface{}, versioned bool) (error, int64) {
 	if len(values) < 1 {
 		return nil, 0
 	}
+	if dbTx == nil {
+		return ErrNilTx, 0
+	}
+
+	finalSql, args := conn.batchUpdatesBySIDStatement(chain, tblName, fields, values, versioned)
+	size := statementSize(finalSql, args)
+	if limit := conn.statementSizeLimit(); size > limit {
+		if len(values) == 1 {
+			return fmt.Errorf("%w: the update of 1 row of table[%s] is %d bytes, above the limit of %d bytes",
+				ErrStatementTooLarge, tblName, size, limit), 0
+		}
+
+		chunks := size/limit + 1
+		if chunks > len(values) {
+			chunks = len(values)
+		}
+		rows := (len(values) + chunks - 1) / chunks
+		log.Warn("batch update above the statement size limit, split into chunks", "table", tblName, "rows", len(values),
+			"bytes", size, "limit", limit, "chunk_rows", rows)
+
+		var affected int64
+		for i := 0; i < len(values); i += rows {
+			end := i + rows
+			if end > len(values) {
+				end = len(values)
+			}
+			err, cnt := conn.batchUpdatesBySID(dbTx, chain, tblName, fields, values[i:end], versioned)
+			if err != nil {
+				return err, 0
+			}
+			affected += cnt
+		}
+		return nil, affected
+	}
+
+	if conn.metricsEnabled {
+		statementBytes.WithLabelValues(tblName).Observe(float64(size))
+	}
+	ret := unprepared(dbTx).Clauses(dbresolver.Write).Exec(finalSql, args...)
+	if ret.Error != nil {
+		return fmt.Errorf("update %d rows of table[%s] of chain[%s] failed: %w", len(values), tblName, chain, ret.Error), 0
+	}
+	return nil, ret.RowsAffected
+}
+
+// batchUpdatesBySIDStatement the sql and the args of the batchUpdatesBySID of the values
+func (conn *DBClient) batchUpdatesBySIDStatement(chain string, tblName string, fields map[string]string, values []map[string]interface{},
+	versioned bool) (string, []interface{}) {
+
+	// keep the field order stable, the generated sql and the args must be in the same order
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
 
-	updates := make([]string, 0, len(fields))
-	for field, vt := range fields {
-		update := fmt.Sprintf(" %s = CASE sid ", field)
-		tpl := fmt.Sprintf(" WHEN %s THEN '%s'", "%d", vt)
+	// the ELSE branch keeps the column type for the CASE, postgres would resolve the bound values as text otherwise
+	updates := make([]string, 0, len(names))
+	args := make([]interface{}, 0, len(names)*len(values)*2+len(values)+1)
+	for _, field := range names {
+		column := conn.quote(field)
+		update := fmt.Sprintf(" %s = CASE sid", column)
 		for _, value := range values {
-			update += fmt.Sprintf(tpl, value["sid"], value[field])
+			update += " WHEN ? THEN ?"
+			args = append(args, value["sid"], value[field])
 		}
-		update += " END"
+		update += fmt.Sprintf(" ELSE %s END", column)
 		updates = append(updates, update)
 	}
 
-	ids := make([]string, 0, len(values))
+	// raw sql bypasses the gorm timestamp tracking, mysql has ON UPDATE CURRENT_TIMESTAMP but sqlite & postgres don't
+	if _, ok := fields["updated_at"]; !ok {
+		updates = append(updates, fmt.Sprintf(" %s = ?", conn.quote("updated_at")))
+		args = append(args, time.Now())
+	}
+
+	version := conn.quote("version")
+	if versioned {
+		updates = append(updates, fmt.Sprintf(" %s = %s + 1", version, version))
+	}
+
+	ids := make([]interface{}, 0, len(values))
 	for _, value := range values {
-		ids = append(ids, fmt.Sprintf("%d", value["sid"]))
+		ids = append(ids, value["sid"])
 	}
+	args = append(args, chain, ids)
 
-	finalSql := fmt.Sprintf("UPDATE %s SET %s WHERE chain = '%s' AND sid IN (%s)", tblName, strings.Join(updates, ","), chain, strings.Join(ids, ","))
-	ret := dbTx.Exec(finalSql)
-	if ret.Error != nil {
-		return ret.Error, 0
+	finalSql := fmt.Sprintf("UPDATE %s SET %s WHERE chain = ? AND sid IN ?", conn.quote(conn.tablePrefix+tblName), strings.Join(updates, ","))
+	if versioned {
+		cond := fmt.Sprintf(" AND %s = CASE sid", version)
+		for _, value := range values {
+			cond += " WHEN ? THEN ?"
+			args = append(args, value["sid"], value["version"])
+		}
+		finalSql += cond + fmt.Sprintf(" ELSE %s END", version)
 	}
-	return nil, ret.RowsAffected
+	return finalSql, args
+}
+
+// statementSizeLimit the size limit of a single batch UPDATE statement in bytes
+func (conn *DBClient) statementSizeLimit() int {
+	if conn.maxStatementBytes > 0 {
+		return conn.maxStatementBytes
+	}
+	return DefaultMaxStatementBytes
+}
+
+// statementSize the length of the sql and the text of the bound values, the size of the statement sent to the database
+// up to the encoding of the values by the driver
+func statementSize(sql string, args []interface{}) int {
+	size := len(sql)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		case []interface{}:
+			size += statementSize("", v)
+		default:
+			size += len(fmt.Sprint(v))
+		}
+	}
+	return size
 }
 
 func (conn *DBClient) BatchUpdateInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) error {
 	if len(items) < 1 {
 		return nil
 	}
+	if dbTx == nil {
+		return ErrNilTx
+	}
 
 	fields := map[string]string{
 		"minted":  "%s",
@@ -226,74 +855,448 @@ func (conn *DBClient) BatchUpdateInscriptionStats(dbTx *gorm.DB, chain string, i
 	return nil
 }
 
-func (conn *DBClient) BatchAddInscriptionStats(dbTx *gorm.DB, ins []*model.InscriptionsStats) error {
+func (conn *DBClient) BatchAddInscriptionStats(dbTx *gorm.DB, ins []*model.InscriptionsStats) (err error) {
+	defer conn.observe("BatchAddInscriptionStats", time.Now(), &err)
+
 	if len(ins) < 1 {
 		return nil
 	}
-	return dbTx.Create(ins).Error
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	if err = conn.CreateInBatches(dbTx, ins, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("insert %d inscription stats failed: %w", len(ins), err)
+	}
+	return nil
 }
 
-func (conn *DBClient) BatchAddTransaction(dbTx *gorm.DB, items []*model.Transaction) error {
+// BatchUpsertInscriptionStats inserts the new stats and sets minted, holders & tx_cnt of the existing ones in one
+// statement, the conflict key is the unique key (chain, protocol, tick). The values are stored as they are, the caller
+// passes the cumulative amounts.
+func (conn *DBClient) BatchUpsertInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) (err error) {
+	defer conn.observe("BatchUpsertInscriptionStats", time.Now(), &err)
+
 	if len(items) < 1 {
 		return nil
 	}
-	return conn.CreateInBatches(dbTx, items, 1000)
+
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	for _, item := range items {
+		if item.Chain != chain {
+			return fmt.Errorf("inscription stats chain[%s] mismatch, expected chain[%s]", item.Chain, chain)
+		}
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain"}, {Name: "protocol"}, {Name: "tick"}},
+		DoUpdates: clause.AssignmentColumns([]string{"minted", "holders", "tx_cnt", "updated_at"}),
+	}
+	if err = conn.CreateInBatches(dbTx.Clauses(onConflict), items, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("upsert %d inscription stats of chain[%s] failed: %w", len(items), chain, err)
+	}
+	return nil
 }
 
-func (conn *DBClient) BatchAddBalanceTx(dbTx *gorm.DB, items []*model.BalanceTxn) error {
+// BatchAddTransaction inserts the txs and returns how many were newly inserted, the ones whose (chain, tx_hash) already
+// exists are skipped, so reprocessing a block does not store its txs twice. The address txs & balance txs of the block
+// have no such key, they are stored again. The rows are inserted in batches, the ids of the items of a batch with
+// skipped rows are cleared: the ids returned by the database can't be told apart per row, mysql even assigns sequential
+// ids from the last insert id to all the items.
+func (conn *DBClient) BatchAddTransaction(dbTx *gorm.DB, items []*model.Transaction) (inserted int64, err error) {
+	defer conn.observe("BatchAddTransaction", time.Now(), &err)
+
 	if len(items) < 1 {
+		return 0, nil
+	}
+	if dbTx == nil {
+		return 0, ErrNilTx
+	}
+
+	dbTx = unprepared(dbTx)
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain"}, {Name: "tx_hash"}},
+		DoNothing: true,
+	}
+	insert := func(tx *gorm.DB) error {
+		for _, batch := range chunks(items, conn.insertBatchSize()) {
+			ret := tx.Clauses(onConflict).Create(batch)
+			if ret.Error != nil {
+				return ret.Error
+			}
+			if ret.RowsAffected < int64(len(batch)) {
+				for _, item := range batch {
+					item.ID = 0
+				}
+			}
+			inserted += ret.RowsAffected
+		}
 		return nil
 	}
-	return conn.CreateInBatches(dbTx, items, 1000)
+	// the batches are inserted all or nothing like CreateInBatches
+	if dbTx.DryRun {
+		err = insert(dbTx.Clauses(dbresolver.Write))
+	} else {
+		err = dbTx.Clauses(dbresolver.Write).Transaction(insert)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("insert %d txs failed: %w", len(items), err)
+	}
+	return inserted, nil
 }
 
-func (conn *DBClient) BatchAddAddressTx(dbTx *gorm.DB, items []*model.AddressTxs) error {
+// BatchAddBalanceTx inserts the balance txs in batches of the insert batch size, all or nothing. With the
+// validate_balance_txs option the items without address or tick are rejected before anything is inserted, the check
+// is skipped otherwise.
+func (conn *DBClient) BatchAddBalanceTx(dbTx *gorm.DB, items []*model.BalanceTxn) (err error) {
+	defer conn.observe("BatchAddBalanceTx", time.Now(), &err)
+
 	if len(items) < 1 {
 		return nil
 	}
-	return conn.CreateInBatches(dbTx, items, 1000)
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	if conn.validateBalanceTx {
+		for _, item := range items {
+			if item.Address == "" || item.Tick == "" {
+				return fmt.Errorf("invalid balance tx[%s], empty address[%s] or tick[%s]", item.TxHash, item.Address, item.Tick)
+			}
+		}
+	}
+	if err = conn.CreateInBatches(dbTx, items, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("insert %d balance txs failed: %w", len(items), err)
+	}
+	return nil
 }
 
-func (conn *DBClient) BatchAddBalances(dbTx *gorm.DB, items []*model.Balances) error {
+func (conn *DBClient) BatchAddAddressTx(dbTx *gorm.DB, items []*model.AddressTxs) (err error) {
+	defer conn.observe("BatchAddAddressTx", time.Now(), &err)
+
 	if len(items) < 1 {
 		return nil
 	}
-	return conn.CreateInBatches(dbTx, items, 1000)
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	for _, item := range items {
+		if !item.Event.Valid() {
+			return fmt.Errorf("invalid event[%d] of address tx[%s]", item.Event, item.TxHash)
+		}
+	}
+	if err = conn.CreateInBatches(dbTx, items, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("insert %d address txs failed: %w", len(items), err)
+	}
+	return nil
 }
 
-func (conn *DBClient) BatchUpdateBalances(dbTx *gorm.DB, chain string, items []*model.Balances) error {
+func (conn *DBClient) BatchAddBalances(dbTx *gorm.DB, items []*model.Balances) (err error) {
+	defer conn.observe("BatchAddBalances", time.Now(), &err)
+
 	if len(items) < 1 {
 		return nil
 	}
-
-	fields := map[string]string{
-		"available": "%s",
-		"balance":   "%s",
+	if dbTx == nil {
+		return ErrNilTx
 	}
-
+	if err = conn.CreateInBatches(dbTx, items, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("insert %d balances failed: %w", len(items), err)
+	}
+	return nil
+}
+
+// BatchUpsertBalances inserts the new balances and updates available & balance of the existing ones in one statement,
+// the conflict key is the unique key (address, chain, protocol, tick).
+func (conn *DBClient) BatchUpsertBalances(dbTx *gorm.DB, chain string, items []*model.Balances) (err error) {
+	defer conn.observe("BatchUpsertBalances", time.Now(), &err)
+
+	if len(items) < 1 {
+		return nil
+	}
+
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	for _, item := range items {
+		if item.Chain != chain {
+			return fmt.Errorf("balance chain[%s] mismatch, expected chain[%s]", item.Chain, chain)
+		}
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}, {Name: "chain"}, {Name: "protocol"}, {Name: "tick"}},
+		DoUpdates: clause.AssignmentColumns([]string{"available", "balance", "updated_at"}),
+	}
+	if err = conn.CreateInBatches(dbTx.Clauses(onConflict), items, conn.insertBatchSize()); err != nil {
+		return fmt.Errorf("upsert %d balances of chain[%s] failed: %w", len(items), chain, err)
+	}
+	return nil
+}
+
+// GetBalancesUpdatedSince returns the balances of the chain updated at or after since, for incremental exports.
+// Rows are ordered by (updated_at, id), pass the updated_at & id of the last row of the previous page to continue,
+// lastId 0 starts at since.
+func (conn *DBClient) GetBalancesUpdatedSince(chain string, since time.Time, lastId uint64, limit int) ([]*model.Balances, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBalancesUpdatedSinceContext(ctx, chain, since, lastId, limit)
+}
+
+// GetBalancesUpdatedSinceContext is the context aware variant of GetBalancesUpdatedSince.
+func (conn *DBClient) GetBalancesUpdatedSinceContext(ctx context.Context, chain string, since time.Time, lastId uint64, limit int) (
+	[]*model.Balances, error) {
+	balances := make([]*model.Balances, 0)
+	err := conn.SqlDB.WithContext(ctx).Where("chain = ?", chain).
+		Where("(updated_at > ? OR (updated_at = ? AND id > ?))", since, since, lastId).
+		Order("updated_at asc").Order("id asc").Limit(limit).Find(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// ErrInvalidOutpoint the utxo does not set its outpoint (chain, tx_hash, vout)
+var ErrInvalidOutpoint = errors.New("invalid utxo outpoint")
+
+// BatchAddUTXO inserts the utxos and returns the ones skipped because their outpoint (chain, tx_hash, vout) already
+// exists, so indexing an output twice is a no-op. Like BatchAddInscription the rows are inserted one by one.
+// Every utxo sets its outpoint, a utxo without tx hash or two utxos of the batch with the same outpoint (e.g. the
+// outputs of a tx all left at vout 0) fail the batch with ErrInvalidOutpoint before anything is inserted.
+func (conn *DBClient) BatchAddUTXO(dbTx *gorm.DB, items []*model.UTXO) (skipped []*model.UTXO, err error) {
+	defer conn.observe("BatchAddUTXO", time.Now(), &err)
+
+	if len(items) < 1 {
+		return nil, nil
+	}
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+
+	type outpoint struct {
+		chain  string
+		txHash string
+		vout   uint32
+	}
+	outpoints := make(map[outpoint]bool, len(items))
+	for _, item := range items {
+		if item.TxHash == "" {
+			return nil, fmt.Errorf("%w: utxo of chain[%s] without tx hash", ErrInvalidOutpoint, item.Chain)
+		}
+		key := outpoint{chain: item.Chain, txHash: item.TxHash, vout: item.Vout}
+		if outpoints[key] {
+			return nil, fmt.Errorf("%w: outpoint %s:%d of chain[%s] repeated in the batch", ErrInvalidOutpoint,
+				item.TxHash, item.Vout, item.Chain)
+		}
+		outpoints[key] = true
+	}
+
+	dbTx = dbTx.Clauses(dbresolver.Write, clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain"}, {Name: "tx_hash"}, {Name: "vout"}},
+		DoNothing: true,
+	})
+	for _, item := range items {
+		ret := dbTx.Create(item)
+		if ret.Error != nil {
+			return nil, fmt.Errorf("insert utxo %s:%d of chain[%s] failed: %w", item.TxHash, item.Vout, item.Chain, ret.Error)
+		}
+		if ret.RowsAffected < 1 {
+			skipped = append(skipped, item)
+		}
+	}
+	return skipped, nil
+}
+
+// MarkUTXOSpent moves the unspent utxo to spent, the status check makes the transition happen at most once.
+// It reports whether the utxo transitioned, false means it is unknown or already spent.
+func (conn *DBClient) MarkUTXOSpent(dbTx *gorm.DB, chain, rootHash, address string) (bool, error) {
+	if dbTx == nil {
+		return false, ErrNilTx
+	}
+
+	ret := dbTx.Clauses(dbresolver.Write).Model(&model.UTXO{}).
+		Where("chain = ? AND root_hash = ? AND address = ? AND status = ?", chain, rootHash, address, model.UTXOStatusUnspent).
+		Updates(map[string]interface{}{"status": model.UTXOStatusSpent, "updated_at": time.Now()})
+	if ret.Error != nil {
+		return false, fmt.Errorf("mark utxo[%s] of chain[%s] spent failed: %w", rootHash, chain, ret.Error)
+	}
+	return ret.RowsAffected > 0, nil
+}
+
+// LockUTXOForSpend reads the utxo created by the output vout of the tx for a spend, nil when unknown. The row is read
+// with SELECT ... FOR UPDATE on mysql and postgres and stays locked until dbTx ends, a concurrent spend of the same
+// utxo waits for the commit and sees it spent. The caller checks the status before spending.
+// Sqlite has no row locks, the transactions are serialized as a whole instead: with _txlock=immediate a second
+// transaction waits for the first one on BEGIN, otherwise only the writes are serialized and two spends may both
+// read the utxo unspent.
+func (conn *DBClient) LockUTXOForSpend(dbTx *gorm.DB, chain, txid string, vout uint32) (*model.UTXO, error) {
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+
+	utxo := &model.UTXO{}
+	err := dbTx.Clauses(dbresolver.Write, clause.Locking{Strength: "UPDATE"}).
+		Where("chain = ? AND tx_hash = ? AND vout = ?", chain, txid, vout).Take(utxo).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lock utxo %s:%d of chain[%s] failed: %w", txid, vout, chain, err)
+	}
+	return utxo, nil
+}
+
+// BatchMarkUTXOSpent moves the unspent utxos of the root hashes to spent, it returns the number of utxos transitioned.
+// A count below the number of root hashes means some of them were unknown or already spent.
+func (conn *DBClient) BatchMarkUTXOSpent(dbTx *gorm.DB, chain string, rootHashes []string) (int64, error) {
+	if len(rootHashes) < 1 {
+		return 0, nil
+	}
+	if dbTx == nil {
+		return 0, ErrNilTx
+	}
+
+	ret := unprepared(dbTx).Clauses(dbresolver.Write).Model(&model.UTXO{}).
+		Where("chain = ? AND root_hash IN ? AND status = ?", chain, rootHashes, model.UTXOStatusUnspent).
+		Updates(map[string]interface{}{"status": model.UTXOStatusSpent, "updated_at": time.Now()})
+	if ret.Error != nil {
+		return 0, fmt.Errorf("mark %d utxos of chain[%s] spent failed: %w", len(rootHashes), chain, ret.Error)
+	}
+	return ret.RowsAffected, nil
+}
+
+// ErrBalanceVersionConflict is returned by BatchUpdateBalances when a balance was changed since it was read,
+// the caller must re-read the balances and retry.
+var ErrBalanceVersionConflict = errors.New("balance version conflict")
+
+// BatchUpdateBalances writes the balances guarded by their version, a balance is only updated when the stored
+// version still equals item.Version. ErrBalanceVersionConflict is returned when any of the balances is stale,
+// the caller must roll back the transaction since the other balances of the batch are updated.
+// On success the version of the items is bumped to the stored one.
+func (conn *DBClient) BatchUpdateBalances(dbTx *gorm.DB, chain string, items []*model.Balances) (err error) {
+	defer conn.observe("BatchUpdateBalances", time.Now(), &err)
+
+	if len(items) < 1 {
+		return nil
+	}
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	fields := map[string]string{
+		"available": "%s",
+		"balance":   "%s",
+	}
+
 	vals := make([]map[string]interface{}, 0, len(items))
 	for _, item := range items {
 		vals = append(vals, map[string]interface{}{
 			"sid":       item.SID,
 			"available": item.Available,
 			"balance":   item.Balance,
+			"version":   item.Version,
 		})
 	}
-	err, _ := conn.BatchUpdatesBySID(dbTx, chain, model.Balances{}.TableName(), fields, vals)
+	err, affected := conn.batchUpdatesBySID(dbTx, chain, model.Balances{}.TableName(), fields, vals, true)
 	if err != nil {
 		return err
 	}
+	// a dry run changes no row, the versions stay as they are
+	if dbTx.DryRun {
+		return nil
+	}
+	if affected != int64(len(items)) {
+		return fmt.Errorf("%w: chain[%s] updated %d of %d balances", ErrBalanceVersionConflict, chain, affected, len(items))
+	}
+
+	for _, item := range items {
+		item.Version++
+	}
 	return nil
 }
 
 func (conn *DBClient) UpdateInscriptionsStatsBySID(dbTx *gorm.DB, chain string, id uint32, updates map[string]interface{}) error {
-	return dbTx.Table(model.InscriptionsStats{}.TableName()).Where("chain = ?", chain).Where("sid = ?", id).Updates(updates).Error
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	err := dbTx.Table(conn.table(model.InscriptionsStats{})).Where("chain = ?", chain).Where("sid = ?", id).Updates(updates).Error
+	if err != nil {
+		return fmt.Errorf("update inscription stats[%d] of chain[%s] failed: %w", id, chain, err)
+	}
+	return nil
+}
+
+// ErrInscriptionNotFound is returned by GetMintableSupply when the tick is not deployed
+var ErrInscriptionNotFound = errors.New("inscription not found")
+
+// GetMintableSupply returns the supply of the tick left to mint, total_supply - minted and never below zero.
+// The stats row is read with SELECT ... FOR UPDATE on mysql and postgres and stays locked until dbTx ends, a concurrent
+// mint reading the same tick waits for the commit and sees the updated minted amount. A tick without stats row has
+// nothing to lock and counts as nothing minted.
+// Sqlite has no row locks, there the read is only safe when the writing transactions are serialized, e.g. by opening
+// the db with _txlock=immediate so that a second transaction waits for the first one on BEGIN.
+func (conn *DBClient) GetMintableSupply(dbTx *gorm.DB, chain, protocol, tick string) (string, error) {
+	if dbTx == nil {
+		return "", ErrNilTx
+	}
+
+	ins := &model.Inscriptions{}
+	err := dbTx.Clauses(dbresolver.Write).Select("total_supply").
+		Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Take(ins).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrInscriptionNotFound
+		}
+		return "", err
+	}
+
+	stats := &model.InscriptionsStats{}
+	err = dbTx.Clauses(dbresolver.Write, clause.Locking{Strength: "UPDATE"}).Select("minted").
+		Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Take(stats).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	remaining := ins.TotalSupply.Sub(stats.Minted)
+	if remaining.IsNegative() {
+		remaining = decimal.Zero
+	}
+	return remaining.String(), nil
 }
 
 // FindInscriptionByTick find token by tick
 func (conn *DBClient) FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindInscriptionByTickContext(ctx, chain, protocol, tick)
+}
+
+// FindInscriptionByTickContext is the context aware variant of FindInscriptionByTick.
+func (conn *DBClient) FindInscriptionByTickContext(ctx context.Context, chain, protocol, tick string) (*model.Inscriptions, error) {
+	inscriptionBaseInfo := &model.Inscriptions{}
+	err := conn.SqlDB.WithContext(ctx).First(inscriptionBaseInfo, "chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return inscriptionBaseInfo, nil
+}
+
+// FindInscriptionByTickTx is the variant of FindInscriptionByTick reading within dbTx, it sees the inscriptions written
+// earlier in the transaction before the commit, e.g. a deploy of the block being indexed. The query runs with the
+// context of dbTx. The CachedDBClient does not cache its result as it may never be committed.
+func (conn *DBClient) FindInscriptionByTickTx(dbTx *gorm.DB, chain, protocol, tick string) (*model.Inscriptions, error) {
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+
 	inscriptionBaseInfo := &model.Inscriptions{}
-	err := conn.SqlDB.First(inscriptionBaseInfo, "chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Error
+	err := dbTx.First(inscriptionBaseInfo, "chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -306,9 +1309,18 @@ func (conn *DBClient) FindInscriptionByTick(chain, protocol, tick string) (*mode
 }
 
 // FindInscriptionStatsInfoByBaseId find inscription stats info by base id
+//
+// Deprecated: inscriptions_stats has no ins_id column, look the stats up by the tick with FindInscriptionStatsByTick.
 func (conn *DBClient) FindInscriptionStatsInfoByBaseId(insId uint32) (*model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindInscriptionStatsInfoByBaseIdContext(ctx, insId)
+}
+
+// FindInscriptionStatsInfoByBaseIdContext is the context aware variant of FindInscriptionStatsInfoByBaseId.
+func (conn *DBClient) FindInscriptionStatsInfoByBaseIdContext(ctx context.Context, insId uint32) (*model.InscriptionsStats, error) {
 	inscriptionStats := &model.InscriptionsStats{}
-	err := conn.SqlDB.First(inscriptionStats, "ins_id = ?", insId).Error
+	err := conn.SqlDB.WithContext(ctx).First(inscriptionStats, "ins_id = ?", insId).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -320,9 +1332,76 @@ func (conn *DBClient) FindInscriptionStatsInfoByBaseId(insId uint32) (*model.Ins
 	return inscriptionStats, nil
 }
 
+// FindInscriptionBySID finds the inscription of the chain by its sid, soft deleted inscriptions are ignored
+func (conn *DBClient) FindInscriptionBySID(chain string, sid uint32) (*model.Inscriptions, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindInscriptionBySIDContext(ctx, chain, sid)
+}
+
+// FindInscriptionBySIDContext is the context aware variant of FindInscriptionBySID.
+func (conn *DBClient) FindInscriptionBySIDContext(ctx context.Context, chain string, sid uint32) (*model.Inscriptions, error) {
+	inscription := &model.Inscriptions{}
+	err := conn.SqlDB.WithContext(ctx).First(inscription, "chain = ? AND sid = ?", chain, sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return inscription, nil
+}
+
+// FindInscriptionStatsBySID finds the inscription stats of the chain by its sid
+func (conn *DBClient) FindInscriptionStatsBySID(chain string, sid uint32) (*model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindInscriptionStatsBySIDContext(ctx, chain, sid)
+}
+
+// FindInscriptionStatsBySIDContext is the context aware variant of FindInscriptionStatsBySID.
+func (conn *DBClient) FindInscriptionStatsBySIDContext(ctx context.Context, chain string, sid uint32) (*model.InscriptionsStats, error) {
+	stats := &model.InscriptionsStats{}
+	err := conn.SqlDB.WithContext(ctx).First(stats, "chain = ? AND sid = ?", chain, sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return stats, nil
+}
+
+// FindBalanceBySID finds the balance of the chain by its sid
+func (conn *DBClient) FindBalanceBySID(chain string, sid uint64) (*model.Balances, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindBalanceBySIDContext(ctx, chain, sid)
+}
+
+// FindBalanceBySIDContext is the context aware variant of FindBalanceBySID.
+func (conn *DBClient) FindBalanceBySIDContext(ctx context.Context, chain string, sid uint64) (*model.Balances, error) {
+	balance := &model.Balances{}
+	err := conn.SqlDB.WithContext(ctx).First(balance, "chain = ? AND sid = ?", chain, sid).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return balance, nil
+}
+
 func (conn *DBClient) FindUserBalanceByTick(chain, protocol, tick, addr string) (*model.Balances, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindUserBalanceByTickContext(ctx, chain, protocol, tick, addr)
+}
+
+// FindUserBalanceByTickContext is the context aware variant of FindUserBalanceByTick.
+func (conn *DBClient) FindUserBalanceByTickContext(ctx context.Context, chain, protocol, tick, addr string) (*model.Balances, error) {
 	balance := &model.Balances{}
-	err := conn.SqlDB.First(balance, "chain = ? AND protocol = ? AND tick = ? AND address = ?", chain, protocol, tick, addr).Error
+	err := conn.SqlDB.WithContext(ctx).First(balance, "chain = ? AND protocol = ? AND tick = ? AND address = ?", chain, protocol, tick, addr).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -332,9 +1411,57 @@ func (conn *DBClient) FindUserBalanceByTick(chain, protocol, tick, addr string)
 	return balance, nil
 }
 
+// GetBalancesByAddresses returns the balances of the tick of the addresses keyed by address, missing addresses are
+// absent. The addresses are de-duplicated and queried in chunks of findByHashesChunkSize.
+func (conn *DBClient) GetBalancesByAddresses(chain, protocol, tick string, addresses []string) (map[string]*model.Balances, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBalancesByAddressesContext(ctx, chain, protocol, tick, addresses)
+}
+
+// GetBalancesByAddressesContext is the context aware variant of GetBalancesByAddresses.
+func (conn *DBClient) GetBalancesByAddressesContext(ctx context.Context, chain, protocol, tick string, addresses []string) (
+	map[string]*model.Balances, error) {
+	unique := make([]string, 0, len(addresses))
+	seen := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		if _, ok := seen[address]; ok {
+			continue
+		}
+		seen[address] = struct{}{}
+		unique = append(unique, address)
+	}
+
+	balances := make(map[string]*model.Balances, len(unique))
+	for start := 0; start < len(unique); start += findByHashesChunkSize {
+		end := start + findByHashesChunkSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		var items []*model.Balances
+		err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND protocol = ? AND tick = ? AND address IN ?", chain, protocol, tick,
+			unique[start:end]).Find(&items).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			balances[item.Address] = item
+		}
+	}
+	return balances, nil
+}
+
 func (conn *DBClient) FindTransaction(chain string, hash string) (*model.Transaction, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindTransactionContext(ctx, chain, hash)
+}
+
+// FindTransactionContext is the context aware variant of FindTransaction.
+func (conn *DBClient) FindTransactionContext(ctx context.Context, chain string, hash string) (*model.Transaction, error) {
 	txn := &model.Transaction{}
-	err := conn.SqlDB.First(txn, "chain = ? AND tx_hash = ?", chain, hash).Error
+	err := conn.SqlDB.WithContext(ctx).First(txn, "chain = ? AND tx_hash = ?", chain, hash).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -344,130 +1471,1005 @@ func (conn *DBClient) FindTransaction(chain string, hash string) (*model.Transac
 	return txn, nil
 }
 
-func (conn *DBClient) GetInscriptions(limit, offset int, chain, protocol, tick, deployBy string, sort int, sortMode int) (
+// GetTransactionByPosition returns the transaction at the index of the block, nil when there is none.
+func (conn *DBClient) GetTransactionByPosition(chain string, blockNumber uint64, txIndex uint) (*model.Transaction, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetTransactionByPositionContext(ctx, chain, blockNumber, txIndex)
+}
+
+// GetTransactionByPositionContext is the context aware variant of GetTransactionByPosition.
+func (conn *DBClient) GetTransactionByPositionContext(ctx context.Context, chain string, blockNumber uint64, txIndex uint) (*model.Transaction, error) {
+	txn := &model.Transaction{}
+	err := conn.SqlDB.WithContext(ctx).
+		First(txn, "chain = ? AND block_height = ? AND position_in_block = ?", chain, blockNumber, uint64(txIndex)).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return txn, nil
+}
+
+// GetTransactionsByBlock pages the transactions of the block ordered by position in block then id, total is the
+// number of transactions of the block. It is served by the idx_txs_chain_block index.
+func (conn *DBClient) GetTransactionsByBlock(chain string, blockNumber uint64, limit, offset int) ([]*model.Transaction, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetTransactionsByBlockContext(ctx, chain, blockNumber, limit, offset)
+}
+
+// GetTransactionsByBlockContext is the context aware variant of GetTransactionsByBlock.
+func (conn *DBClient) GetTransactionsByBlockContext(ctx context.Context, chain string, blockNumber uint64, limit, offset int) ([]*model.Transaction, int64, error) {
+	var data []*model.Transaction
+	var total int64
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Transaction{}).Where("chain = ? AND block_height = ?", chain, blockNumber)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("position_in_block asc, id asc").Limit(limit).Offset(offset).Find(&data).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, total, nil
+}
+
+// GetTransfersBetween pages the transfer txs of the tick sent by from to to, newest first. An empty from or to matches
+// any address, total is the number of the matching transfers.
+func (conn *DBClient) GetTransfersBetween(chain, protocol, tick, from, to string, limit, offset int) ([]*model.Transaction, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetTransfersBetweenContext(ctx, chain, protocol, tick, from, to, limit, offset)
+}
+
+// GetTransfersBetweenContext is the context aware variant of GetTransfersBetween.
+func (conn *DBClient) GetTransfersBetweenContext(ctx context.Context, chain, protocol, tick, from, to string, limit, offset int) (
+	[]*model.Transaction, int64, error) {
+	var data []*model.Transaction
+	var total int64
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Transaction{}).
+		Where("chain = ? AND protocol = ? AND tick = ? AND op = ?", chain, protocol, tick, "transfer")
+	if from != "" {
+		query = query.Where(conn.quote("from")+" = ?", from)
+	}
+	if to != "" {
+		query = query.Where(conn.quote("to")+" = ?", to)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("block_height desc, position_in_block desc, id desc").Limit(limit).Offset(offset).Find(&data).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, total, nil
+}
+
+// FindTransactionsByHashes returns the stored transactions of the hashes keyed by hash, missing hashes are absent.
+// The hashes are de-duplicated and queried in chunks of findByHashesChunkSize to stay below the placeholder limits.
+func (conn *DBClient) FindTransactionsByHashes(chain string, hashes []string) (map[string]*model.Transaction, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindTransactionsByHashesContext(ctx, chain, hashes)
+}
+
+// FindTransactionsByHashesContext is the context aware variant of FindTransactionsByHashes.
+func (conn *DBClient) FindTransactionsByHashesContext(ctx context.Context, chain string, hashes []string) (map[string]*model.Transaction, error) {
+	unique := make([]string, 0, len(hashes))
+	seen := make(map[string]struct{}, len(hashes))
+	for _, hash := range hashes {
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+		unique = append(unique, hash)
+	}
+
+	txs := make(map[string]*model.Transaction, len(unique))
+	for start := 0; start < len(unique); start += findByHashesChunkSize {
+		end := start + findByHashesChunkSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+
+		var items []*model.Transaction
+		err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND tx_hash IN ?", chain, unique[start:end]).Find(&items).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			txs[item.TxHash] = item
+		}
+	}
+	return txs, nil
+}
+
+// GetInscriptionsStatsBySIDs returns the stats of the chain with the sids keyed by sid, missing sids are absent.
+// The sid is only unique per chain. The sids are de-duplicated and queried in chunks of findByHashesChunkSize.
+func (conn *DBClient) GetInscriptionsStatsBySIDs(chain string, sids []uint32) (map[uint32]*model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsStatsBySIDsContext(ctx, chain, sids)
+}
+
+// GetInscriptionsStatsBySIDsContext is the context aware variant of GetInscriptionsStatsBySIDs.
+func (conn *DBClient) GetInscriptionsStatsBySIDsContext(ctx context.Context, chain string, sids []uint32) (
+	map[uint32]*model.InscriptionsStats, error) {
+	unique := make([]uint32, 0, len(sids))
+	seen := make(map[uint32]struct{}, len(sids))
+	for _, sid := range sids {
+		if _, ok := seen[sid]; ok {
+			continue
+		}
+		seen[sid] = struct{}{}
+		unique = append(unique, sid)
+	}
+
+	stats := make(map[uint32]*model.InscriptionsStats, len(unique))
+	for _, chunk := range chunks(unique, findByHashesChunkSize) {
+		var items []*model.InscriptionsStats
+		err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND sid IN ?", chain, chunk).Find(&items).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			stats[item.SID] = item
+		}
+	}
+	return stats, nil
+}
+
+// GetInscriptions pages the inscriptions of the filter. With a SinceDeployTime and SortByDeployTime it is the feed of
+// the new tokens.
+func (conn *DBClient) GetInscriptions(limit, offset int, filter InscriptionFilter, sort SortField, sortMode int) (
 	[]*model.InscriptionOverView, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsContext(ctx, limit, offset, filter, sort, sortMode)
+}
+
+// GetInscriptionsContext is the context aware variant of GetInscriptions.
+func (conn *DBClient) GetInscriptionsContext(ctx context.Context, limit, offset int, filter InscriptionFilter, sort SortField,
+	sortMode int) (_ []*model.InscriptionOverView, _ int64, err error) {
+	defer conn.observe("GetInscriptions", time.Now(), &err)
 
 	var data []*model.InscriptionOverView
 	var total int64
+	query, err := conn.inscriptionsFilterQuery(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	query = query.Select(inscriptionOverViewFields)
+
+	if total, err = pageInscriptions(query, limit, offset, sort, sortMode, &data); err != nil {
+		return nil, 0, err
+	}
+	return data, total, nil
+}
+
+// GetInscriptionsWithDeployer pages the inscriptions of the filter like GetInscriptions along with the current balance
+// of their deployer in the tick, for the token details. A deployer having transferred out all the tokens or never
+// holding any has a zero balance.
+func (conn *DBClient) GetInscriptionsWithDeployer(limit, offset int, filter InscriptionFilter, sort SortField, sortMode int) (
+	[]*model.InscriptionDeployerView, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsWithDeployerContext(ctx, limit, offset, filter, sort, sortMode)
+}
+
+// GetInscriptionsWithDeployerContext is the context aware variant of GetInscriptionsWithDeployer.
+func (conn *DBClient) GetInscriptionsWithDeployerContext(ctx context.Context, limit, offset int, filter InscriptionFilter,
+	sort SortField, sortMode int) (_ []*model.InscriptionDeployerView, _ int64, err error) {
+	defer conn.observe("GetInscriptionsWithDeployer", time.Now(), &err)
+
+	query, err := conn.inscriptionsFilterQuery(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	// a balance row is unique per address & tick, the join keeps one row per inscription
+	query = query.Joins("left join " + conn.table(model.Balances{}) + " as db on (db.chain = a.chain and db.protocol = a.protocol" +
+		" and db.tick = a.tick and db.address = a.deploy_by)").
+		Select(inscriptionOverViewFields + ", COALESCE(db.balance, 0) as deployer_balance")
+
+	var data []*model.InscriptionDeployerView
+	total, err := pageInscriptions(query, limit, offset, sort, sortMode, &data)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, item := range data {
+		item.DeployerHolds = item.DeployerBalance.IsPositive()
+	}
+	return data, total, nil
+}
+
+// pageInscriptions applies the sort to the inscriptions query, counts the matching rows and scans the page into dest
+func pageInscriptions(query *gorm.DB, limit, offset int, sort SortField, sortMode int, dest interface{}) (int64, error) {
+	order, err := inscriptionSortOrder(sort)
+	if err != nil {
+		return 0, err
+	}
+	// sort mode 1: asc 2: desc, the fields of a fixed direction ignore it
+	if !order.fixed {
+		order.desc = sortMode != OrderByModeAsc
+	}
+	query = order.apply(query)
+
+	var total int64
+	query = query.Count(&total)
+	if err = query.Limit(limit).Offset(offset).Find(dest).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// InscriptionFilter the filters of the inscriptions listings, the zero values match all the inscriptions.
+// Chain and Chains both apply when both are set. Tick is an exact match, TickLike matches the tick prefix with the LIKE
+// wildcards in it matched literally.
+type InscriptionFilter struct {
+	Chain      string
+	Chains     []string
+	Protocol   string
+	Tick       string
+	TickLike   string
+	DeployBy   string
+	MintStatus int    // one of the MintStatus* filters
+	FromMinted string // inclusive decimal bounds of the minted amount, empty for unbounded
+	ToMinted   string
+
+	SinceDeployTime time.Time // the deploys at or after the time, zero for unbounded
+}
+
+// inscriptionsFilterQuery the inscriptions & inscriptions_stats join with the predicates of the filter
+func (conn *DBClient) inscriptionsFilterQuery(ctx context.Context, filter InscriptionFilter) (*gorm.DB, error) {
+	query := conn.inscriptionsQuery(ctx, filter.Chain, filter.Protocol, filter.Tick, filter.DeployBy)
+	if len(filter.Chains) > 0 {
+		query = unprepared(query).Where("a.chain IN ?", filter.Chains)
+	}
+	if !filter.SinceDeployTime.IsZero() {
+		query = query.Where("a.deploy_time >= ?", filter.SinceDeployTime)
+	}
+	if filter.TickLike != "" {
+		// the case sensitivity follows the column collation, sqlite LIKE ignores the case of ascii letters
+		// the escape character is bound as well, the quoting of a backslash literal differs between mysql and postgres
+		query = query.Where("a.tick LIKE ? ESCAPE ?", utils.EscapeLike(filter.TickLike)+"%", utils.LikeEscapeChar)
+	}
+
+	switch filter.MintStatus {
+	case MintStatusAll:
+	case MintStatusMinting:
+		query = query.Where("NOT " + inscriptionMintCompletedExpr)
+	case MintStatusCompleted:
+		query = query.Where(inscriptionMintCompletedExpr)
+	default:
+		return nil, fmt.Errorf("invalid mint status[%d]", filter.MintStatus)
+	}
+
+	// missing stats count as nothing minted like in the progress
+	return conn.whereDecimalRange(query, "COALESCE(d.minted, 0)", filter.FromMinted, filter.ToMinted)
+}
+
+// InscriptionFacet the column the inscriptions are counted by in GetInscriptionFacets
+type InscriptionFacet int
+
+const (
+	FacetByChain    InscriptionFacet = 0
+	FacetByProtocol InscriptionFacet = 1
+)
+
+// inscriptionFacetColumns the grouped column of the facets
+var inscriptionFacetColumns = map[InscriptionFacet]string{
+	FacetByChain:    "a.chain",
+	FacetByProtocol: "a.protocol",
+}
+
+// GetInscriptionFacets counts the inscriptions matching the filter by chain or by protocol in a single query, the
+// values without inscriptions are absent.
+func (conn *DBClient) GetInscriptionFacets(filter InscriptionFilter, facet InscriptionFacet) (map[string]int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetInscriptionFacetsContext(ctx, filter, facet)
+}
+
+// GetInscriptionFacetsContext is the context aware variant of GetInscriptionFacets.
+func (conn *DBClient) GetInscriptionFacetsContext(ctx context.Context, filter InscriptionFilter, facet InscriptionFacet) (
+	_ map[string]int64, err error) {
+	defer conn.observe("GetInscriptionFacets", time.Now(), &err)
+
+	column, ok := inscriptionFacetColumns[facet]
+	if !ok {
+		return nil, fmt.Errorf("invalid facet[%d]", facet)
+	}
+	query, err := conn.inscriptionsFilterQuery(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []*struct {
+		Value string
+		Cnt   int64
+	}
+	if err = query.Select(column + " as value, COUNT(*) as cnt").Group(column).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	facets := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		facets[row.Value] = row.Cnt
+	}
+	return facets, nil
+}
 
-	query := conn.SqlDB.Select("*, (d.minted / a.total_supply) as progress").Table("inscriptions as a").
-		Joins("left join `inscriptions_stats` as d on (`a`.chain = `d`.chain and `a`.protocol = `d`.protocol and `a`.tick = `d`.tick)")
+// GetInscriptionsByCursor pages the inscriptions by keyset instead of offset. lastId is the id of the last row of the
+// previous page (0 for the first page) and the returned cursor is the lastId for the next page, 0 when there are no
+// more rows. Rows are sorted in the direction of the sort field with id as the tiebreaker.
+// The total count is omitted in cursor mode to avoid the expensive COUNT.
+func (conn *DBClient) GetInscriptionsByCursor(lastId uint64, limit int, chain, protocol, tick, deployBy string, sort SortField) (
+	[]*model.InscriptionOverView, uint64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsByCursorContext(ctx, lastId, limit, chain, protocol, tick, deployBy, sort)
+}
+
+// GetInscriptionsByCursorContext is the context aware variant of GetInscriptionsByCursor.
+func (conn *DBClient) GetInscriptionsByCursorContext(ctx context.Context, lastId uint64, limit int, chain, protocol, tick, deployBy string,
+	sort SortField) ([]*model.InscriptionOverView, uint64, error) {
+	order, err := inscriptionSortOrder(sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := make([]*model.InscriptionOverView, 0)
+	query := conn.inscriptionsQuery(ctx, chain, protocol, tick, deployBy).Select(inscriptionOverViewFields)
+
+	if lastId > 0 {
+		cmp := "<"
+		if !order.desc {
+			cmp = ">"
+		}
+
+		if order.column == "a.id" {
+			query = query.Where("a.id "+cmp+" ?", lastId)
+		} else {
+			// the sort value of the last row, the keyset is (sort value, id)
+			var last interface{}
+			lastQuery := conn.inscriptionsQuery(ctx, "", "", "", "").Select(order.column).Where("a.id = ?", lastId)
+			if err = scanFirst(lastQuery, &last); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return data, 0, nil
+				}
+				return nil, 0, err
+			}
+			query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND a.id %s ?))", order.column, cmp, order.column, cmp), last, last, lastId)
+		}
+	}
+
+	err = order.apply(query).Limit(limit).Find(&data).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var cursor uint64
+	if len(data) > 0 && len(data) == limit {
+		cursor = uint64(data[len(data)-1].ID)
+	}
+	return data, cursor, nil
+}
+
+// GetTickMarketStats returns the market rollup of the tick, nil when the tick does not exist
+func (conn *DBClient) GetTickMarketStats(chain, protocol, tick string) (*model.TickMarketStats, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetTickMarketStatsContext(ctx, chain, protocol, tick)
+}
+
+// GetTickMarketStatsContext is the context aware variant of GetTickMarketStats.
+func (conn *DBClient) GetTickMarketStatsContext(ctx context.Context, chain, protocol, tick string) (*model.TickMarketStats, error) {
+	stats := &model.TickMarketStats{}
+	err := conn.inscriptionsQuery(ctx, chain, protocol, tick, "").
+		Select("a.chain, a.protocol, a.tick, a.total_supply, a.deploy_time, d.minted, d.holders, d.tx_cnt, d.mint_completed_time, " +
+			inscriptionProgressExpr + " as progress").
+		Take(stats).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if stats.CirculatingSupply, err = conn.circulatingSupply(ctx, chain, protocol, tick, nil); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetProtocolSummary aggregates the deployed ticks, the minted amount and the holders of all ticks of the protocol
+func (conn *DBClient) GetProtocolSummary(chain, protocol string) (*model.ProtocolSummary, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetProtocolSummaryContext(ctx, chain, protocol)
+}
+
+// GetProtocolSummaryContext is the context aware variant of GetProtocolSummary.
+func (conn *DBClient) GetProtocolSummaryContext(ctx context.Context, chain, protocol string) (*model.ProtocolSummary, error) {
+	summary := &model.ProtocolSummary{}
+	err := conn.inscriptionsQuery(ctx, chain, protocol, "", "").
+		Select("COUNT(a.id) as ticks, COALESCE(" + conn.decimalSum("d.minted") + ", 0) as minted, COALESCE(SUM(d.holders), 0) as holders").
+		Take(summary).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Chain = chain
+	summary.Protocol = protocol
+	return summary, nil
+}
+
+// GetIndexedChains returns the chains with deployed inscriptions in alphabetical order
+func (conn *DBClient) GetIndexedChains() ([]string, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetIndexedChainsContext(ctx)
+}
+
+// GetIndexedChainsContext is the context aware variant of GetIndexedChains.
+func (conn *DBClient) GetIndexedChainsContext(ctx context.Context) ([]string, error) {
+	chains := make([]string, 0)
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Inscriptions{}).Distinct("chain").Order("chain").Pluck("chain", &chains).Error
+	if err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// GetIndexedProtocols returns the protocols with deployed inscriptions on the chain in alphabetical order
+func (conn *DBClient) GetIndexedProtocols(chain string) ([]string, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetIndexedProtocolsContext(ctx, chain)
+}
+
+// GetIndexedProtocolsContext is the context aware variant of GetIndexedProtocols.
+func (conn *DBClient) GetIndexedProtocolsContext(ctx context.Context, chain string) ([]string, error) {
+	protocols := make([]string, 0)
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Inscriptions{}).Where("chain = ?", chain).
+		Distinct("protocol").Order("protocol").Pluck("protocol", &protocols).Error
+	if err != nil {
+		return nil, err
+	}
+	return protocols, nil
+}
+
+// decimalSum the SUM expression of a decimal column keeping the full precision of the amounts,
+// sqlite has no exact decimal type and sums the numeric values as they are.
+func (conn *DBClient) decimalSum(column string) string {
+	switch conn.SqlDB.Dialector.Name() {
+	case DatabaseTypeMysql:
+		return "SUM(CAST(" + column + " AS DECIMAL(65,18)))"
+	case DatabaseTypePostgres:
+		return "SUM(CAST(" + column + " AS NUMERIC))"
+	}
+	return "SUM(" + column + ")"
+}
+
+// decimalParam the placeholder of a decimal bound as a string and cast to the exact decimal type of the database. A bound
+// decimal would be compared as a float by mysql and a text is never equal to a number on sqlite.
+func (conn *DBClient) decimalParam() string {
+	if conn.SqlDB.Dialector.Name() == DatabaseTypeMysql {
+		return "CAST(? AS DECIMAL(65,18))"
+	}
+	return "CAST(? AS NUMERIC)"
+}
+
+// whereDecimalRange filters the decimal expression by the inclusive bounds, an empty bound is unbounded.
+func (conn *DBClient) whereDecimalRange(query *gorm.DB, expr, from, to string) (*gorm.DB, error) {
+	var lower, upper decimal.Decimal
+	var err error
+	if from != "" {
+		if lower, err = decimal.NewFromString(from); err != nil {
+			return nil, fmt.Errorf("invalid decimal bound[%s]", from)
+		}
+		query = query.Where(expr+" >= "+conn.decimalParam(), lower.String())
+	}
+	if to != "" {
+		if upper, err = decimal.NewFromString(to); err != nil {
+			return nil, fmt.Errorf("invalid decimal bound[%s]", to)
+		}
+		if from != "" && upper.LessThan(lower) {
+			return nil, fmt.Errorf("invalid decimal range[%s, %s]", from, to)
+		}
+		query = query.Where(expr+" <= "+conn.decimalParam(), upper.String())
+	}
+	return query, nil
+}
+
+// inscriptionMintCompletedExpr whether the mint of the inscriptions & inscriptions_stats join is completed, either
+// marked by the indexer or the minted amount reached the total supply. A zero total supply is never completed by the
+// amount and missing stats count as nothing minted, the expression is never NULL. The decimal columns compare exactly
+// on mysql and postgres, sqlite compares them as floating point numbers.
+const inscriptionMintCompletedExpr = "(d.mint_completed_time IS NOT NULL OR (a.total_supply > 0 AND COALESCE(d.minted, 0) >= a.total_supply))"
+
+// inscriptionProgressExpr the minted progress of the inscriptions & inscriptions_stats join,
+// the 1.0 factor avoids the integer division of sqlite when both amounts are integral.
+// A zero total supply or missing stats yield 0 instead of a division error (mysql strict mode) or NULL.
+const inscriptionProgressExpr = "COALESCE(CASE WHEN a.total_supply > 0 THEN d.minted * 1.0 / a.total_supply END, 0)"
+
+// inscriptionOverViewFields the InscriptionOverView columns of the inscriptions & inscriptions_stats join
+const inscriptionOverViewFields = "a.*, d.minted, d.holders, d.tx_cnt, " + inscriptionProgressExpr + " as progress"
+
+// inscriptionsQuery the inscriptions & inscriptions_stats join with the common filters
+func (conn *DBClient) inscriptionsQuery(ctx context.Context, chain, protocol, tick, deployBy string) *gorm.DB {
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.Inscriptions{}) + " as a").
+		Joins("left join " + conn.table(model.InscriptionsStats{}) + " as d on (a.chain = d.chain and a.protocol = d.protocol and a.tick = d.tick)").
+		Where("a.deleted_at IS NULL")
 	if chain != "" {
-		query = query.Where("`a`.chain = ?", chain)
+		query = query.Where("a.chain = ?", chain)
 	}
 	if protocol != "" {
-		query = query.Where("`a`.protocol = ?", protocol)
+		query = query.Where("a.protocol = ?", protocol)
+	}
+	if tick != "" {
+		query = query.Where("a.tick = ?", tick)
+	}
+	if deployBy != "" {
+		query = query.Where("a.deploy_by = ?", deployBy)
+	}
+	return query
+}
+
+// inscriptionSort the order by expression of a sort field and its direction
+type inscriptionSort struct {
+	column string
+	desc   bool
+	fixed  bool // the direction ignores the sort mode, e.g. SortByDeployTimeAsc
+}
+
+// inscriptionSortFields the registry of the sort fields, a new sort only needs an entry here
+var inscriptionSortFields = map[SortField]inscriptionSort{
+	SortById:            {column: "a.id", desc: true},
+	SortByDeployTime:    {column: "a.deploy_time", desc: true},
+	SortByProgress:      {column: inscriptionProgressExpr, desc: true},
+	SortByHolders:       {column: "COALESCE(d.holders, 0)", desc: true},
+	SortByTxCnt:         {column: "COALESCE(d.tx_cnt, 0)", desc: true},
+	SortByMinted:        {column: "COALESCE(d.minted, 0)", desc: true},
+	SortByDeployTimeAsc: {column: "a.deploy_time", desc: false, fixed: true},
+}
+
+// inscriptionSortOrder the sort of the field, an error for unknown fields
+func inscriptionSortOrder(sort SortField) (inscriptionSort, error) {
+	order, ok := inscriptionSortFields[sort]
+	if !ok {
+		return inscriptionSort{}, fmt.Errorf("invalid sort field[%d]", sort)
+	}
+	return order, nil
+}
+
+// apply orders the query by the sort, id in the same direction is the tiebreaker
+func (s inscriptionSort) apply(query *gorm.DB) *gorm.DB {
+	dir := " desc"
+	if !s.desc {
+		dir = " asc"
+	}
+	if s.column != "a.id" {
+		query = query.Order(s.column + dir)
+	}
+	return query.Order("a.id" + dir)
+}
+
+// GetRowsByIdLimit pages the table of the model T by id, the rows with id > start in ascending id order.
+// conds add the table specific filters, e.g. WhereChain.
+func GetRowsByIdLimit[T any](conn *DBClient, start uint64, limit int, conds ...func(*gorm.DB) *gorm.DB) ([]T, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return GetRowsByIdLimitContext[T](ctx, conn, start, limit, conds...)
+}
+
+// GetRowsByIdLimitContext is the context aware variant of GetRowsByIdLimit.
+func GetRowsByIdLimitContext[T any](ctx context.Context, conn *DBClient, start uint64, limit int, conds ...func(*gorm.DB) *gorm.DB) ([]T, error) {
+	rows := make([]T, 0)
+	err := conn.SqlDB.WithContext(ctx).Scopes(conds...).Where("id > ?", start).Order("id asc").Limit(limit).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// WhereChain filters the rows of the chain
+func WhereChain(chain string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("chain = ?", chain)
+	}
+}
+
+// WhereUTXOUnspent filters the unspent utxos
+func WhereUTXOUnspent(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ?", model.UTXOStatusUnspent)
+}
+
+func (conn *DBClient) GetInscriptionsByIdLimit(chain string, start uint64, limit int) ([]model.Inscriptions, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsByIdLimitContext(ctx, chain, start, limit)
+}
+
+// GetInscriptionsByIdLimitContext is the context aware variant of GetInscriptionsByIdLimit.
+func (conn *DBClient) GetInscriptionsByIdLimitContext(ctx context.Context, chain string, start uint64, limit int) ([]model.Inscriptions, error) {
+	return GetRowsByIdLimitContext[model.Inscriptions](ctx, conn, start, limit, WhereChain(chain))
+}
+
+func (conn *DBClient) GetInscriptionStatsByIdLimit(chain string, start uint64, limit int) ([]model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionStatsByIdLimitContext(ctx, chain, start, limit)
+}
+
+// GetInscriptionStatsByIdLimitContext is the context aware variant of GetInscriptionStatsByIdLimit.
+func (conn *DBClient) GetInscriptionStatsByIdLimitContext(ctx context.Context, chain string, start uint64, limit int) ([]model.InscriptionsStats, error) {
+	return GetRowsByIdLimitContext[model.InscriptionsStats](ctx, conn, start, limit, WhereChain(chain))
+}
+
+// GetInscriptionsByAddress returns the balance rows of the address, i.e. the inscriptions the address holds or held.
+// The address is required, an empty address would page through the balances of every address.
+func (conn *DBClient) GetInscriptionsByAddress(limit, offset int, address string) ([]*model.Balances, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsByAddressContext(ctx, limit, offset, address)
+}
+
+// GetInscriptionsByAddressContext is the context aware variant of GetInscriptionsByAddress.
+func (conn *DBClient) GetInscriptionsByAddressContext(ctx context.Context, limit, offset int, address string) ([]*model.Balances, error) {
+	if address == "" {
+		return nil, errors.New("address is required")
+	}
+
+	balances := make([]*model.Balances, 0)
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).Where("address = ?", address)
+	result := query.Order("id desc").Limit(limit).Offset(offset).Find(&balances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return balances, nil
+}
+
+// TxRangeFilter bounds the transactions by block height and creation time (both inclusive),
+// zero values leave that side unbounded
+type TxRangeFilter struct {
+	FromBlock uint64
+	ToBlock   uint64
+	FromTime  time.Time
+	ToTime    time.Time
+}
+
+// apply adds the range predicates of the filter on the txs table alias
+func (f TxRangeFilter) apply(query *gorm.DB, alias string) *gorm.DB {
+	if f.FromBlock > 0 {
+		query = query.Where(alias+".block_height >= ?", f.FromBlock)
+	}
+	if f.ToBlock > 0 {
+		query = query.Where(alias+".block_height <= ?", f.ToBlock)
+	}
+	if !f.FromTime.IsZero() {
+		query = query.Where(alias+".created_at >= ?", f.FromTime)
+	}
+	if !f.ToTime.IsZero() {
+		query = query.Where(alias+".created_at <= ?", f.ToTime)
+	}
+	return query
+}
+
+// GetTransactionsByAddress pages the transactions of the address in the order of the sort, event 0 matches all events.
+func (conn *DBClient) GetTransactionsByAddress(limit, offset int, address, chain, protocol, tick, key string, event model.TxEvent, filter TxRangeFilter,
+	sort TxSort) ([]*model.AddressTransaction, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetTransactionsByAddressContext(ctx, limit, offset, address, chain, protocol, tick, key, event, filter, sort)
+}
+
+// GetTransactionsByAddressContext is the context aware variant of GetTransactionsByAddress.
+func (conn *DBClient) GetTransactionsByAddressContext(ctx context.Context, limit, offset int, address, chain, protocol, tick, key string,
+	event model.TxEvent, filter TxRangeFilter, sort TxSort) (_ []*model.AddressTransaction, _ int64, err error) {
+	defer conn.observe("GetTransactionsByAddress", time.Now(), &err)
+
+	if event != 0 && !event.Valid() {
+		return nil, 0, fmt.Errorf("invalid event[%d]", event)
+	}
+	orderBy, err := txSortOrder(sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var data []*model.AddressTransaction
+	var total int64
+
+	query := conn.SqlDB.WithContext(ctx).Select("*").Table(conn.table(model.Transaction{})+" as t").
+		Joins("left join "+conn.table(model.AddressTxs{})+" as a on (t.tx_hash = a.tx_hash and t.chain = a.chain and t.protocol = a.protocol and t.tick = a.tick)").
+		Where("a.address = ?", address)
+
+	if chain != "" {
+		query = query.Where("a.chain = ?", chain)
+	}
+	if protocol != "" {
+		query = query.Where("a.protocol = ?", protocol)
+	}
+	if tick != "" {
+		query = query.Where("a.tick = ?", tick)
+	}
+	if key != "" {
+		query = query.Where("a.tick like ?", "%"+key+"%")
+	}
+	if event > 0 {
+		query = query.Where("a.event = ?", event)
+	}
+	query = filter.apply(query, "t")
+
+	query = query.Count(&total)
+	result := query.Order(orderBy).Limit(limit).Offset(offset).Find(&data)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return data, total, nil
+}
+
+// txSortOrder the order by clause of the address txs sort, the address tx id is the tiebreaker. An error for unknown sorts.
+// The amounts sort exactly on the decimal column of mysql and postgres, sqlite sorts them as floating point numbers.
+func txSortOrder(sort TxSort) (string, error) {
+	switch sort {
+	case TxSortNewest:
+		return "a.id desc", nil
+	case TxSortOldest:
+		return "a.id asc", nil
+	case TxSortAmountDesc:
+		return "t.amt desc, a.id desc", nil
+	}
+	return "", fmt.Errorf("invalid tx sort[%d]", sort)
+}
+
+// GetBalanceHistory pages the balance changes of the address for the tick in chronological order, the amount of
+// each entry is the signed delta and balance is the balance after it. The filter bounds the txs of the changes.
+func (conn *DBClient) GetBalanceHistory(chain, protocol, tick, address string, limit, offset int, filter TxRangeFilter) (
+	[]*model.BalanceTxn, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBalanceHistoryContext(ctx, chain, protocol, tick, address, limit, offset, filter)
+}
+
+// GetBalanceHistoryContext is the context aware variant of GetBalanceHistory.
+func (conn *DBClient) GetBalanceHistoryContext(ctx context.Context, chain, protocol, tick, address string, limit, offset int,
+	filter TxRangeFilter) ([]*model.BalanceTxn, int64, error) {
+	var data []*model.BalanceTxn
+	var total int64
+
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.BalanceTxn{})+" as b").
+		Joins("inner join "+conn.table(model.Transaction{})+" as t on (t.tx_hash = b.tx_hash and t.chain = b.chain and t.protocol = b.protocol and t.tick = b.tick)").
+		Where("b.chain = ? and b.protocol = ? and b.tick = ? and b.address = ?", chain, protocol, tick, address)
+	query = filter.apply(query, "t")
+
+	query = query.Count(&total)
+	result := query.Select("b.*").Order("t.block_height asc, t.position_in_block asc, b.id asc").Limit(limit).Offset(offset).Find(&data)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	return data, total, nil
+}
+
+// GetBalanceAtBlock reconstructs the balance of the address for the tick as of the end of the block, the sum of the
+// signed deltas of its balance changes up to and including the block. The block of a change is the height of its tx
+// stored on the balance tx. The deltas are summed as decimals in process, a SUM on sqlite would add up their floating point values.
+func (conn *DBClient) GetBalanceAtBlock(chain, protocol, tick, address string, blockNumber uint64) (string, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetBalanceAtBlockContext(ctx, chain, protocol, tick, address, blockNumber)
+}
+
+// GetBalanceAtBlockContext is the context aware variant of GetBalanceAtBlock.
+func (conn *DBClient) GetBalanceAtBlockContext(ctx context.Context, chain, protocol, tick, address string, blockNumber uint64) (string, error) {
+	rows, err := conn.SqlDB.WithContext(ctx).Table(conn.table(model.BalanceTxn{})+" as b").
+		Where("b.chain = ? and b.protocol = ? and b.tick = ? and b.address = ? and b.block_number <= ?", chain, protocol, tick, address, blockNumber).
+		Select("b.amount").Rows()
+	if err != nil {
+		return "", err
 	}
-	if tick != "" {
-		query = query.Where("`a`.tick = ?", tick)
+	defer rows.Close()
+
+	balance := decimal.Zero
+	for rows.Next() {
+		var amount decimal.Decimal
+		if err = rows.Scan(&amount); err != nil {
+			return "", err
+		}
+		balance = balance.Add(amount)
 	}
-	if deployBy != "" {
-		query = query.Where("`a`.deploy_by = ?", deployBy)
+	if err = rows.Err(); err != nil {
+		return "", err
 	}
+	return balance.String(), nil
+}
 
-	// sort mode 1: asc 2: desc
-	mode := "desc"
-	if sortMode == OrderByModeAsc {
-		mode = "asc"
+// GetInscriptionsByDeployBlockRange returns the inscriptions of the chain deployed within [fromBlock, toBlock] ordered by
+// the deploy block then id, a zero toBlock leaves the range open ended. The deploy block is the height of the deploy tx.
+func (conn *DBClient) GetInscriptionsByDeployBlockRange(chain string, fromBlock, toBlock uint64) ([]*model.Inscriptions, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsByDeployBlockRangeContext(ctx, chain, fromBlock, toBlock)
+}
+
+// GetInscriptionsByDeployBlockRangeContext is the context aware variant of GetInscriptionsByDeployBlockRange.
+func (conn *DBClient) GetInscriptionsByDeployBlockRangeContext(ctx context.Context, chain string, fromBlock, toBlock uint64) (
+	[]*model.Inscriptions, error) {
+	if toBlock > 0 && toBlock < fromBlock {
+		return nil, fmt.Errorf("invalid block range[%d, %d]", fromBlock, toBlock)
 	}
 
-	// sort by  0.id  1.deploy_time  2.progress  3.holders  4.tx_cnt
-	switch sort {
-	case SortTypeId:
-		query = query.Order("`a`.id " + mode)
-	case SortTypeDeployTime:
-		query = query.Order("deploy_time " + mode)
-	case SortTpyeProgress:
-		query = query.Order("progress " + mode)
-	case SortTypeHolders:
-		query = query.Order("holders " + mode)
-	case SortTypeTxCnt:
-		query = query.Order("tx_cnt " + mode)
+	var data []*model.Inscriptions
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.Inscriptions{})+" as a").
+		Joins("inner join "+conn.table(model.Transaction{})+" as t on (t.tx_hash = a.deploy_hash and t.chain = a.chain and t.op = ?)", "deploy").
+		Where("a.chain = ? and a.deleted_at IS NULL and t.block_height >= ?", chain, fromBlock)
+	if toBlock > 0 {
+		query = query.Where("t.block_height <= ?", toBlock)
 	}
 
-	query = query.Count(&total)
-	result := query.Limit(limit).Offset(offset).Find(&data)
+	result := query.Select("a.*").Order("t.block_height asc, a.id asc").Find(&data)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return nil, result.Error
 	}
+	return data, nil
+}
 
-	return data, total, nil
+// GetAddressStats returns the activity summary of the address on the chain, zero values when it has no activity
+func (conn *DBClient) GetAddressStats(chain, address string) (*model.AddressStats, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetAddressStatsContext(ctx, chain, address)
 }
 
-func (conn *DBClient) GetInscriptionsByIdLimit(chain string, start uint64, limit int) ([]model.Inscriptions, error) {
-	inscriptions := make([]model.Inscriptions, 0)
-	err := conn.SqlDB.Where("chain = ?", chain).Where("id > ?", start).Order("id asc").Limit(limit).Find(&inscriptions).Error
+// GetAddressStatsContext is the context aware variant of GetAddressStats.
+func (conn *DBClient) GetAddressStatsContext(ctx context.Context, chain, address string) (*model.AddressStats, error) {
+	// balances are unique by (address, chain, protocol, tick), every row is a distinct tick
+	stats := &model.AddressStats{Chain: chain, Address: address}
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).
+		Where("chain = ? AND address = ? AND balance > 0", chain, address).
+		Count(&stats.Ticks).Error
 	if err != nil {
 		return nil, err
 	}
-	return inscriptions, nil
-}
 
-func (conn *DBClient) GetInscriptionStatsByIdLimit(chain string, start uint64, limit int) ([]model.InscriptionsStats, error) {
-	stats := make([]model.InscriptionsStats, 0)
-	err := conn.SqlDB.Where("chain = ?", chain).Where("id > ?", start).Order("id asc").Limit(limit).Find(&stats).Error
+	err = conn.SqlDB.WithContext(ctx).Table(conn.table(model.AddressTxs{})+" as a").
+		Joins("left join "+conn.table(model.Transaction{})+" as t on (t.tx_hash = a.tx_hash and t.chain = a.chain)").
+		Select("COUNT(DISTINCT a.tx_hash) as tx_cnt, COALESCE(MIN(t.block_height), 0) as first_block, COALESCE(MAX(t.block_height), 0) as last_block").
+		Where("a.chain = ? AND a.address = ?", chain, address).
+		Take(stats).Error
 	if err != nil {
 		return nil, err
 	}
 	return stats, nil
 }
 
-func (conn *DBClient) GetInscriptionsByAddress(limit, offset int, address string) ([]*model.Balances, error) {
-	balances := make([]*model.Balances, 0)
+// GetActivityMetrics counts the txs of the chain and the distinct addresses involved in them with a block time at or
+// after since, e.g. the activity of the last 24 hours. The block time is used instead of created_at so a backfill
+// does not report the old blocks as recent activity.
+func (conn *DBClient) GetActivityMetrics(chain string, since time.Time) (*model.ActivityMetrics, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetActivityMetricsContext(ctx, chain, since)
+}
 
-	query := conn.SqlDB.Model(&model.Balances{})
-	if address != "" {
-		query = query.Where("`address` = ?", address)
+// GetActivityMetricsContext is the context aware variant of GetActivityMetrics.
+func (conn *DBClient) GetActivityMetricsContext(ctx context.Context, chain string, since time.Time) (*model.ActivityMetrics, error) {
+	metrics := &model.ActivityMetrics{Chain: chain, Since: since}
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Transaction{}).
+		Where("chain = ? AND block_time >= ?", chain, since).
+		Count(&metrics.TxCnt).Error
+	if err != nil {
+		return nil, err
 	}
 
-	result := query.Order("id desc").Limit(limit).Offset(offset).Find(&balances)
-	if result.Error != nil {
-		return nil, result.Error
+	err = conn.SqlDB.WithContext(ctx).Table(conn.table(model.AddressTxs{})+" as a").
+		Joins("inner join "+conn.table(model.Transaction{})+" as t on (t.tx_hash = a.tx_hash and t.chain = a.chain)").
+		Select("COUNT(DISTINCT a.address) as active_addresses").
+		Where("a.chain = ? AND t.block_time >= ?", chain, since).
+		Take(metrics).Error
+	if err != nil {
+		return nil, err
 	}
+	return metrics, nil
+}
 
-	return balances, nil
+// GetEventCounts counts the txs of the tick per event, e.g. the mints vs the transfers of the token page. A transfer
+// has an address_txs row per address involved, the distinct tx hashes are counted. The events without any tx are
+// missing from the map.
+func (conn *DBClient) GetEventCounts(chain, protocol, tick string) (map[model.TxEvent]int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetEventCountsContext(ctx, chain, protocol, tick)
 }
 
-func (conn *DBClient) GetTransactionsByAddress(limit, offset int, address, chain, protocol, tick, key string, event int8) (
-	[]*model.AddressTransaction, int64, error) {
+// GetEventCountsContext is the context aware variant of GetEventCounts.
+func (conn *DBClient) GetEventCountsContext(ctx context.Context, chain, protocol, tick string) (map[model.TxEvent]int64, error) {
+	rows := make([]struct {
+		Event model.TxEvent
+		Cnt   int64
+	}, 0)
+	err := conn.SqlDB.WithContext(ctx).Model(&model.AddressTxs{}).
+		Select("event, COUNT(DISTINCT tx_hash) as cnt").
+		Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).
+		Group("event").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
 
-	var data []*model.AddressTransaction
-	var total int64
+	counts := make(map[model.TxEvent]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Event] = row.Cnt
+	}
+	return counts, nil
+}
 
-	query := conn.SqlDB.Select("*").Table("txs as t").
-		Joins("left join `address_txs` as a on (`t`.tx_hash = `a`.tx_hash and `t`.chain = `a`.chain and `t`.protocol = `a`.protocol and `t`.tick = `a`.tick)").
-		Where("`a`.address = ?", address)
+// GetTableCounts returns the rows of the chain per table, soft deleted inscriptions included. The keys are the table
+// names without prefix. With approximate set mysql reads the row estimates of information_schema instead of counting,
+// they are the rows of the whole table of all the chains and may be off by a large factor after bulk writes. The other
+// databases always count.
+func (conn *DBClient) GetTableCounts(chain string, approximate bool) (map[string]int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetTableCountsContext(ctx, chain, approximate)
+}
 
-	if chain != "" {
-		query = query.Where("`a`.chain = ?", chain)
-	}
-	if protocol != "" {
-		query = query.Where("`a`.protocol = ?", protocol)
-	}
-	if tick != "" {
-		query = query.Where("`a`.tick = ?", tick)
-	}
-	if key != "" {
-		query = query.Where("`a`.tick like ?", "%"+key+"%")
-	}
-	if event > 0 {
-		query = query.Where("`a`.event = ?", event)
-	}
+// GetTableCountsContext is the context aware variant of GetTableCounts.
+func (conn *DBClient) GetTableCountsContext(ctx context.Context, chain string, approximate bool) (map[string]int64, error) {
+	tables := []schema.Tabler{&model.Inscriptions{}, &model.Balances{}, &model.Transaction{}, &model.AddressTxs{},
+		&model.UTXO{}, &model.BalanceTxn{}}
+
+	counts := make(map[string]int64, len(tables))
+	if approximate && conn.SqlDB.Dialector.Name() == DatabaseTypeMysql {
+		names := make([]string, 0, len(tables))
+		prefixed := make(map[string]string, len(tables))
+		for _, table := range tables {
+			names = append(names, conn.table(table))
+			prefixed[conn.table(table)] = table.TableName()
+		}
 
-	query = query.Count(&total)
-	result := query.Order("`a`.id desc").Limit(limit).Offset(offset).Find(&data)
-	if result.Error != nil {
-		return nil, 0, result.Error
+		var rows []*struct {
+			TableName string
+			TableRows int64
+		}
+		err := conn.SqlDB.WithContext(ctx).
+			Raw("SELECT TABLE_NAME as table_name, COALESCE(TABLE_ROWS, 0) as table_rows FROM information_schema.TABLES "+
+				"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME IN ?", names).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[prefixed[row.TableName]] = row.TableRows
+		}
+		return counts, nil
 	}
 
-	return data, total, nil
+	for _, table := range tables {
+		var cnt int64
+		if err := conn.SqlDB.WithContext(ctx).Unscoped().Model(table).Where("chain = ?", chain).Count(&cnt).Error; err != nil {
+			return nil, err
+		}
+		counts[table.TableName()] = cnt
+	}
+	return counts, nil
 }
 
 func (conn *DBClient) GetAddressTxs(limit, offset int, address, chain, protocol, tick string, event int8) ([]*model.AddressTransaction, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetAddressTxsContext(ctx, limit, offset, address, chain, protocol, tick, event)
+}
+
+// GetAddressTxsContext is the context aware variant of GetAddressTxs.
+func (conn *DBClient) GetAddressTxsContext(ctx context.Context, limit, offset int, address, chain, protocol, tick string, event int8) ([]*model.AddressTransaction, int64, error) {
 	var data []*model.AddressTransaction
 	var total int64
 
-	query := conn.SqlDB.Select("*").Table("`address_txs`").
+	query := conn.SqlDB.WithContext(ctx).Select("*").Table(conn.table(model.AddressTxs{})).
 		Where("address = ?", address)
 
 	if chain != "" {
@@ -494,8 +2496,15 @@ func (conn *DBClient) GetAddressTxs(limit, offset int, address, chain, protocol,
 }
 
 func (conn *DBClient) GetTxsByHashes(chain string, hashes []string) ([]*model.Transaction, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetTxsByHashesContext(ctx, chain, hashes)
+}
+
+// GetTxsByHashesContext is the context aware variant of GetTxsByHashes.
+func (conn *DBClient) GetTxsByHashesContext(ctx context.Context, chain string, hashes []string) ([]*model.Transaction, error) {
 	txs := make([]*model.Transaction, 0)
-	err := conn.SqlDB.Where("chain = ? AND tx_hash in ?", chain, hashes).Find(&txs).Error
+	err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND tx_hash in ?", chain, hashes).Find(&txs).Error
 	if err != nil {
 		return nil, err
 	}
@@ -504,29 +2513,37 @@ func (conn *DBClient) GetTxsByHashes(chain string, hashes []string) ([]*model.Tr
 
 func (conn *DBClient) GetAddressInscriptions(limit, offset int, address, chain, protocol, tick string, sort int) (
 	[]*model.BalanceInscription, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetAddressInscriptionsContext(ctx, limit, offset, address, chain, protocol, tick, sort)
+}
+
+// GetAddressInscriptionsContext is the context aware variant of GetAddressInscriptions.
+func (conn *DBClient) GetAddressInscriptionsContext(ctx context.Context, limit, offset int, address, chain, protocol, tick string, sort int) (
+	[]*model.BalanceInscription, int64, error) {
 
 	var data []*model.BalanceInscription
 	var total int64
 
-	query := conn.SqlDB.Select("*").Table("balances as b").
-		Joins("left join `inscriptions` as a on (`b`.chain = `a`.chain and `b`.protocol = `a`.protocol and `b`.tick = `a`.tick)")
+	query := conn.SqlDB.WithContext(ctx).Select("*").Table(conn.table(model.Balances{}) + " as b").
+		Joins("left join " + conn.table(model.Inscriptions{}) + " as a on (b.chain = a.chain and b.protocol = a.protocol and b.tick = a.tick)")
 
-	query = query.Where("`b`.address = ? and `b`.balance > 0", address)
+	query = query.Where("b.address = ? and b.balance > 0", address)
 
 	if chain != "" {
-		query = query.Where("`b`.chain = ?", chain)
+		query = query.Where("b.chain = ?", chain)
 	}
 	if protocol != "" {
-		query = query.Where("`b`.protocol = ?", protocol)
+		query = query.Where("b.protocol = ?", protocol)
 	}
 	if tick != "" {
-		query = query.Where("`b`.tick like ?", "%"+tick+"%")
+		query = query.Where("b.tick like ?", "%"+tick+"%")
 	}
 
 	query = query.Count(&total)
-	orderBy := "`b`.balance DESC"
+	orderBy := "b.balance DESC"
 	if sort == OrderByModeAsc {
-		orderBy = "`b`.balance ASC"
+		orderBy = "b.balance ASC"
 	}
 
 	result := query.Order(orderBy).Limit(limit).Offset(offset).Find(&data)
@@ -539,19 +2556,27 @@ func (conn *DBClient) GetAddressInscriptions(limit, offset int, address, chain,
 
 func (conn *DBClient) GetBalancesByAddress(limit, offset int, address, chain, protocol, tick string) (
 	[]*model.Balances, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBalancesByAddressContext(ctx, limit, offset, address, chain, protocol, tick)
+}
+
+// GetBalancesByAddressContext is the context aware variant of GetBalancesByAddress.
+func (conn *DBClient) GetBalancesByAddressContext(ctx context.Context, limit, offset int, address, chain, protocol, tick string) (
+	[]*model.Balances, int64, error) {
 
 	var balances []*model.Balances
 	var total int64
 
-	query := conn.SqlDB.Model(&model.Balances{}).Where("`address` = ?", address)
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).Where("address = ?", address)
 	if chain != "" {
-		query = query.Where("`chain` = ?", chain)
+		query = query.Where("chain = ?", chain)
 	}
 	if protocol != "" {
-		query = query.Where("`protocol` = ?", protocol)
+		query = query.Where("protocol = ?", protocol)
 	}
 	if tick != "" {
-		query = query.Where("`tick` = ?", tick)
+		query = query.Where("tick = ?", tick)
 	}
 	query = query.Count(&total)
 	err := query.Limit(limit).Offset(offset).Find(&balances).Error
@@ -561,27 +2586,276 @@ func (conn *DBClient) GetBalancesByAddress(limit, offset int, address, chain, pr
 	return balances, total, nil
 }
 
-func (conn *DBClient) GetHoldersByTick(limit, offset int, chain, protocol, tick string, sortMode int) ([]*model.Balances, int64, error) {
-	var holders []*model.Balances
+// GetAddressBalancesAllChains returns the balances of the address in the tick on every chain ordered by chain, along
+// with their sum for a unified view of a tick deployed on several chains. The total is summed with decimals, zero when
+// the address holds the tick nowhere.
+func (conn *DBClient) GetAddressBalancesAllChains(protocol, tick, address string) ([]*model.Balances, decimal.Decimal, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetAddressBalancesAllChainsContext(ctx, protocol, tick, address)
+}
+
+// GetAddressBalancesAllChainsContext is the context aware variant of GetAddressBalancesAllChains.
+func (conn *DBClient) GetAddressBalancesAllChainsContext(ctx context.Context, protocol, tick, address string) (
+	[]*model.Balances, decimal.Decimal, error) {
+	balances := make([]*model.Balances, 0)
+	err := conn.SqlDB.WithContext(ctx).Where("address = ? AND protocol = ? AND tick = ?", address, protocol, tick).
+		Order("chain").Find(&balances).Error
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	total := decimal.Zero
+	for _, balance := range balances {
+		total = total.Add(balance.Balance)
+	}
+	return balances, total, nil
+}
+
+// GetHoldersByTick returns the addresses holding a positive balance of the tick. A non empty minBalance only keeps the
+// holders with a balance of at least minBalance, an empty or zero one returns all the holders.
+func (conn *DBClient) GetHoldersByTick(limit, offset int, chain, protocol, tick, minBalance string, sortMode int) ([]*model.Balances, int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetHoldersByTickContext(ctx, limit, offset, chain, protocol, tick, minBalance, sortMode)
+}
+
+// GetHoldersByTickContext is the context aware variant of GetHoldersByTick.
+func (conn *DBClient) GetHoldersByTickContext(ctx context.Context, limit, offset int, chain, protocol, tick, minBalance string, sortMode int) ([]*model.Balances, int64, error) {
+	var rows []*labeledBalance
 	var total int64
-	query := conn.SqlDB.Model(&model.Balances{}).
-		Where("balance > 0 and chain = ? and protocol = ? and tick = ?", chain, protocol, tick)
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.Balances{})+" as b").
+		Where("b.balance > 0 and b.chain = ? and b.protocol = ? and b.tick = ?", chain, protocol, tick)
+	query, err := conn.whereDecimalRange(query, "b.balance", minBalance, "")
+	if err != nil {
+		return nil, 0, err
+	}
 	query = query.Count(&total)
-	orderBy := "balance desc,"
+	orderBy := "b.balance desc,"
 	if sortMode == OrderByModeAsc {
-		orderBy = "balance asc,"
+		orderBy = "b.balance asc,"
+	}
+
+	result := query.Select("b.*, COALESCE(l.label, '') as label").Joins(conn.addressLabelsJoin("b")).
+		Order(orderBy + " b.id asc").Limit(limit).Offset(offset).Find(&rows)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	holders := make([]*model.Balances, 0, len(rows))
+	for _, row := range rows {
+		row.Balances.Label = row.Label
+		holders = append(holders, &row.Balances)
+	}
+	return holders, total, nil
+}
+
+// GetInscriptionHolderCount counts the addresses holding a positive balance of the tick. The ignore addresses, e.g. the
+// deployer or burn addresses, are left out of the count.
+func (conn *DBClient) GetInscriptionHolderCount(chain, protocol, tick string, ignore ...string) (int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetInscriptionHolderCountContext(ctx, chain, protocol, tick, ignore...)
+}
+
+// GetInscriptionHolderCountContext is the context aware variant of GetInscriptionHolderCount.
+func (conn *DBClient) GetInscriptionHolderCountContext(ctx context.Context, chain, protocol, tick string, ignore ...string) (int64, error) {
+	var total int64
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).
+		Where("balance > 0 and chain = ? and protocol = ? and tick = ?", chain, protocol, tick)
+	if len(ignore) > 0 {
+		query = unprepared(query).Where("address NOT IN ?", ignore)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// GetStatsNeedingHolderRecount returns the stats of the chain whose holders differs from the number of addresses
+// with a positive balance of the tick, ordered by id. The reconciliation job fixes them with RecalculateHolders.
+func (conn *DBClient) GetStatsNeedingHolderRecount(chain string, limit int) ([]*model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetStatsNeedingHolderRecountContext(ctx, chain, limit)
+}
+
+// GetStatsNeedingHolderRecountContext is the context aware variant of GetStatsNeedingHolderRecount.
+func (conn *DBClient) GetStatsNeedingHolderRecountContext(ctx context.Context, chain string, limit int) ([]*model.InscriptionsStats, error) {
+	db := conn.SqlDB.WithContext(ctx)
+	holders := db.Model(&model.Balances{}).
+		Select("protocol, tick, COUNT(id) as cnt").
+		Where("chain = ? and balance > 0", chain).
+		Group("protocol, tick")
+
+	stats := make([]*model.InscriptionsStats, 0)
+	err := db.Table(conn.table(model.InscriptionsStats{})+" as s").
+		Joins("left join (?) as b on (b.protocol = s.protocol and b.tick = s.tick)", holders).
+		Where("s.chain = ? and s.holders <> COALESCE(b.cnt, 0)", chain).
+		Select("s.*").Order("s.id asc").Limit(limit).
+		Find(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RecalculateHolders counts the addresses with a positive balance of the tick and writes the count to the holders
+// of the stats, it returns the corrected count. A tick without stats is left as is.
+func (conn *DBClient) RecalculateHolders(dbTx *gorm.DB, chain, protocol, tick string) (holders int64, err error) {
+	defer conn.observe("RecalculateHolders", time.Now(), &err)
+
+	if dbTx == nil {
+		return 0, ErrNilTx
+	}
+
+	err = dbTx.Clauses(dbresolver.Write).Model(&model.Balances{}).Where("chain = ? and protocol = ? and tick = ? and balance > 0", chain, protocol, tick).
+		Count(&holders).Error
+	if err != nil {
+		return 0, fmt.Errorf("count holders of tick[%s] of chain[%s] failed: %w", tick, chain, err)
+	}
+
+	err = dbTx.Clauses(dbresolver.Write).Model(&model.InscriptionsStats{}).
+		Where("chain = ? and protocol = ? and tick = ?", chain, protocol, tick).
+		Update("holders", holders).Error
+	if err != nil {
+		return 0, fmt.Errorf("update holders of tick[%s] of chain[%s] failed: %w", tick, chain, err)
+	}
+	return holders, nil
+}
+
+// GetRichList returns the addresses holding the most of the protocol, the balances of all the ticks summed up.
+// The amounts of different ticks are summed as they are, no price conversion applies.
+func (conn *DBClient) GetRichList(chain, protocol string, limit, offset int) ([]*model.AddressHolding, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetRichListContext(ctx, chain, protocol, limit, offset)
+}
+
+// GetRichListContext is the context aware variant of GetRichList.
+func (conn *DBClient) GetRichListContext(ctx context.Context, chain, protocol string, limit, offset int) ([]*model.AddressHolding, error) {
+	holdings := make([]*model.AddressHolding, 0)
+	err := conn.SqlDB.WithContext(ctx).Table(conn.table(model.Balances{})+" as b").
+		Select("b.address, "+conn.decimalSum("b.balance")+" as total, COUNT(b.id) as ticks, COALESCE(l.label, '') as label").
+		Joins(conn.addressLabelsJoin("b")).
+		Where("b.balance > 0 and b.chain = ? and b.protocol = ?", chain, protocol).
+		Group("b.address, l.label").
+		Order("total desc, b.address asc").
+		Limit(limit).Offset(offset).
+		Scan(&holdings).Error
+	if err != nil {
+		return nil, err
+	}
+	return holdings, nil
+}
+
+// GetDeployerStats returns the addresses which deployed ticks of the protocol with the number of deploys and the time
+// of their first and last deploy, the most deploys first. Soft deleted inscriptions are not counted.
+func (conn *DBClient) GetDeployerStats(chain, protocol string, limit, offset int) ([]*model.DeployerStats, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetDeployerStatsContext(ctx, chain, protocol, limit, offset)
+}
+
+// GetDeployerStatsContext is the context aware variant of GetDeployerStats.
+func (conn *DBClient) GetDeployerStatsContext(ctx context.Context, chain, protocol string, limit, offset int) ([]*model.DeployerStats, error) {
+	var rows []*struct {
+		DeployBy        string
+		Deploys         int64
+		FirstDeployTime aggregateTime
+		LastDeployTime  aggregateTime
+	}
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Inscriptions{}).
+		Select("deploy_by, COUNT(id) as deploys, MIN(deploy_time) as first_deploy_time, MAX(deploy_time) as last_deploy_time").
+		Where("chain = ? and protocol = ?", chain, protocol).
+		Group("deploy_by").
+		Order("deploys desc, deploy_by asc").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*model.DeployerStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, &model.DeployerStats{DeployBy: row.DeployBy, Deploys: row.Deploys,
+			FirstDeployTime: row.FirstDeployTime.Time, LastDeployTime: row.LastDeployTime.Time})
 	}
+	return stats, nil
+}
+
+// GetTopHoldersByTick returns the holders of the tick, the largest balance first, with their rank.
+// Holders with equal balances share the rank (1, 2, 2, 4).
+func (conn *DBClient) GetTopHoldersByTick(limit, offset int, chain, protocol, tick string) ([]*model.TickHolder, int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetTopHoldersByTickContext(ctx, limit, offset, chain, protocol, tick)
+}
 
-	result := query.Order(orderBy + " id asc").Limit(limit).Offset(offset).Find(&holders)
+// GetTopHoldersByTickContext is the context aware variant of GetTopHoldersByTick.
+func (conn *DBClient) GetTopHoldersByTickContext(ctx context.Context, limit, offset int, chain, protocol, tick string) ([]*model.TickHolder, int64, error) {
+	var holders []*model.TickHolder
+	var total int64
+	query := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).
+		Where("balance > 0 and chain = ? and protocol = ? and tick = ?", chain, protocol, tick)
+	query = query.Count(&total)
+	result := query.Order("balance desc, id asc").Limit(limit).Offset(offset).Find(&holders)
 	if result.Error != nil {
 		return nil, 0, result.Error
 	}
+	if len(holders) < 1 {
+		return holders, total, nil
+	}
+
+	// the ties of the first row may start on the previous page
+	var higher int64
+	err := conn.SqlDB.WithContext(ctx).Model(&model.Balances{}).
+		Where("balance > ? and chain = ? and protocol = ? and tick = ?", holders[0].Balance, chain, protocol, tick).Count(&higher).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	holders[0].Rank = higher + 1
+	for i := 1; i < len(holders); i++ {
+		if holders[i].Balance.Equal(holders[i-1].Balance) {
+			holders[i].Rank = holders[i-1].Rank
+		} else {
+			holders[i].Rank = int64(offset + i + 1)
+		}
+	}
 	return holders, total, nil
 }
 
+// GetUTXOByOutpoint returns the utxo created by the output vout of the tx whatever its status, nil when unknown
+func (conn *DBClient) GetUTXOByOutpoint(chain, txid string, vout uint32) (*model.UTXO, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetUTXOByOutpointContext(ctx, chain, txid, vout)
+}
+
+// GetUTXOByOutpointContext is the context aware variant of GetUTXOByOutpoint.
+func (conn *DBClient) GetUTXOByOutpointContext(ctx context.Context, chain, txid string, vout uint32) (*model.UTXO, error) {
+	utxo := &model.UTXO{}
+	err := conn.SqlDB.WithContext(ctx).First(utxo, "chain = ? AND tx_hash = ? AND vout = ?", chain, txid, vout).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return utxo, nil
+}
+
 func (conn *DBClient) GetUTXOCount(address, chain, protocol, tick string) (int64, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetUTXOCountContext(ctx, address, chain, protocol, tick)
+}
+
+// GetUTXOCountContext is the context aware variant of GetUTXOCount.
+func (conn *DBClient) GetUTXOCountContext(ctx context.Context, address, chain, protocol, tick string) (int64, error) {
 	var count int64
-	query := conn.SqlDB.Model(&model.UTXO{}).
+	query := conn.SqlDB.WithContext(ctx).Model(&model.UTXO{}).
 		Where("address = ? and chain = ? and protocol = ? and tick = ? and status = ?", address, chain, protocol, tick, model.UTXOStatusUnspent)
 	err := query.Count(&count)
 	if err.Error != nil {
@@ -591,37 +2865,226 @@ func (conn *DBClient) GetUTXOCount(address, chain, protocol, tick string) (int64
 }
 
 func (conn *DBClient) GetBalancesByIdLimit(chain string, start uint64, limit int) ([]model.Balances, error) {
-	balances := make([]model.Balances, 0)
-	err := conn.SqlDB.Where("chain = ?", chain).Where("id > ?", start).Order("id asc").Limit(limit).Find(&balances).Error
-	if err != nil {
-		return nil, err
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetBalancesByIdLimitContext(ctx, chain, start, limit)
+}
+
+// GetBalancesByIdLimitContext is the context aware variant of GetBalancesByIdLimit.
+func (conn *DBClient) GetBalancesByIdLimitContext(ctx context.Context, chain string, start uint64, limit int) ([]model.Balances, error) {
+	return GetRowsByIdLimitContext[model.Balances](ctx, conn, start, limit, WhereChain(chain))
+}
+
+// IterateBalances walks the balances of the chain by id and calls fn per batch, only one batch is held in memory.
+// The iteration stops at the first error of fn, which is returned as is.
+func (conn *DBClient) IterateBalances(chain string, batchSize int, fn func(batch []model.Balances) error) error {
+	return conn.IterateBalancesContext(context.Background(), chain, batchSize, fn)
+}
+
+// IterateBalancesContext is the context aware variant of IterateBalances.
+func (conn *DBClient) IterateBalancesContext(ctx context.Context, chain string, batchSize int, fn func(batch []model.Balances) error) error {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batch size[%d]", batchSize)
+	}
+
+	var start uint64
+	for {
+		batch, err := conn.GetBalancesByIdLimitContext(ctx, chain, start, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) < 1 {
+			return nil
+		}
+
+		if err = fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		start = batch[len(batch)-1].ID
 	}
-	return balances, nil
 }
 
-func (conn *DBClient) GetUTXOsByIdLimit(start uint64, limit int) ([]model.UTXO, error) {
-	utxos := make([]model.UTXO, 0, limit)
-	err := conn.SqlDB.Where("id > ? ", start).Where("status = ? ", model.UTXOStatusUnspent).Order("id asc").Limit(limit).Find(&utxos).Error
-	if err != nil {
-		return nil, err
+// IterateInscriptionStats walks the stats of all the chains by id and calls fn per batch, only one batch is held in memory.
+// The context is checked before every batch, the iteration stops with ctx.Err() once it is cancelled. The iteration
+// stops at the first error of fn, which is returned as is.
+func (conn *DBClient) IterateInscriptionStats(ctx context.Context, batchSize int, fn func(batch []model.InscriptionsStats) error) error {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batch size[%d]", batchSize)
 	}
-	return utxos, nil
+
+	var start uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := GetRowsByIdLimitContext[model.InscriptionsStats](ctx, conn, start, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) < 1 {
+			return nil
+		}
+
+		if err = fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		start = uint64(batch[len(batch)-1].ID)
+	}
+}
+
+func (conn *DBClient) GetUTXOsByIdLimit(start uint64, limit int) ([]model.UTXO, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetUTXOsByIdLimitContext(ctx, start, limit)
+}
+
+// GetUTXOsByIdLimitContext is the context aware variant of GetUTXOsByIdLimit.
+func (conn *DBClient) GetUTXOsByIdLimitContext(ctx context.Context, start uint64, limit int) ([]model.UTXO, error) {
+	return GetRowsByIdLimitContext[model.UTXO](ctx, conn, start, limit, WhereUTXOUnspent)
 }
 
-func (conn *DBClient) GetUtxosByAddress(address, chain, protocol, tick string) ([]*model.UTXO, error) {
+func (conn *DBClient) GetUtxosByAddress(limit, offset int, address, chain, protocol, tick string) ([]*model.UTXO, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetUtxosByAddressContext(ctx, limit, offset, address, chain, protocol, tick)
+}
+
+// GetUtxosByAddressContext is the context aware variant of GetUtxosByAddress.
+func (conn *DBClient) GetUtxosByAddressContext(ctx context.Context, limit, offset int, address, chain, protocol, tick string) ([]*model.UTXO, error) {
 	var utxos []*model.UTXO
-	query := conn.SqlDB.Model(&model.UTXO{}).
+	query := conn.SqlDB.WithContext(ctx).Model(&model.UTXO{}).
 		Where("address = ? and chain = ? and protocol = ? and tick = ? and status = ?", address, chain, protocol, tick, model.UTXOStatusUnspent)
-	result := query.Order("id desc").Find(&utxos)
+	result := query.Order("id desc").Limit(limit).Offset(offset).Find(&utxos)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return utxos, nil
 }
 
+// GetUTXOsByAddressTick returns all the unspent utxos of the address in the order they were indexed, only the ones of
+// the tick when tick is not empty. It returns nil when the address has none.
+func (conn *DBClient) GetUTXOsByAddressTick(address, tick string) ([]*model.UTXO, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetUTXOsByAddressTickContext(ctx, address, tick)
+}
+
+// GetUTXOsByAddressTickContext is the context aware variant of GetUTXOsByAddressTick.
+func (conn *DBClient) GetUTXOsByAddressTickContext(ctx context.Context, address, tick string) ([]*model.UTXO, error) {
+	return findUTXOsByAddressTick(conn.SqlDB.WithContext(ctx), address, tick)
+}
+
+// GetUTXOsByAddressTickTx is the variant of GetUTXOsByAddressTick reading within dbTx, it sees the utxos written and
+// spent earlier in the transaction. The query runs with the context of dbTx.
+func (conn *DBClient) GetUTXOsByAddressTickTx(dbTx *gorm.DB, address, tick string) ([]*model.UTXO, error) {
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+	return findUTXOsByAddressTick(dbTx, address, tick)
+}
+
+func findUTXOsByAddressTick(db *gorm.DB, address, tick string) ([]*model.UTXO, error) {
+	var utxos []*model.UTXO
+	query := db.Model(&model.UTXO{}).Where("address = ? and status = ?", address, model.UTXOStatusUnspent)
+	if tick != "" {
+		query = query.Where("tick = ?", tick)
+	}
+	if err := query.Order("id asc").Find(&utxos).Error; err != nil {
+		return nil, err
+	}
+	if len(utxos) < 1 {
+		return nil, nil
+	}
+	return utxos, nil
+}
+
+// SumUTXOValue returns the total amount and the count of the unspent utxos of the address,
+// the amount is a decimal string summed without precision loss on mysql & postgres.
+func (conn *DBClient) SumUTXOValue(address, chain, protocol, tick string) (string, int64, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.SumUTXOValueContext(ctx, address, chain, protocol, tick)
+}
+
+// SumUTXOValueContext is the context aware variant of SumUTXOValue.
+func (conn *DBClient) SumUTXOValueContext(ctx context.Context, address, chain, protocol, tick string) (string, int64, error) {
+	ret := &struct {
+		Amount decimal.Decimal `gorm:"column:amount"`
+		Cnt    int64           `gorm:"column:cnt"`
+	}{}
+	err := conn.SqlDB.WithContext(ctx).Model(&model.UTXO{}).
+		Select("COALESCE("+conn.decimalSum("amount")+", 0) as amount, COUNT(id) as cnt").
+		Where("address = ? and chain = ? and protocol = ? and tick = ? and status = ?", address, chain, protocol, tick, model.UTXOStatusUnspent).
+		Take(ret).Error
+	if err != nil {
+		return "", 0, err
+	}
+	return ret.Amount.String(), ret.Cnt, nil
+}
+
+// ErrInsufficientUTXO is returned by SelectUTXOs when the unspent utxos of the address can not cover the target amount
+var ErrInsufficientUTXO = errors.New("insufficient utxo amount")
+
+// SelectUTXOs picks the unspent utxos of the address largest first until the target amount is covered,
+// it returns the selected utxos with their total. The amounts are compared as decimals, the sql order only
+// decides the visiting order so a less precise order of the database does not affect the result.
+func (conn *DBClient) SelectUTXOs(address, chain, protocol, tick, targetAmount string) ([]*model.UTXO, string, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.SelectUTXOsContext(ctx, address, chain, protocol, tick, targetAmount)
+}
+
+// SelectUTXOsContext is the context aware variant of SelectUTXOs.
+func (conn *DBClient) SelectUTXOsContext(ctx context.Context, address, chain, protocol, tick, targetAmount string) ([]*model.UTXO, string, error) {
+	target, err := decimal.NewFromString(targetAmount)
+	if err != nil || !target.IsPositive() {
+		return nil, "", fmt.Errorf("invalid target amount[%s]", targetAmount)
+	}
+
+	rows, err := conn.SqlDB.WithContext(ctx).Model(&model.UTXO{}).
+		Where("address = ? and chain = ? and protocol = ? and tick = ? and status = ?", address, chain, protocol, tick, model.UTXOStatusUnspent).
+		Order("amount desc").Order("id asc").Rows()
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	utxos := make([]*model.UTXO, 0)
+	total := decimal.Zero
+	for total.LessThan(target) && rows.Next() {
+		utxo := &model.UTXO{}
+		if err = conn.SqlDB.ScanRows(rows, utxo); err != nil {
+			return nil, "", err
+		}
+		utxos = append(utxos, utxo)
+		total = total.Add(utxo.Amount)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if total.LessThan(target) {
+		return nil, "", fmt.Errorf("%w: address[%s] available[%s] target[%s]", ErrInsufficientUTXO, address, total.String(), target.String())
+	}
+	return utxos, total.String(), nil
+}
+
 func (conn *DBClient) FindAddressTxByHash(chain, hash string) (*model.AddressTxs, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindAddressTxByHashContext(ctx, chain, hash)
+}
+
+// FindAddressTxByHashContext is the context aware variant of FindAddressTxByHash.
+func (conn *DBClient) FindAddressTxByHashContext(ctx context.Context, chain, hash string) (*model.AddressTxs, error) {
 	tx := &model.AddressTxs{}
-	err := conn.SqlDB.First(tx, "chain = ? and tx_hash = ? ", chain, hash).Error
+	err := conn.SqlDB.WithContext(ctx).First(tx, "chain = ? and tx_hash = ? ", chain, hash).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
@@ -633,8 +3096,15 @@ func (conn *DBClient) FindAddressTxByHash(chain, hash string) (*model.AddressTxs
 }
 
 func (conn *DBClient) FindLastBlock(chain string) (*model.Block, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindLastBlockContext(ctx, chain)
+}
+
+// FindLastBlockContext is the context aware variant of FindLastBlock.
+func (conn *DBClient) FindLastBlockContext(ctx context.Context, chain string) (*model.Block, error) {
 	data := &model.Block{}
-	err := conn.SqlDB.First(data, "chain = ? ", chain).Error
+	err := conn.SqlDB.WithContext(ctx).First(data, "chain = ? ", chain).Error
 	if err != nil {
 		return nil, err
 	}
@@ -642,20 +3112,51 @@ func (conn *DBClient) FindLastBlock(chain string) (*model.Block, error) {
 }
 
 func (conn *DBClient) GetInscriptionsByChain(chain string, hashes []string) ([]*model.Inscriptions, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetInscriptionsByChainContext(ctx, chain, hashes)
+}
+
+// GetInscriptionsByChainContext is the context aware variant of GetInscriptionsByChain.
+func (conn *DBClient) GetInscriptionsByChainContext(ctx context.Context, chain string, hashes []string) ([]*model.Inscriptions, error) {
 	inscriptions := make([]*model.Inscriptions, 0)
-	err := conn.SqlDB.Where("chain = ? AND deploy_hash in ?", chain, hashes).Find(&inscriptions).Error
+	err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND deploy_hash in ?", chain, hashes).Find(&inscriptions).Error
 	if err != nil {
 		return nil, err
 	}
 	return inscriptions, nil
 }
 
-func (conn *DBClient) FindInscriptionsStatsByTick(chain string, protocol string, tick string) (*model.InscriptionsStats, error) {
+// FindInscriptionStatsByTick find the inscription stats by the tick, nil when the tick has no stats
+func (conn *DBClient) FindInscriptionStatsByTick(chain, protocol, tick string) (*model.InscriptionsStats, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.FindInscriptionStatsByTickContext(ctx, chain, protocol, tick)
+}
+
+// FindInscriptionStatsByTickContext is the context aware variant of FindInscriptionStatsByTick.
+func (conn *DBClient) FindInscriptionStatsByTickContext(ctx context.Context, chain, protocol, tick string) (*model.InscriptionsStats, error) {
 	inscriptionStats := &model.InscriptionsStats{}
-	err := conn.SqlDB.First(inscriptionStats, "chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Error
+	err := conn.SqlDB.WithContext(ctx).Where("chain = ? AND protocol = ? AND tick = ?", chain, protocol, tick).Take(inscriptionStats).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
-
 	return inscriptionStats, nil
 }
+
+// FindInscriptionsStatsByTick is an alias of FindInscriptionStatsByTick, nil when the tick has no stats
+//
+// Deprecated: use FindInscriptionStatsByTick.
+func (conn *DBClient) FindInscriptionsStatsByTick(chain string, protocol string, tick string) (*model.InscriptionsStats, error) {
+	return conn.FindInscriptionStatsByTick(chain, protocol, tick)
+}
+
+// FindInscriptionsStatsByTickContext is an alias of FindInscriptionStatsByTickContext.
+//
+// Deprecated: use FindInscriptionStatsByTickContext.
+func (conn *DBClient) FindInscriptionsStatsByTickContext(ctx context.Context, chain string, protocol string, tick string) (*model.InscriptionsStats, error) {
+	return conn.FindInscriptionStatsByTickContext(ctx, chain, protocol, tick)
+}
@@ -0,0 +1,3256 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// newTestClient opens a sqlite db under the test temp dir with all the indexer tables created.
+func newTestClient(t *testing.T) *DBClient {
+	cfg := &config.DatabaseConfig{
+		Type: DatabaseTypeSqlite3,
+		Dsn:  filepath.Join(t.TempDir(), "indexer.db"),
+	}
+	conn, err := NewDbClient(cfg)
+	if err != nil {
+		t.Fatalf("open sqlite db failed. err:%v", err)
+	}
+
+	if err = conn.AutoMigrateAll(); err != nil {
+		t.Fatalf("migrate tables failed. err:%v", err)
+	}
+	return conn
+}
+
+// addInscriptions inserts the deploys, none of them may be skipped
+func addInscriptions(t *testing.T, conn *DBClient, dbTx *gorm.DB, ins []*model.Inscriptions) {
+	skipped, err := conn.BatchAddInscription(dbTx, ins)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(skipped))
+}
+
+func addTransactions(t *testing.T, conn *DBClient, dbTx *gorm.DB, txs []*model.Transaction) {
+	inserted, err := conn.BatchAddTransaction(dbTx, txs)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(txs)), inserted)
+}
+
+func TestBatchUpdatesBySIDBindsValues(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche'; DROP TABLE inscriptions; --"
+
+	items := []*model.Inscriptions{
+		{SID: 1, Chain: chain, Protocol: "asc-20", Tick: "a", Name: "a"},
+		{SID: 2, Chain: chain, Protocol: "asc-20", Tick: "b", Name: "b"},
+		{SID: 3, Chain: "avalanche", Protocol: "asc-20", Tick: "c", Name: "c"},
+	}
+	addInscriptions(t, conn, conn.SqlDB, items)
+
+	fields := map[string]string{
+		"name":          "%s",
+		"transfer_type": "%d",
+	}
+	values := []map[string]interface{}{
+		{"sid": 1, "name": "it's; DELETE FROM inscriptions", "transfer_type": model.TransferTypeHash},
+		{"sid": 2, "name": "'; --", "transfer_type": model.TransferTypeBalance},
+	}
+	err, affected := conn.BatchUpdatesBySID(conn.SqlDB, chain, model.Inscriptions{}.TableName(), fields, values)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	rows := make([]*model.Inscriptions, 0)
+	assert.Nil(t, conn.SqlDB.Order("sid asc").Find(&rows).Error)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, "it's; DELETE FROM inscriptions", rows[0].Name)
+	assert.Equal(t, int8(model.TransferTypeHash), rows[0].TransferType)
+	assert.Equal(t, "'; --", rows[1].Name)
+	assert.Equal(t, int8(model.TransferTypeBalance), rows[1].TransferType)
+	assert.Equal(t, "c", rows[2].Name)
+
+	// decimal values keep their precision through the bound parameters
+	balances := []*model.Balances{
+		{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "a", Address: "0x1"},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	balances[0].Available = decimal.RequireFromString("1.5")
+	balances[0].Balance = decimal.RequireFromString("1234.5678")
+	assert.Nil(t, conn.BatchUpdateBalances(conn.SqlDB, "avalanche", balances))
+
+	balance, err := conn.FindUserBalanceByTick("avalanche", "asc-20", "a", "0x1")
+	assert.Nil(t, err)
+	assert.True(t, balance.Available.Equal(decimal.RequireFromString("1.5")))
+	assert.True(t, balance.Balance.Equal(decimal.RequireFromString("1234.5678")))
+}
+
+func TestBatchUpdatesBySIDStatementLimit(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+	conn.maxStatementBytes = 4096
+
+	items := make([]*model.Inscriptions, 0, 200)
+	values := make([]map[string]interface{}, 0, 200)
+	for i := 1; i <= 200; i++ {
+		tick := fmt.Sprintf("tick-%d", i)
+		items = append(items, &model.Inscriptions{SID: uint32(i), Chain: chain, Protocol: "asc-20", Tick: tick})
+		values = append(values, map[string]interface{}{"sid": i, "name": "name-" + tick})
+	}
+	addInscriptions(t, conn, conn.SqlDB, items)
+
+	var statements, largest int
+	err := conn.SqlDB.Callback().Raw().After("gorm:raw").Register("test:statement_size", func(db *gorm.DB) {
+		statements++
+		if size := statementSize(db.Statement.SQL.String(), db.Statement.Vars); size > largest {
+			largest = size
+		}
+	})
+	assert.Nil(t, err)
+
+	// the statement of the 200 rows is split in statements below the limit
+	fields := map[string]string{"name": "%s"}
+	assert.Greater(t, statementSize(conn.batchUpdatesBySIDStatement(chain, model.Inscriptions{}.TableName(), fields, values, false)), 4096)
+	err, affected := conn.BatchUpdatesBySID(conn.SqlDB, chain, model.Inscriptions{}.TableName(), fields, values)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), affected)
+	assert.Greater(t, statements, 1)
+	assert.LessOrEqual(t, largest, 4096)
+
+	rows := make([]*model.Inscriptions, 0)
+	assert.Nil(t, conn.SqlDB.Order("sid asc").Find(&rows).Error)
+	assert.Equal(t, 200, len(rows))
+	for _, row := range rows {
+		assert.Equal(t, "name-"+row.Tick, row.Name)
+	}
+
+	// a single row above the limit is never sent to the database
+	statements = 0
+	huge := []map[string]interface{}{{"sid": 1, "name": strings.Repeat("x", 5000)}}
+	err, affected = conn.BatchUpdatesBySID(conn.SqlDB, chain, model.Inscriptions{}.TableName(), fields, huge)
+	assert.True(t, errors.Is(err, ErrStatementTooLarge))
+	assert.Contains(t, err.Error(), "table[inscriptions]")
+	assert.Equal(t, int64(0), affected)
+	assert.Equal(t, 0, statements)
+}
+
+func TestReadContextCancel(t *testing.T) {
+	conn := newTestClient(t)
+	ins := []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "a"}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+
+	data, total, err := conn.GetInscriptionsContext(context.Background(), 10, 0, InscriptionFilter{Chain: "avalanche"}, SortById, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, 1, len(data))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = conn.GetInscriptionsContext(ctx, 10, 0, InscriptionFilter{Chain: "avalanche"}, SortById, OrderByModeDesc)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = conn.FindInscriptionByTickContext(ctx, "avalanche", "asc-20", "a")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetInscriptionsByCursor(t *testing.T) {
+	conn := newTestClient(t)
+	holders := []uint64{5, 3, 5, 0, 8, 3, 3}
+	for i, h := range holders {
+		tick := fmt.Sprintf("t%d", i)
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: "avalanche", Protocol: "asc-20", Tick: tick, TotalSupply: decimal.NewFromInt(100)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: "avalanche", Protocol: "asc-20", Tick: tick, Holders: h}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	for _, sort := range []SortField{SortById, SortByHolders, SortByProgress, SortByDeployTimeAsc} {
+		var cursor uint64
+		for page := 0; ; page++ {
+			rows, next, err := conn.GetInscriptionsByCursor(cursor, 3, "avalanche", "", "", "", sort)
+			assert.Nil(t, err)
+
+			expected, total, err := conn.GetInscriptions(3, page*3, InscriptionFilter{Chain: "avalanche"}, sort, OrderByModeDesc)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(holders)), total)
+			assert.Equal(t, len(expected), len(rows), "sort %d page %d", sort, page)
+			for i := range expected {
+				assert.Equal(t, expected[i].ID, rows[i].ID, "sort %d page %d", sort, page)
+			}
+
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+}
+
+func TestGetInscriptionsSortFields(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	items := []struct {
+		tick     string
+		deployed time.Time
+		supply   int64
+		minted   int64
+		holders  uint64
+		txCnt    uint64
+	}{
+		{"a", base.Add(2 * time.Hour), 1000, 300, 1, 50},
+		{"b", base, 100, 90, 3, 10},
+		{"c", base.Add(time.Hour), 10000, 500, 2, 30},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick, DeployTime: item.deployed,
+			TotalSupply: decimal.NewFromInt(item.supply)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.NewFromInt(item.minted), Holders: item.holders, TxCnt: item.txCnt}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	cases := []struct {
+		sort  SortField
+		ticks []string
+	}{
+		{SortById, []string{"c", "b", "a"}},
+		{SortByDeployTime, []string{"a", "c", "b"}},
+		{SortByProgress, []string{"b", "a", "c"}},
+		{SortByHolders, []string{"b", "c", "a"}},
+		{SortByTxCnt, []string{"a", "c", "b"}},
+		{SortByMinted, []string{"c", "a", "b"}},
+		{SortByDeployTimeAsc, []string{"b", "c", "a"}},
+	}
+	for _, c := range cases {
+		for _, sortMode := range []int{OrderByModeDesc, OrderByModeAsc} {
+			data, _, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, c.sort, sortMode)
+			assert.Nil(t, err, "sort %d", c.sort)
+			ticks := make([]string, 0, len(data))
+			for _, row := range data {
+				ticks = append(ticks, row.Tick)
+			}
+
+			// the asc mode reverses the direction of the field, SortByDeployTimeAsc is always ascending
+			expected := c.ticks
+			if sortMode == OrderByModeAsc && c.sort != SortByDeployTimeAsc {
+				expected = []string{c.ticks[2], c.ticks[1], c.ticks[0]}
+			}
+			assert.Equal(t, expected, ticks, "sort %d mode %d", c.sort, sortMode)
+		}
+	}
+
+	_, _, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortField(99), OrderByModeDesc)
+	assert.NotNil(t, err)
+	_, _, err = conn.GetInscriptionsByCursor(0, 10, chain, protocol, "", "", SortField(99))
+	assert.NotNil(t, err)
+}
+
+func TestGetInscriptionsProgressZeroSupply(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	items := []struct {
+		tick   string
+		supply int64
+		minted int64
+	}{
+		{"half", 100, 50},
+		{"zero", 0, 10},
+		{"full", 100, 100},
+		{"none", 100, 0},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick, TotalSupply: decimal.NewFromInt(item.supply)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick, Minted: decimal.NewFromInt(item.minted)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	// zero supply sorts as progress 0, the id desc tiebreaker puts it behind "none"
+	data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortByProgress, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(items)), total)
+	ticks := make([]string, 0, len(data))
+	for _, row := range data {
+		ticks = append(ticks, row.Tick)
+	}
+	assert.Equal(t, []string{"full", "half", "none", "zero"}, ticks)
+
+	data, _, err = conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortByProgress, OrderByModeAsc)
+	assert.Nil(t, err)
+	assert.Equal(t, len(items), len(data))
+	assert.Equal(t, "zero", data[0].Tick)
+
+	stats, err := conn.GetTickMarketStats(chain, protocol, "zero")
+	assert.Nil(t, err)
+	assert.True(t, stats.Progress.IsZero())
+}
+
+func TestGetInscriptionsTickLike(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	items := []struct {
+		tick    string
+		minted  int64
+		holders uint64
+	}{
+		{"ordi", 10, 3},
+		{"ords", 50, 1},
+		{"oxbt", 90, 2},
+		{"or%x", 20, 4},
+		{"or_x", 30, 5},
+		{"100%", 0, 0},
+		{"1000", 0, 0},
+		{`10\0`, 0, 0},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick, TotalSupply: decimal.NewFromInt(100)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.NewFromInt(item.minted), Holders: item.holders}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	search := func(tick, tickLike string, sort SortField) []string {
+		data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, Tick: tick, TickLike: tickLike}, sort, OrderByModeDesc)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), total)
+		ticks := make([]string, 0, len(data))
+		for _, row := range data {
+			ticks = append(ticks, row.Tick)
+		}
+		return ticks
+	}
+
+	assert.Equal(t, []string{"ords", "or_x", "or%x", "ordi"}, search("", "or", SortByProgress))
+	assert.Equal(t, []string{"or_x", "or%x", "ordi", "ords"}, search("", "or", SortByHolders))
+	assert.Equal(t, []string{"ords", "ordi"}, search("", "ord", SortById))
+
+	// the wildcards are matched literally
+	assert.Equal(t, []string{"or%x"}, search("", "or%", SortById))
+	assert.Equal(t, []string{"or_x"}, search("", "or_", SortById))
+	assert.Empty(t, search("", "%", SortById))
+	assert.Equal(t, []string{"100%"}, search("", "100%", SortById))
+	assert.Equal(t, []string{`10\0`}, search("", `10\`, SortById))
+	assert.Equal(t, []string{`10\0`, "1000", "100%"}, search("", "10", SortById))
+
+	// sqlite LIKE ignores the case of ascii letters, mysql follows the case sensitive column collation
+	assert.Equal(t, []string{"ords", "ordi"}, search("", "ORD", SortById))
+
+	// the exact tick still applies together with the prefix
+	assert.Equal(t, []string{"ordi"}, search("ordi", "or", SortById))
+	assert.Empty(t, search("oxbt", "or", SortById))
+}
+
+// captureWriter collects the gorm log lines
+type captureWriter struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (w *captureWriter) Printf(format string, args ...interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines = append(w.lines, fmt.Sprintf(format, args...))
+}
+
+func (w *captureWriter) count(substr string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cnt := 0
+	for _, line := range w.lines {
+		if strings.Contains(line, substr) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Type:            DatabaseTypeSqlite3,
+		Dsn:             filepath.Join(t.TempDir(), "indexer.db"),
+		SlowThresholdMs: 20,
+	}
+	writer := &captureWriter{}
+	conn, err := NewSqliteClient(cfg, &gorm.Config{Logger: newGormLogger(cfg, writer)})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var one int
+	assert.Nil(t, conn.SqlDB.Raw("SELECT 1").Scan(&one).Error)
+	assert.Equal(t, 0, len(writer.lines))
+
+	var cnt int64
+	slow := "WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 3000000) SELECT COUNT(*) FROM seq"
+	assert.Nil(t, conn.SqlDB.Raw(slow).Scan(&cnt).Error)
+	assert.Equal(t, int64(3000000), cnt)
+	assert.Equal(t, 1, writer.count("SLOW SQL >= 20ms"), writer.lines)
+
+	// EnableLog logs every query on top of the slow ones
+	cfg.EnableLog = true
+	writer = &captureWriter{}
+	conn.SqlDB.Logger = newGormLogger(cfg, writer)
+	assert.Nil(t, conn.SqlDB.Raw("SELECT 1").Scan(&one).Error)
+	assert.Equal(t, 1, len(writer.lines))
+	assert.Equal(t, 0, writer.count("SLOW SQL"))
+}
+
+func TestQueryTimeout(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		Type:           DatabaseTypeSqlite3,
+		Dsn:            filepath.Join(t.TempDir(), "indexer.db"),
+		QueryTimeoutMs: 100,
+	}
+	conn, err := NewDbClient(cfg)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.SqlDB.AutoMigrate(&model.BlockStatus{}))
+
+	var timeout int
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA busy_timeout").Scan(&timeout).Error)
+	assert.Equal(t, 100, timeout)
+
+	// another client holds the write lock, the write gives up after the busy timeout
+	other, err := NewDbClient(&config.DatabaseConfig{Type: DatabaseTypeSqlite3, Dsn: cfg.Dsn})
+	assert.Nil(t, err)
+	defer other.Close()
+	tx := other.SqlDB.Begin()
+	assert.Nil(t, tx.Error)
+	defer tx.Rollback()
+	assert.Nil(t, other.SaveLastBlock(tx, &model.BlockStatus{Chain: "btc", BlockNumber: 1}))
+
+	start := time.Now()
+	err = conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "avalanche", BlockNumber: 1})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "locked")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestPostgresDsn(t *testing.T) {
+	assert.Equal(t, "host=db user=indexer statement_timeout=100", postgresDsn("host=db user=indexer ", "statement_timeout", "100"))
+	assert.Equal(t, "postgres://db/indexer?statement_timeout=100", postgresDsn("postgres://db/indexer", "statement_timeout", "100"))
+	assert.Equal(t, "postgres://db/indexer?sslmode=disable&statement_timeout=100",
+		postgresDsn("postgres://db/indexer?sslmode=disable", "statement_timeout", "100"))
+	assert.Equal(t, "host=db sslmode=require", postgresDsn("host=db sslmode=require", "sslmode", "disable"))
+	assert.Equal(t, "host=db", postgresDsn("host=db", "sslmode", ""))
+}
+
+func TestGetInscriptionsMintStatus(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	completedAt := time.Now()
+
+	items := []struct {
+		tick        string
+		supply      string
+		minted      string
+		completedAt *time.Time
+		stats       bool
+	}{
+		{"done", "100", "100", nil, true},
+		{"marked", "100", "60", &completedAt, true},
+		{"minting", "100", "99.5", nil, true},
+		{"zero", "0", "10", nil, true},
+		{"nostats", "100", "0", nil, false},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			TotalSupply: decimal.RequireFromString(item.supply)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		if !item.stats {
+			continue
+		}
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.RequireFromString(item.minted), MintCompletedTime: item.completedAt}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	ticks := func(mintStatus int) []string {
+		data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, MintStatus: mintStatus}, SortById, OrderByModeAsc)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), total)
+		ticks := make([]string, 0, len(data))
+		for _, row := range data {
+			ticks = append(ticks, row.Tick)
+		}
+		return ticks
+	}
+	assert.Equal(t, []string{"done", "marked", "minting", "zero", "nostats"}, ticks(MintStatusAll))
+	assert.Equal(t, []string{"done", "marked"}, ticks(MintStatusCompleted))
+	assert.Equal(t, []string{"minting", "zero", "nostats"}, ticks(MintStatusMinting))
+
+	_, _, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, MintStatus: 3}, SortById, OrderByModeAsc)
+	assert.NotNil(t, err)
+}
+
+func TestGetInscriptionFacets(t *testing.T) {
+	conn := newTestClient(t)
+
+	items := []struct {
+		sid      uint32
+		chain    string
+		protocol string
+		tick     string
+		minted   int64
+	}{
+		{1, "avalanche", "asc-20", "t1", 100},
+		{2, "avalanche", "asc-20", "t2", 1000},
+		{3, "avalanche", "avax-20", "t3", 0},
+		{1, "arbitrum", "asc-20", "t1", 1000},
+		{2, "arbitrum", "asc-20", "t4", 300},
+		{1, "btc", "brc-20", "ordi", 1000},
+	}
+	for _, item := range items {
+		addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: item.sid, Chain: item.chain, Protocol: item.protocol,
+			Tick: item.tick, TotalSupply: decimal.NewFromInt(1000)}})
+		stats := []*model.InscriptionsStats{{SID: item.sid, Chain: item.chain, Protocol: item.protocol, Tick: item.tick,
+			Minted: decimal.NewFromInt(item.minted)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+	// soft deleted inscriptions are not counted
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 9, Chain: "btc", Protocol: "brc-20", Tick: "gone"}})
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, "btc", "brc-20", "gone"))
+
+	cases := []struct {
+		name   string
+		filter InscriptionFilter
+		facet  InscriptionFacet
+		counts map[string]int64
+	}{
+		{"chains", InscriptionFilter{}, FacetByChain, map[string]int64{"avalanche": 3, "arbitrum": 2, "btc": 1}},
+		{"protocols", InscriptionFilter{}, FacetByProtocol, map[string]int64{"asc-20": 4, "avax-20": 1, "brc-20": 1}},
+		{"protocols of a chain", InscriptionFilter{Chain: "avalanche"}, FacetByProtocol, map[string]int64{"asc-20": 2, "avax-20": 1}},
+		{"chains of a protocol", InscriptionFilter{Protocol: "asc-20"}, FacetByChain, map[string]int64{"avalanche": 2, "arbitrum": 2}},
+		{"completed", InscriptionFilter{MintStatus: MintStatusCompleted}, FacetByChain, map[string]int64{"avalanche": 1, "arbitrum": 1, "btc": 1}},
+		{"tick prefix & minted", InscriptionFilter{TickLike: "t", FromMinted: "200"}, FacetByChain, map[string]int64{"avalanche": 1, "arbitrum": 2}},
+		{"chains list", InscriptionFilter{Chains: []string{"btc", "arbitrum"}}, FacetByProtocol, map[string]int64{"asc-20": 2, "brc-20": 1}},
+		{"nothing", InscriptionFilter{Chain: "bsc"}, FacetByChain, map[string]int64{}},
+	}
+	for _, c := range cases {
+		counts, err := conn.GetInscriptionFacets(c.filter, c.facet)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.counts, counts, c.name)
+	}
+
+	_, err := conn.GetInscriptionFacets(InscriptionFilter{}, 2)
+	assert.NotNil(t, err)
+	_, err = conn.GetInscriptionFacets(InscriptionFilter{MintStatus: 3}, FacetByChain)
+	assert.NotNil(t, err)
+}
+
+func TestGetInscriptionsChains(t *testing.T) {
+	conn := newTestClient(t)
+	protocol := "asc-20"
+
+	// the same tick on several chains, the stats join must not mix them up
+	items := []struct {
+		sid    uint32
+		chain  string
+		tick   string
+		minted int64
+	}{
+		{1, "avalanche", "t1", 100},
+		{2, "avalanche", "t2", 900},
+		{1, "arbitrum", "t1", 500},
+		{2, "arbitrum", "t3", 1000},
+		{1, "btc", "t1", 800},
+	}
+	for _, item := range items {
+		addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: item.sid, Chain: item.chain, Protocol: protocol,
+			Tick: item.tick, TotalSupply: decimal.NewFromInt(1000)}})
+		stats := []*model.InscriptionsStats{{SID: item.sid, Chain: item.chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.NewFromInt(item.minted)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	rows := func(chain string, chains []string) []string {
+		data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Chains: chains, Protocol: protocol}, SortByProgress, OrderByModeDesc)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), total)
+		ret := make([]string, 0, len(data))
+		for _, row := range data {
+			ret = append(ret, fmt.Sprintf("%s/%s/%s", row.Chain, row.Tick, row.Minted.String()))
+		}
+		return ret
+	}
+
+	assert.Equal(t, []string{"arbitrum/t3/1000", "avalanche/t2/900", "arbitrum/t1/500", "avalanche/t1/100"},
+		rows("", []string{"avalanche", "arbitrum"}))
+	assert.Equal(t, []string{"btc/t1/800"}, rows("", []string{"btc"}))
+	assert.Equal(t, 5, len(rows("", nil)))
+	// along with chain both filters apply
+	assert.Equal(t, []string{"avalanche/t2/900", "avalanche/t1/100"}, rows("avalanche", []string{"avalanche", "arbitrum"}))
+	assert.Equal(t, 0, len(rows("btc", []string{"avalanche", "arbitrum"})))
+}
+
+func TestGetInscriptionsMintedRange(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	items := []struct {
+		tick   string
+		minted string
+		stats  bool
+	}{
+		{"t1", "500000", true},
+		{"t2", "1000000", true},
+		{"t3", "5000000", true},
+		{"t4", "10000000", true},
+		{"t5", "20000000", true},
+		{"nostats", "0", false},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			TotalSupply: decimal.NewFromInt(20000000)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		if !item.stats {
+			continue
+		}
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.RequireFromString(item.minted)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	ticks := func(fromMinted, toMinted string, sort SortField, sortMode int) []string {
+		data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, FromMinted: fromMinted, ToMinted: toMinted}, sort, sortMode)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), total)
+		ticks := make([]string, 0, len(data))
+		for _, row := range data {
+			ticks = append(ticks, row.Tick)
+		}
+		return ticks
+	}
+	// the bounds are inclusive
+	assert.Equal(t, []string{"t2", "t3", "t4"}, ticks("1000000", "10000000", SortById, OrderByModeAsc))
+	assert.Equal(t, []string{"t4", "t5"}, ticks("10000000", "", SortById, OrderByModeAsc))
+	// missing stats count as nothing minted
+	assert.Equal(t, []string{"t1", "nostats"}, ticks("", "999999.5", SortById, OrderByModeAsc))
+	assert.Equal(t, []string{"nostats"}, ticks("0", "0", SortById, OrderByModeAsc))
+	assert.Equal(t, 6, len(ticks("", "", SortById, OrderByModeAsc)))
+
+	// the filter combines with the sort fields
+	assert.Equal(t, []string{"t4", "t3", "t2"}, ticks("1000000", "10000000", SortByMinted, OrderByModeDesc))
+	assert.Equal(t, []string{"t2", "t3", "t4"}, ticks("1000000", "10000000", SortByProgress, OrderByModeAsc))
+
+	_, _, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, FromMinted: "abc"}, SortById, OrderByModeAsc)
+	assert.NotNil(t, err)
+	_, _, err = conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, ToMinted: "1e"}, SortById, OrderByModeAsc)
+	assert.NotNil(t, err)
+	_, _, err = conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, FromMinted: "10", ToMinted: "9.99"}, SortById, OrderByModeAsc)
+	assert.NotNil(t, err)
+}
+
+func TestGetInscriptionsSinceDeployTime(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	now := time.Now().UTC().Truncate(time.Second)
+
+	items := []struct {
+		tick   string
+		age    time.Duration
+		minted int64
+	}{
+		{"old", 72 * time.Hour, 100},
+		{"day", 20 * time.Hour, 300},
+		{"hour", time.Hour, 200},
+		{"new", time.Minute, 0},
+	}
+	for i, item := range items {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			TotalSupply: decimal.NewFromInt(1000), DeployTime: now.Add(-item.age)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+			Minted: decimal.NewFromInt(item.minted)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	ticks := func(since time.Time, fromMinted string, sort SortField, sortMode int) []string {
+		data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, FromMinted: fromMinted, SinceDeployTime: since}, sort, sortMode)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), total)
+		ticks := make([]string, 0, len(data))
+		for _, row := range data {
+			ticks = append(ticks, row.Tick)
+		}
+		return ticks
+	}
+	// the new tokens of the last 24 hours, the latest deploys first
+	assert.Equal(t, []string{"new", "hour", "day"}, ticks(now.Add(-24*time.Hour), "", SortByDeployTime, OrderByModeDesc))
+	assert.Equal(t, []string{"day", "hour", "new"}, ticks(now.Add(-24*time.Hour), "", SortByDeployTimeAsc, OrderByModeDesc))
+	// the window starts inclusively at the deploy time
+	assert.Equal(t, []string{"hour", "new"}, ticks(now.Add(-time.Hour), "", SortById, OrderByModeAsc))
+	assert.Equal(t, 4, len(ticks(time.Time{}, "", SortById, OrderByModeAsc)))
+	assert.Equal(t, 0, len(ticks(now.Add(time.Minute), "", SortById, OrderByModeAsc)))
+
+	// the window combines with the other filters & sorts
+	assert.Equal(t, []string{"day", "hour"}, ticks(now.Add(-24*time.Hour), "100", SortByMinted, OrderByModeDesc))
+}
+
+func TestGetInscriptionsWithDeployer(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	items := []struct {
+		tick     string
+		deployer string
+		balance  string // empty for a deployer without a balance row
+	}{
+		{"sold", "0xd1", "0"},
+		{"none", "0xd2", ""},
+		{"held", "0xd3", "250"},
+	}
+	for i, item := range items {
+		sid := uint32(i + 1)
+		ins := []*model.Inscriptions{{SID: sid, Chain: chain, Protocol: protocol, Tick: item.tick, DeployBy: item.deployer,
+			TotalSupply: decimal.NewFromInt(1000)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: sid, Chain: chain, Protocol: protocol, Tick: item.tick, Holders: 1}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+		// the balance of another holder must not be taken as the one of the deployer
+		balances := []*model.Balances{{SID: uint64(sid * 10), Chain: chain, Protocol: protocol, Tick: item.tick, Address: "0xh",
+			Balance: decimal.NewFromInt(750)}}
+		if item.balance != "" {
+			balances = append(balances, &model.Balances{SID: uint64(sid*10 + 1), Chain: chain, Protocol: protocol, Tick: item.tick,
+				Address: item.deployer, Balance: decimal.RequireFromString(item.balance)})
+		}
+		assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	}
+
+	data, total, err := conn.GetInscriptionsWithDeployer(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortById, OrderByModeAsc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, 3, len(data))
+	for i, row := range data {
+		expected := decimal.Zero
+		if items[i].balance != "" {
+			expected = decimal.RequireFromString(items[i].balance)
+		}
+		assert.Equal(t, items[i].tick, row.Tick)
+		assert.Equal(t, uint64(1), row.Holders)
+		assert.True(t, expected.Equal(row.DeployerBalance), "%s: %s", row.Tick, row.DeployerBalance)
+		assert.Equal(t, expected.IsPositive(), row.DeployerHolds, row.Tick)
+	}
+
+	data, total, err = conn.GetInscriptionsWithDeployer(10, 0, InscriptionFilter{Chain: chain, DeployBy: "0xd1"}, SortById, OrderByModeAsc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "sold", data[0].Tick)
+	assert.False(t, data[0].DeployerHolds)
+}
+
+func TestGetIndexedChainsAndProtocols(t *testing.T) {
+	conn := newTestClient(t)
+
+	chains, err := conn.GetIndexedChains()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, chains)
+
+	items := []struct{ chain, protocol, tick string }{
+		{"avalanche", "asc-20", "a"},
+		{"avalanche", "asc-20", "b"},
+		{"eth", "erc-20", "a"},
+		{"avalanche", "asc-721", "a"},
+		{"btc", "brc-20", "a"},
+		{"btc", "brc-20", "b"},
+		{"btc", "orc-20", "a"},
+	}
+	for i, item := range items {
+		addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: uint32(i + 1), Chain: item.chain, Protocol: item.protocol, Tick: item.tick}})
+	}
+	// soft deleted inscriptions are not listed
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, "btc", "orc-20", "a"))
+
+	chains, err = conn.GetIndexedChains()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"avalanche", "btc", "eth"}, chains)
+
+	for chain, expected := range map[string][]string{
+		"avalanche": {"asc-20", "asc-721"},
+		"btc":       {"brc-20"},
+		"eth":       {"erc-20"},
+		"none":      {},
+	} {
+		protocols, err := conn.GetIndexedProtocols(chain)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, protocols, chain)
+	}
+}
+
+func TestFindBySID(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.NewFromInt(100)
+
+	ins := []*model.Inscriptions{
+		{SID: 7, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount, DeployHash: "0xd1"},
+		{SID: 7, Chain: "btc", Protocol: "brc-20", Tick: "ordi", TotalSupply: amount, DeployHash: "0xd2"},
+	}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	stats := []*model.InscriptionsStats{{SID: 7, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount, Holders: 1}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	balances := []*model.Balances{{SID: 9, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount, Available: amount}}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	foundIns, err := conn.FindInscriptionBySID(chain, ins[0].SID)
+	assert.Nil(t, err)
+	assert.Equal(t, ins[0].ID, foundIns.ID)
+	assert.Equal(t, tick, foundIns.Tick)
+	foundIns, err = conn.FindInscriptionBySID("btc", ins[1].SID)
+	assert.Nil(t, err)
+	assert.Equal(t, "ordi", foundIns.Tick)
+
+	foundStats, err := conn.FindInscriptionStatsBySID(chain, stats[0].SID)
+	assert.Nil(t, err)
+	assert.Equal(t, stats[0].ID, foundStats.ID)
+	assert.True(t, amount.Equal(foundStats.Minted))
+
+	foundBalance, err := conn.FindBalanceBySID(chain, balances[0].SID)
+	assert.Nil(t, err)
+	assert.Equal(t, balances[0].ID, foundBalance.ID)
+	assert.Equal(t, "0xa", foundBalance.Address)
+
+	// unknown sids & the sids of the other chains
+	foundIns, err = conn.FindInscriptionBySID(chain, 8)
+	assert.Nil(t, err)
+	assert.Nil(t, foundIns)
+	foundStats, err = conn.FindInscriptionStatsBySID("btc", stats[0].SID)
+	assert.Nil(t, err)
+	assert.Nil(t, foundStats)
+	foundBalance, err = conn.FindBalanceBySID("btc", balances[0].SID)
+	assert.Nil(t, err)
+	assert.Nil(t, foundBalance)
+
+	// soft deleted inscriptions are not found
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, chain, protocol, tick))
+	foundIns, err = conn.FindInscriptionBySID(chain, ins[0].SID)
+	assert.Nil(t, err)
+	assert.Nil(t, foundIns)
+}
+
+func TestFindInscriptionByTickTx(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	_, err := conn.FindInscriptionByTickTx(nil, chain, protocol, tick)
+	assert.NotNil(t, err)
+
+	tx := conn.SqlDB.Begin()
+	assert.Nil(t, tx.Error)
+	addInscriptions(t, conn, tx, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, DeployHash: "0xd1"}})
+
+	ins, err := conn.FindInscriptionByTickTx(tx, chain, protocol, tick)
+	assert.Nil(t, err)
+	if assert.NotNil(t, ins) {
+		assert.Equal(t, "0xd1", ins.DeployHash)
+	}
+
+	// the deploy is not committed yet
+	ins, err = conn.FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Nil(t, ins)
+
+	ins, err = conn.FindInscriptionByTickTx(tx, chain, protocol, "missing")
+	assert.Nil(t, err)
+	assert.Nil(t, ins)
+	assert.Nil(t, tx.Rollback().Error)
+}
+
+func TestGetMintableSupply(t *testing.T) {
+	// sqlite has no row locks, the immediate transactions serialize the mints instead
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:           DatabaseTypeSqlite3,
+		Dsn:            filepath.Join(t.TempDir(), "indexer.db") + "?_txlock=immediate",
+		QueryTimeoutMs: 5000,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	_, err = conn.GetMintableSupply(conn.SqlDB, chain, protocol, tick)
+	assert.Equal(t, ErrInscriptionNotFound, err)
+
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick,
+		TotalSupply: decimal.NewFromInt(1000)}})
+	remaining, err := conn.GetMintableSupply(conn.SqlDB, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, "1000", remaining)
+
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: decimal.NewFromInt(100)}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	tx1 := conn.SqlDB.Begin()
+	assert.Nil(t, tx1.Error)
+	remaining, err = conn.GetMintableSupply(tx1, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, "900", remaining)
+	assert.Nil(t, conn.UpdateInscriptionsStatsBySID(tx1, chain, 1, map[string]interface{}{"minted": decimal.NewFromInt(600)}))
+
+	// the second mint waits for the first one and reads the updated minted amount
+	var committed atomic.Bool
+	done := make(chan string)
+	go func() {
+		defer close(done)
+		err := conn.SqlDB.Transaction(func(tx2 *gorm.DB) error {
+			assert.True(t, committed.Load())
+			remaining, err := conn.GetMintableSupply(tx2, chain, protocol, tick)
+			done <- remaining
+			return err
+		})
+		assert.Nil(t, err)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	committed.Store(true)
+	assert.Nil(t, tx1.Commit().Error)
+	assert.Equal(t, "400", <-done)
+	<-done
+
+	// an overshot supply is never negative
+	assert.Nil(t, conn.UpdateInscriptionsStatsBySID(conn.SqlDB, chain, 1, map[string]interface{}{"minted": decimal.NewFromInt(1200)}))
+	remaining, err = conn.GetMintableSupply(conn.SqlDB, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", remaining)
+}
+
+func TestBatchAddTransactionChunks(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	// the rows & placeholders of every INSERT into txs
+	var statements []int
+	var mu sync.Mutex
+	err := conn.SqlDB.Callback().Create().After("gorm:create").Register("test:count_txs", func(db *gorm.DB) {
+		if db.Statement.Table == (model.Transaction{}).TableName() {
+			mu.Lock()
+			statements = append(statements, len(db.Statement.Vars))
+			mu.Unlock()
+		}
+	})
+	assert.Nil(t, err)
+
+	const cnt = 5000
+	txs := make([]*model.Transaction, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		txs = append(txs, &model.Transaction{Chain: chain, Protocol: "asc-20", Tick: "tick", TxHash: fmt.Sprintf("0x%d", i), BlockHeight: uint64(i)})
+	}
+	assert.Nil(t, conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+		inserted, err := conn.BatchAddTransaction(tx, txs)
+		assert.Equal(t, int64(cnt), inserted)
+		return err
+	}))
+
+	var stored int64
+	assert.Nil(t, conn.SqlDB.Model(&model.Transaction{}).Where("chain = ?", chain).Count(&stored).Error)
+	assert.Equal(t, int64(cnt), stored)
+
+	assert.Equal(t, cnt/DefaultBatchSize, len(statements))
+	for _, vars := range statements {
+		assert.LessOrEqual(t, vars, 65535)
+	}
+
+	// the batch size of the config
+	conn.batchSize = 2000
+	statements = nil
+	for i := range txs {
+		txs[i].ID = 0
+		txs[i].Chain = "btc"
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+	assert.Equal(t, 3, len(statements))
+}
+
+func TestBatchAddTransactionReprocess(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	block := func(hashes ...string) []*model.Transaction {
+		txs := make([]*model.Transaction, 0, len(hashes))
+		for i, hash := range hashes {
+			txs = append(txs, &model.Transaction{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, BlockHeight: 1,
+				PositionInBlock: uint64(i)})
+		}
+		return txs
+	}
+	process := func(txs []*model.Transaction) (inserted int64) {
+		assert.Nil(t, conn.SqlDB.Transaction(func(tx *gorm.DB) (err error) {
+			inserted, err = conn.BatchAddTransaction(tx, txs)
+			return err
+		}))
+		return inserted
+	}
+
+	txs := block("0x1", "0x2", "0x3")
+	assert.Equal(t, int64(3), process(txs))
+	for _, item := range txs {
+		assert.NotZero(t, item.ID)
+	}
+	// the block is processed again after a partial failure, the same hash of another chain is a new tx
+	assert.Equal(t, int64(0), process(block("0x1", "0x2", "0x3")))
+	// the ids of a batch with skipped rows can't be matched to the rows
+	txs = block("0x1", "0x2", "0x3", "0x4")
+	assert.Equal(t, int64(1), process(txs))
+	for _, item := range txs {
+		assert.Zero(t, item.ID, item.TxHash)
+	}
+	inserted, err := conn.BatchAddTransaction(conn.SqlDB, []*model.Transaction{{Chain: "btc", TxHash: "0x1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), inserted)
+
+	var hashes []string
+	assert.Nil(t, conn.SqlDB.Model(&model.Transaction{}).Where("chain = ?", chain).Order("id").Pluck("tx_hash", &hashes).Error)
+	assert.Equal(t, []string{"0x1", "0x2", "0x3", "0x4"}, hashes)
+}
+
+func TestBatchAddBalanceTx(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	var statements int
+	err := conn.SqlDB.Callback().Create().After("gorm:create").Register("test:count_balance_txs", func(db *gorm.DB) {
+		if db.Statement.Table == (model.BalanceTxn{}).TableName() {
+			statements++
+		}
+	})
+	assert.Nil(t, err)
+
+	const cnt = 3000
+	items := make([]*model.BalanceTxn, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		items = append(items, &model.BalanceTxn{Chain: chain, Protocol: protocol, Tick: tick, TxHash: fmt.Sprintf("0x%d", i),
+			Address: fmt.Sprintf("0xa%d", i%10), Event: model.TransactionEventTransfer, Amount: decimal.NewFromInt(1)})
+	}
+	assert.Nil(t, conn.BatchAddBalanceTx(conn.SqlDB, items))
+	assert.Equal(t, cnt/DefaultBatchSize, statements)
+
+	count := func() int64 {
+		var stored int64
+		assert.Nil(t, conn.SqlDB.Model(&model.BalanceTxn{}).Where("chain = ?", chain).Count(&stored).Error)
+		return stored
+	}
+	assert.Equal(t, int64(cnt), count())
+
+	// the validation rejects the whole batch, without it the item is stored as it is
+	invalid := []*model.BalanceTxn{
+		{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xv1", Address: "0xa"},
+		{Chain: chain, Protocol: protocol, TxHash: "0xv2", Address: "0xa"},
+	}
+	conn.validateBalanceTx = true
+	assert.NotNil(t, conn.BatchAddBalanceTx(conn.SqlDB, invalid))
+	assert.NotNil(t, conn.BatchAddBalanceTx(conn.SqlDB, []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xv3"}}))
+	assert.Equal(t, int64(cnt), count())
+	conn.validateBalanceTx = false
+	assert.Nil(t, conn.BatchAddBalanceTx(conn.SqlDB, invalid))
+	assert.Equal(t, int64(cnt+2), count())
+}
+
+func TestConnPoolSettings(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:         DatabaseTypeSqlite3,
+		Dsn:          filepath.Join(t.TempDir(), "indexer.db"),
+		MaxOpenConns: 1,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.SqlDB.AutoMigrate(&model.Inscriptions{}))
+
+	sqlDB, err := conn.SqlDB.DB()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+
+	// concurrent queries wait for the single connection instead of failing
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := conn.FindInscriptionByTick("avalanche", "asc-20", "tick")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	assert.LessOrEqual(t, sqlDB.Stats().OpenConnections, 1)
+}
+
+func TestBatchUpsertBalances(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	exist := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Available: decimal.NewFromInt(10), Balance: decimal.NewFromInt(10)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, exist))
+
+	items := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Available: decimal.NewFromInt(4), Balance: decimal.NewFromInt(5)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xb", Available: decimal.NewFromInt(6), Balance: decimal.NewFromInt(6)},
+	}
+	assert.Nil(t, conn.BatchUpsertBalances(conn.SqlDB, chain, items))
+
+	balances, total, err := conn.GetBalancesByAddress(10, 0, "0xa", chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.True(t, balances[0].Available.Equal(decimal.NewFromInt(4)))
+	assert.True(t, balances[0].Balance.Equal(decimal.NewFromInt(5)))
+
+	balance, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xb")
+	assert.Nil(t, err)
+	assert.True(t, balance.Balance.Equal(decimal.NewFromInt(6)))
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.Balances{}).Count(&cnt).Error)
+	assert.Equal(t, int64(2), cnt)
+
+	assert.NotNil(t, conn.BatchUpsertBalances(conn.SqlDB, "btc", items))
+}
+
+func TestBatchUpsertInscriptionStats(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	exist := []*model.InscriptionsStats{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Minted: decimal.NewFromInt(100), Holders: 1, TxCnt: 1, MintFirstBlock: 10},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "b", Minted: decimal.NewFromInt(50), Holders: 2, TxCnt: 3},
+	}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, exist))
+
+	items := []*model.InscriptionsStats{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Minted: decimal.NewFromInt(300), Holders: 3, TxCnt: 4},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "c", Minted: decimal.NewFromInt(7), Holders: 1, TxCnt: 1, MintFirstBlock: 12},
+	}
+	assert.Nil(t, conn.BatchUpsertInscriptionStats(conn.SqlDB, chain, items))
+
+	expected := map[string]struct {
+		minted         int64
+		holders, txCnt uint64
+		mintFirstBlock uint64
+	}{
+		"a": {300, 3, 4, 10}, // the other columns of an existing row are kept
+		"b": {50, 2, 3, 0},
+		"c": {7, 1, 1, 12},
+	}
+	for tick, want := range expected {
+		stats, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+		assert.Nil(t, err)
+		assert.True(t, stats.Minted.Equal(decimal.NewFromInt(want.minted)), tick)
+		assert.Equal(t, want.holders, stats.Holders, tick)
+		assert.Equal(t, want.txCnt, stats.TxCnt, tick)
+		assert.Equal(t, want.mintFirstBlock, stats.MintFirstBlock, tick)
+	}
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.InscriptionsStats{}).Count(&cnt).Error)
+	assert.Equal(t, int64(3), cnt)
+
+	assert.NotNil(t, conn.BatchUpsertInscriptionStats(conn.SqlDB, "btc", items))
+}
+
+func TestGetTickMarketStats(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "tick", TotalSupply: decimal.NewFromInt(1000)}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: "tick", Minted: decimal.NewFromInt(250), Holders: 3, TxCnt: 7}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	market, err := conn.GetTickMarketStats(chain, protocol, "tick")
+	assert.Nil(t, err)
+	assert.True(t, market.Progress.Equal(market.Minted.Div(market.TotalSupply)), market.Progress.String())
+	assert.True(t, market.Progress.Equal(decimal.RequireFromString("0.25")))
+	assert.Equal(t, uint64(3), market.Holders)
+	assert.Equal(t, uint64(7), market.TxCnt)
+	assert.Nil(t, market.MintCompletedTime)
+
+	market, err = conn.GetTickMarketStats(chain, protocol, "none")
+	assert.Nil(t, err)
+	assert.Nil(t, market)
+}
+
+func TestGetDeployerStats(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newIns := func(sid uint32, tick, deployBy string, day int) *model.Inscriptions {
+		return &model.Inscriptions{SID: sid, Chain: chain, Protocol: protocol, Tick: tick, DeployBy: deployBy,
+			DeployHash: "0x" + tick, DeployTime: base.AddDate(0, 0, day)}
+	}
+	ins := []*model.Inscriptions{
+		newIns(1, "a", "0xprolific", 2),
+		newIns(2, "b", "0xprolific", 0),
+		newIns(3, "c", "0xprolific", 5),
+		newIns(4, "d", "0xonce", 1),
+		newIns(5, "e", "0xdeleted", 1),
+		{SID: 6, Chain: chain, Protocol: "brc-20", Tick: "f", DeployBy: "0xonce", DeployHash: "0xf", DeployTime: base},
+	}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, chain, protocol, "e"))
+
+	stats, err := conn.GetDeployerStats(chain, protocol, 10, 0)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(stats)) {
+		assert.Equal(t, "0xprolific", stats[0].DeployBy)
+		assert.Equal(t, int64(3), stats[0].Deploys)
+		assert.True(t, base.Equal(stats[0].FirstDeployTime), stats[0].FirstDeployTime.String())
+		assert.True(t, base.AddDate(0, 0, 5).Equal(stats[0].LastDeployTime), stats[0].LastDeployTime.String())
+
+		assert.Equal(t, "0xonce", stats[1].DeployBy)
+		assert.Equal(t, int64(1), stats[1].Deploys)
+		assert.True(t, stats[1].FirstDeployTime.Equal(stats[1].LastDeployTime))
+	}
+
+	stats, err = conn.GetDeployerStats(chain, protocol, 10, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(stats))
+
+	stats, err = conn.GetDeployerStats(chain, "missing", 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(stats))
+}
+
+func TestGetTopHoldersByTick(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	amounts := []int64{30, 50, 30, 0, 10, 50, 30}
+	balances := make([]*model.Balances, 0, len(amounts))
+	for i, amount := range amounts {
+		balances = append(balances, &model.Balances{SID: uint64(i + 1), Chain: chain, Protocol: protocol, Tick: tick,
+			Address: fmt.Sprintf("0x%d", i), Balance: decimal.NewFromInt(amount)})
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	holders, total, err := conn.GetTopHoldersByTick(10, 0, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), total)
+
+	expected := []struct {
+		address string
+		rank    int64
+	}{{"0x1", 1}, {"0x5", 1}, {"0x0", 3}, {"0x2", 3}, {"0x6", 3}, {"0x4", 6}}
+	assert.Equal(t, len(expected), len(holders))
+	for i, item := range expected {
+		assert.Equal(t, item.address, holders[i].Address)
+		assert.Equal(t, item.rank, holders[i].Rank)
+	}
+
+	// a page starting inside a tie keeps the rank of the tie
+	holders, _, err = conn.GetTopHoldersByTick(2, 3, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(holders))
+	assert.Equal(t, int64(3), holders[0].Rank)
+	assert.Equal(t, int64(3), holders[1].Rank)
+}
+
+func TestGetInscriptionHolderCount(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xdeployer", Balance: decimal.NewFromInt(100)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: decimal.NewFromInt(10)},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xb", Balance: decimal.RequireFromString("0.5")},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xzero", Balance: decimal.Zero},
+		{SID: 5, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xdead", Balance: decimal.NewFromInt(5)},
+		{SID: 6, Chain: chain, Protocol: protocol, Tick: "other", Address: "0xa", Balance: decimal.NewFromInt(10)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	cnt, err := conn.GetInscriptionHolderCount(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), cnt)
+
+	_, total, err := conn.GetHoldersByTick(1, 0, chain, protocol, tick, "", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, total, cnt)
+
+	cnt, err = conn.GetInscriptionHolderCount(chain, protocol, tick, "0xdeployer", "0xdead", "0xzero")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	cnt, err = conn.GetInscriptionHolderCount(chain, protocol, "missing")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), cnt)
+}
+
+func TestGetHoldersByTickMinBalance(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: decimal.NewFromInt(1000)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xb", Balance: decimal.NewFromInt(100)},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xc", Balance: decimal.RequireFromString("99.5")},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xd", Balance: decimal.RequireFromString("0.001")},
+		{SID: 5, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xzero", Balance: decimal.Zero},
+		{SID: 6, Chain: chain, Protocol: protocol, Tick: "other", Address: "0xe", Balance: decimal.NewFromInt(5000)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	addresses := func(holders []*model.Balances) []string {
+		list := make([]string, 0, len(holders))
+		for _, h := range holders {
+			list = append(list, h.Address)
+		}
+		return list
+	}
+
+	for _, minBalance := range []string{"", "0"} {
+		holders, total, err := conn.GetHoldersByTick(10, 0, chain, protocol, tick, minBalance, OrderByModeDesc)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), total, minBalance)
+		assert.Equal(t, []string{"0xa", "0xb", "0xc", "0xd"}, addresses(holders), minBalance)
+	}
+
+	// the bound is inclusive
+	holders, total, err := conn.GetHoldersByTick(10, 0, chain, protocol, tick, "100", OrderByModeAsc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"0xb", "0xa"}, addresses(holders))
+
+	// the total counts all the matching holders, not only the page
+	holders, total, err = conn.GetHoldersByTick(1, 0, chain, protocol, tick, "0.01", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []string{"0xa"}, addresses(holders))
+
+	holders, total, err = conn.GetHoldersByTick(10, 0, chain, protocol, tick, "1001", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Empty(t, holders)
+
+	_, _, err = conn.GetHoldersByTick(10, 0, chain, protocol, tick, "abc", OrderByModeDesc)
+	assert.NotNil(t, err)
+}
+
+func TestGetRichList(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	items := []struct {
+		protocol string
+		tick     string
+		address  string
+		balance  int64
+	}{
+		{protocol, "tick1", "0xa", 60},
+		{protocol, "tick2", "0xa", 50},
+		{protocol, "tick1", "0xb", 100},
+		{protocol, "tick2", "0xc", 100},
+		{protocol, "tick1", "0xd", 0},
+		{protocol, "tick2", "0xd", 10},
+		{"brc-20", "ordi", "0xe", 1000},
+	}
+	balances := make([]*model.Balances, 0, len(items))
+	for i, item := range items {
+		balances = append(balances, &model.Balances{SID: uint64(i + 1), Chain: chain, Protocol: item.protocol, Tick: item.tick,
+			Address: item.address, Balance: decimal.NewFromInt(item.balance)})
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	holdings, err := conn.GetRichList(chain, protocol, 10, 0)
+	assert.Nil(t, err)
+	expected := []struct {
+		address string
+		total   int64
+		ticks   int64
+	}{{"0xa", 110, 2}, {"0xb", 100, 1}, {"0xc", 100, 1}, {"0xd", 10, 1}}
+	assert.Equal(t, len(expected), len(holdings))
+	for i, item := range expected {
+		assert.Equal(t, item.address, holdings[i].Address)
+		assert.True(t, decimal.NewFromInt(item.total).Equal(holdings[i].Total), holdings[i].Total.String())
+		assert.Equal(t, item.ticks, holdings[i].Ticks)
+	}
+
+	holdings, err = conn.GetRichList(chain, protocol, 2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(holdings))
+	assert.Equal(t, "0xb", holdings[0].Address)
+	assert.Equal(t, "0xc", holdings[1].Address)
+
+	// a negative limit of the rpc does not panic, it is no limit
+	holdings, err = conn.GetRichList(chain, protocol, -1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expected), len(holdings))
+}
+
+func TestSoftDeleteInscription(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	ins := []*model.Inscriptions{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "keep"},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "drop"},
+	}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, chain, protocol, "drop"))
+
+	data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortById, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "keep", data[0].Tick)
+
+	dropped, err := conn.FindInscriptionByTick(chain, protocol, "drop")
+	assert.Nil(t, err)
+	assert.Nil(t, dropped)
+
+	audit := &model.Inscriptions{}
+	assert.Nil(t, conn.SqlDB.Unscoped().First(audit, "chain = ? AND tick = ?", chain, "drop").Error)
+	assert.True(t, audit.DeletedAt.Valid)
+
+	assert.Nil(t, conn.RestoreInscription(conn.SqlDB, chain, protocol, "drop"))
+	restored, err := conn.FindInscriptionByTick(chain, protocol, "drop")
+	assert.Nil(t, err)
+	assert.NotNil(t, restored)
+}
+
+func TestReadReplicas(t *testing.T) {
+	dir := t.TempDir()
+	sourceDsn, replicaDsn := filepath.Join(dir, "source.db"), filepath.Join(dir, "replica.db")
+	for _, dsn := range []string{sourceDsn, replicaDsn} {
+		db, err := NewDbClient(&config.DatabaseConfig{Type: DatabaseTypeSqlite3, Dsn: dsn})
+		assert.Nil(t, err)
+		assert.Nil(t, db.SqlDB.AutoMigrate(&model.Inscriptions{}, &model.BlockStatus{}))
+		sqlDB, _ := db.SqlDB.DB()
+		assert.Nil(t, sqlDB.Close())
+	}
+
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:     DatabaseTypeSqlite3,
+		Dsn:      sourceDsn,
+		Replicas: []string{replicaDsn},
+	})
+	assert.Nil(t, err)
+
+	chain, protocol, tick := "avalanche", "asc-20", "avav"
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick}}
+	dbTx := conn.SqlDB.Begin()
+	addInscriptions(t, conn, dbTx, ins)
+	assert.Nil(t, conn.SaveLastBlock(dbTx, &model.BlockStatus{Chain: chain, BlockNumber: 100}))
+
+	// uncommitted writes are invisible, even on the source
+	found, err := conn.Primary().FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+	assert.Nil(t, dbTx.Commit().Error)
+
+	found, err = conn.Primary().FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.NotNil(t, found)
+
+	// the replica never received the writes
+	found, err = conn.FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	lastBlock, err := conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Nil(t, lastBlock)
+
+	lastBlock, err = conn.Primary().QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), lastBlock.Int64())
+}
+
+func TestGetTransactionsByAddressRange(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick, address := "avalanche", "asc-20", "avav", "0x1"
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for block := uint64(100); block < 110; block++ {
+		hash := fmt.Sprintf("0x%d", block)
+		createdAt := base.Add(time.Duration(block-100) * time.Hour)
+		txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, BlockHeight: block, TxHash: hash, CreatedAt: createdAt}}
+		addTransactions(t, conn, conn.SqlDB, txs)
+		addressTxs := []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: address, Event: model.TransactionEventMint}}
+		assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, addressTxs))
+	}
+
+	cases := []struct {
+		name   string
+		filter TxRangeFilter
+		blocks []uint64
+	}{
+		{"unbounded", TxRangeFilter{}, []uint64{109, 108, 107, 106, 105, 104, 103, 102, 101, 100}},
+		{"blocks", TxRangeFilter{FromBlock: 102, ToBlock: 104}, []uint64{104, 103, 102}},
+		{"from block", TxRangeFilter{FromBlock: 108}, []uint64{109, 108}},
+		{"to block", TxRangeFilter{ToBlock: 101}, []uint64{101, 100}},
+		{"time", TxRangeFilter{FromTime: base.Add(5 * time.Hour), ToTime: base.Add(6 * time.Hour)}, []uint64{106, 105}},
+		{"blocks and time", TxRangeFilter{FromBlock: 103, ToTime: base.Add(4 * time.Hour)}, []uint64{104, 103}},
+	}
+	for _, c := range cases {
+		data, total, err := conn.GetTransactionsByAddress(2, 0, address, chain, protocol, tick, "", 0, c.filter, TxSortNewest)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, int64(len(c.blocks)), total, c.name)
+
+		expected := c.blocks
+		if len(expected) > 2 {
+			expected = expected[:2]
+		}
+		assert.Equal(t, len(expected), len(data), c.name)
+		for i := range data {
+			assert.Equal(t, fmt.Sprintf("0x%d", expected[i]), data[i].TxHash, c.name)
+		}
+	}
+}
+
+func TestGetBalanceHistory(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "avav"
+	amount := decimal.RequireFromString
+
+	// inserted latest first, the history follows the blocks instead of the row ids
+	entries := []struct {
+		hash    string
+		block   uint64
+		changes [][3]string // address, delta, balance after
+	}{
+		{"0xt2", 30, [][3]string{{"0xb", "-5", "25"}, {"0xa", "5", "75"}}},
+		{"0xt1", 20, [][3]string{{"0xa", "-30", "70"}, {"0xb", "30", "30"}}},
+		{"0xm1", 10, [][3]string{{"0xa", "100", "100"}}},
+	}
+	for _, entry := range entries {
+		txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, BlockHeight: entry.block, TxHash: entry.hash}}
+		addTransactions(t, conn, conn.SqlDB, txs)
+		for _, change := range entry.changes {
+			balanceTxs := []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: entry.hash, Address: change[0],
+				Event: model.TransactionEventTransfer, Amount: amount(change[1]), Balance: amount(change[2]), Available: amount(change[2])}}
+			assert.Nil(t, conn.BatchAddBalanceTx(conn.SqlDB, balanceTxs))
+		}
+	}
+
+	history, total, err := conn.GetBalanceHistory(chain, protocol, tick, "0xa", 10, 0, TxRangeFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	expected := [][3]string{{"0xm1", "100", "100"}, {"0xt1", "-30", "70"}, {"0xt2", "5", "75"}}
+	assert.Equal(t, len(expected), len(history))
+	for i, item := range expected {
+		assert.Equal(t, item[0], history[i].TxHash)
+		assert.True(t, amount(item[1]).Equal(history[i].Amount), history[i].Amount.String())
+		assert.True(t, amount(item[2]).Equal(history[i].Balance), history[i].Balance.String())
+	}
+
+	history, total, err = conn.GetBalanceHistory(chain, protocol, tick, "0xa", 1, 0, TxRangeFilter{FromBlock: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, "0xt1", history[0].TxHash)
+
+	history, total, err = conn.GetBalanceHistory(chain, protocol, tick, "0xb", 10, 0, TxRangeFilter{ToBlock: 20})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.True(t, amount("30").Equal(history[0].Amount))
+}
+
+func TestFindInscriptionStatsByTick(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	for i, tick := range []string{"a", "b"} {
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: tick}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: tick,
+			Minted: decimal.NewFromInt(int64(i+1) * 10), Holders: uint64(i + 1)}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+
+	// the two step lookup through the inscription sid
+	ins, err := conn.FindInscriptionByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	expected := &model.InscriptionsStats{}
+	assert.Nil(t, conn.SqlDB.Take(expected, "sid = ?", ins.SID).Error)
+
+	stats, err := conn.FindInscriptionStatsByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, expected.ID, stats.ID)
+	assert.Equal(t, expected.Tick, stats.Tick)
+	assert.True(t, expected.Minted.Equal(stats.Minted))
+	assert.Equal(t, expected.Holders, stats.Holders)
+
+	stats, err = conn.FindInscriptionStatsByTick(chain, protocol, "missing")
+	assert.Nil(t, err)
+	assert.Nil(t, stats)
+
+	// the deprecated lookup is an alias with the same semantics
+	stats, err = conn.FindInscriptionsStatsByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, expected.ID, stats.ID)
+	stats, err = conn.FindInscriptionsStatsByTick(chain, protocol, "missing")
+	assert.Nil(t, err)
+	assert.Nil(t, stats)
+}
+
+func TestInscriptionExtra(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	type mintRule struct {
+		MaxPerMint string   `json:"max_per_mint"`
+		Minters    []string `json:"minters"`
+	}
+	withExtra := &model.Inscriptions{SID: 1, Chain: chain, Protocol: protocol, Tick: "self"}
+	assert.Nil(t, withExtra.SetExtraValue("self_mint", true))
+	assert.Nil(t, withExtra.SetExtraValue("rule", mintRule{MaxPerMint: "1000", Minters: []string{"0x1", "0x2"}}))
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{withExtra, {SID: 2, Chain: chain, Protocol: protocol, Tick: "plain"}})
+
+	ins, err := conn.FindInscriptionByTick(chain, protocol, "self")
+	assert.Nil(t, err)
+	var selfMint bool
+	found, err := ins.ExtraValue("self_mint", &selfMint)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.True(t, selfMint)
+
+	var rule mintRule
+	found, err = ins.ExtraValue("rule", &rule)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, mintRule{MaxPerMint: "1000", Minters: []string{"0x1", "0x2"}}, rule)
+
+	found, err = ins.ExtraValue("missing", &rule)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	plain, err := conn.FindInscriptionByTick(chain, protocol, "plain")
+	assert.Nil(t, err)
+	found, err = plain.ExtraValue("self_mint", &selfMint)
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	data, _, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, Tick: "self"}, SortById, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(data))
+	assert.JSONEq(t, string(ins.Extra), string(data[0].Extra))
+
+	// the json functions of the database see the stored document
+	var ticks []string
+	err = conn.SqlDB.Model(&model.Inscriptions{}).Where(datatypes.JSONQuery("extra").Equals("1000", "rule", "max_per_mint")).
+		Pluck("tick", &ticks).Error
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"self"}, ticks)
+}
+
+func TestGetAddressStats(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, address := "avalanche", "asc-20", "0x1"
+
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: address, Balance: decimal.NewFromInt(10)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "b", Address: address, Balance: decimal.NewFromInt(20)},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "c", Address: address, Balance: decimal.Zero},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x2", Balance: decimal.NewFromInt(30)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	// 0xt2 touches the address twice (two ticks) & is stored once, 0xt4 is another address only
+	txs := []struct {
+		hash  string
+		block uint64
+		tick  string
+		addr  string
+	}{
+		{"0xt1", 120, "a", address},
+		{"0xt2", 105, "a", address},
+		{"0xt2", 105, "b", address},
+		{"0xt3", 130, "b", address},
+		{"0xt4", 90, "a", "0x2"},
+	}
+	for _, item := range txs {
+		_, err := conn.BatchAddTransaction(conn.SqlDB, []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: item.tick,
+			TxHash: item.hash, BlockHeight: item.block}})
+		assert.Nil(t, err)
+		assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: item.tick,
+			TxHash: item.hash, Address: item.addr, Event: model.TransactionEventTransfer}}))
+	}
+
+	stats, err := conn.GetAddressStats(chain, address)
+	assert.Nil(t, err)
+	assert.Equal(t, &model.AddressStats{Chain: chain, Address: address, Ticks: 2, TxCnt: 3, FirstBlock: 105, LastBlock: 130}, stats)
+
+	stats, err = conn.GetAddressStats(chain, "0xnone")
+	assert.Nil(t, err)
+	assert.Equal(t, &model.AddressStats{Chain: chain, Address: "0xnone"}, stats)
+
+	stats, err = conn.GetAddressStats("btc", address)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), stats.TxCnt)
+}
+
+func TestGetActivityMetrics(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	now := time.Now()
+	recent, old := now.Add(-time.Hour), now.Add(-48*time.Hour)
+
+	// the transfer 0xt2 involves two addresses, 0xa is active in both windows
+	txs := []struct {
+		chain     string
+		hash      string
+		blockTime time.Time
+		addrs     []string
+	}{
+		{chain, "0xt1", old, []string{"0xa"}},
+		{chain, "0xt2", old, []string{"0xb", "0xc"}},
+		{chain, "0xt3", recent, []string{"0xa", "0xd"}},
+		{chain, "0xt4", recent, []string{"0xa"}},
+		{chain, "0xt5", now, []string{"0xe"}},
+		{"btc", "0xt6", recent, []string{"0xf"}},
+	}
+	for _, item := range txs {
+		addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: item.chain, Protocol: protocol, TxHash: item.hash,
+			BlockTime: item.blockTime}})
+		for _, addr := range item.addrs {
+			assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, []*model.AddressTxs{{Chain: item.chain, Protocol: protocol, TxHash: item.hash,
+				Address: addr, Event: model.TransactionEventTransfer}}))
+		}
+	}
+
+	since := now.Add(-24 * time.Hour)
+	metrics, err := conn.GetActivityMetrics(chain, since)
+	assert.Nil(t, err)
+	assert.Equal(t, &model.ActivityMetrics{Chain: chain, Since: since, TxCnt: 3, ActiveAddresses: 3}, metrics)
+
+	// the window start is inclusive
+	metrics, err = conn.GetActivityMetrics(chain, old)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), metrics.TxCnt)
+	assert.Equal(t, int64(5), metrics.ActiveAddresses)
+
+	metrics, err = conn.GetActivityMetrics(chain, now.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), metrics.TxCnt)
+	assert.Equal(t, int64(0), metrics.ActiveAddresses)
+}
+
+func TestGetEventCounts(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	items := []struct {
+		tick  string
+		hash  string
+		event model.TxEvent
+		addrs []string
+	}{
+		{tick, "0xd1", model.TransactionEventDeploy, []string{"0xa"}},
+		{tick, "0xm1", model.TransactionEventMint, []string{"0xa"}},
+		{tick, "0xm2", model.TransactionEventMint, []string{"0xb"}},
+		{tick, "0xm3", model.TransactionEventMint, []string{"0xc"}},
+		// a transfer counts once whatever the number of addresses involved
+		{tick, "0xt1", model.TransactionEventTransfer, []string{"0xa", "0xb"}},
+		{tick, "0xt2", model.TransactionEventTransfer, []string{"0xb", "0xc", "0xd"}},
+		{"other", "0xm4", model.TransactionEventMint, []string{"0xa"}},
+	}
+	for _, item := range items {
+		for _, addr := range item.addrs {
+			assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: item.tick,
+				TxHash: item.hash, Address: addr, Event: item.event}}))
+		}
+	}
+
+	counts, err := conn.GetEventCounts(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, map[model.TxEvent]int64{model.TransactionEventDeploy: 1, model.TransactionEventMint: 3,
+		model.TransactionEventTransfer: 2}, counts)
+
+	counts, err = conn.GetEventCounts(chain, protocol, "unknown")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(counts))
+}
+
+func TestGetTableCounts(t *testing.T) {
+	conn := newTestClient(t)
+	protocol := "asc-20"
+	amount := decimal.NewFromInt(100)
+
+	// the sids are the ids of the saved balances & stats, unique over the chains
+	seed := func(chain string, firstSid, n int) {
+		for i := firstSid; i < firstSid+n; i++ {
+			hash, tick := fmt.Sprintf("%s-0x%d", chain, i), fmt.Sprintf("tick%d", i)
+			addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: uint32(i), Chain: chain, Protocol: protocol,
+				Tick: tick, DeployHash: hash}})
+			block := &testBlock{
+				txs: []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, BlockHeight: uint64(i), Op: "mint"}},
+				balanceTxs: []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: "0xa",
+					Event: model.TransactionEventMint, Amount: amount, Balance: amount, Available: amount}},
+				addressTxs: []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: "0xa",
+					Amount: amount, Event: model.TransactionEventMint}},
+				balances: []*model.Balances{{SID: uint64(i), Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa",
+					Balance: amount, Available: amount}},
+				stats:  &model.InscriptionsStats{SID: uint32(i), Chain: chain, Protocol: protocol, Tick: tick},
+				status: &model.BlockStatus{Chain: chain, BlockNumber: uint64(i)},
+			}
+			block.apply(t, conn)
+		}
+		_, err := conn.BatchAddUTXO(conn.SqlDB, []*model.UTXO{{Chain: chain, Protocol: protocol, Tick: "tick1", Address: "0xa",
+			Amount: amount, RootHash: chain, TxHash: chain, Status: model.UTXOStatusUnspent}})
+		assert.Nil(t, err)
+	}
+	seed("avalanche", 1, 3)
+	seed("btc", 4, 1)
+	// soft deleted inscriptions still take their space
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, "avalanche", protocol, "tick1"))
+
+	// sqlite always counts
+	for _, approximate := range []bool{false, true} {
+		counts, err := conn.GetTableCounts("avalanche", approximate)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int64{"inscriptions": 3, "balances": 3, "txs": 3, "address_txs": 3, "utxos": 1,
+			"balance_txn": 3}, counts)
+	}
+
+	counts, err := conn.GetTableCounts("missing", false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"inscriptions": 0, "balances": 0, "txs": 0, "address_txs": 0, "utxos": 0,
+		"balance_txn": 0}, counts)
+}
+
+func TestGetBlockStatus(t *testing.T) {
+	conn := newTestClient(t)
+
+	status, err := conn.GetBlockStatus("avalanche")
+	assert.Nil(t, err)
+	assert.Nil(t, status)
+
+	height, err := conn.QueryLastBlock("avalanche")
+	assert.Nil(t, err)
+	assert.Nil(t, height)
+
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "avalanche", BlockNumber: 100, BlockHash: "0xa"}))
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "bsc", BlockNumber: 200, BlockHash: "0xb"}))
+
+	status, err = conn.GetBlockStatus("avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), status.BlockNumber)
+	assert.Equal(t, "0xa", status.BlockHash)
+
+	height, err = conn.QueryLastBlock("bsc")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), height.Int64())
+
+	blocks, err := conn.LastBlocks([]string{"avalanche", "bsc", "eth"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(blocks))
+	assert.Equal(t, int64(100), blocks["avalanche"].Int64())
+	assert.Equal(t, int64(200), blocks["bsc"].Int64())
+	assert.Equal(t, int64(0), blocks["eth"].Int64())
+}
+
+func TestSaveLastBlockMonotonic(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	save := func(height uint64, hash string) bool {
+		applied, err := conn.SaveLastBlockMonotonic(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: height, BlockHash: hash})
+		assert.Nil(t, err)
+		return applied
+	}
+	assertStatus := func(height uint64, hash string) {
+		status, err := conn.GetBlockStatus(chain)
+		assert.Nil(t, err)
+		assert.Equal(t, height, status.BlockNumber)
+		assert.Equal(t, hash, status.BlockHash)
+	}
+
+	assert.True(t, save(100, "0x100"))
+	assertStatus(100, "0x100")
+
+	assert.True(t, save(101, "0x101"))
+	assertStatus(101, "0x101")
+
+	// stale and repeated heights are ignored
+	assert.False(t, save(99, "0x99"))
+	assert.False(t, save(101, "0xother"))
+	assertStatus(101, "0x101")
+
+	// an explicit reorg still moves the height back
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 90, BlockHash: "0x90"}))
+	assertStatus(90, "0x90")
+	assert.True(t, save(91, "0x91"))
+	assertStatus(91, "0x91")
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.BlockStatus{}).Where("chain = ?", chain).Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+}
+
+func TestGetIndexingLag(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	_, err := conn.GetIndexingLag(chain)
+	assert.True(t, errors.Is(err, ErrChainNotIndexed), err)
+	// the rows stored before the indexing time was tracked
+	assert.Nil(t, conn.SqlDB.Create(&model.BlockStatus{Chain: "bsc", BlockNumber: 1}).Error)
+	_, err = conn.GetIndexingLag("bsc")
+	assert.True(t, errors.Is(err, ErrChainNotIndexed), err)
+
+	indexedAt := time.Now().Add(-90 * time.Second)
+	assert.Nil(t, conn.SqlDB.Create(&model.BlockStatus{Chain: chain, BlockNumber: 100, IndexedAt: &indexedAt}).Error)
+	lag, err := conn.GetIndexingLag(chain)
+	assert.Nil(t, err)
+	assert.True(t, lag >= 90*time.Second && lag < 100*time.Second, lag.String())
+
+	// a stale height doesn't count as progress, a new one resets the lag
+	applied, err := conn.SaveLastBlockMonotonic(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 99})
+	assert.Nil(t, err)
+	assert.False(t, applied)
+	lag, err = conn.GetIndexingLag(chain)
+	assert.Nil(t, err)
+	assert.True(t, lag >= 90*time.Second, lag.String())
+
+	applied, err = conn.SaveLastBlockMonotonic(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 101})
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	lag, err = conn.GetIndexingLag(chain)
+	assert.Nil(t, err)
+	assert.True(t, lag < 10*time.Second, lag.String())
+
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "bsc", BlockNumber: 2}))
+	lag, err = conn.GetIndexingLag("bsc")
+	assert.Nil(t, err)
+	assert.True(t, lag < 10*time.Second, lag.String())
+}
+
+func TestQueryLastBlockCorrupt(t *testing.T) {
+	conn := newTestClient(t)
+	assert.Nil(t, conn.SqlDB.Exec("INSERT INTO block (chain, block_number) VALUES (?, ?), (?, ?)", "avalanche", "12a", "bsc", "").Error)
+
+	height, err := conn.QueryLastBlock("avalanche")
+	assert.Nil(t, height)
+	assert.True(t, errors.Is(err, ErrInvalidBlockNumber))
+	assert.Contains(t, err.Error(), "avalanche")
+	assert.Contains(t, err.Error(), "12a")
+
+	height, err = conn.QueryLastBlock("bsc")
+	assert.Nil(t, height)
+	assert.True(t, errors.Is(err, ErrInvalidBlockNumber))
+
+	status, err := conn.GetBlockStatus("avalanche")
+	assert.Nil(t, status)
+	assert.True(t, errors.Is(err, ErrInvalidBlockNumber))
+}
+
+func TestGetInscriptionsByAddress(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x1", Balance: decimal.NewFromInt(10)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "b", Address: "0x1", Balance: decimal.NewFromInt(20)},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x2", Balance: decimal.NewFromInt(30)},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: "c", Address: "0x1", Balance: decimal.NewFromInt(40)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	rows, err := conn.GetInscriptionsByAddress(10, 0, "0x1")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rows))
+	for i, tick := range []string{"c", "b", "a"} {
+		assert.Equal(t, "0x1", rows[i].Address)
+		assert.Equal(t, tick, rows[i].Tick)
+	}
+
+	rows, err = conn.GetInscriptionsByAddress(1, 1, "0x1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "b", rows[0].Tick)
+	assert.True(t, decimal.NewFromInt(20).Equal(rows[0].Balance))
+
+	rows, err = conn.GetInscriptionsByAddress(10, 0, "0x3")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+
+	_, err = conn.GetInscriptionsByAddress(10, 0, "")
+	assert.NotNil(t, err)
+}
+
+func TestGetProtocolSummary(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	items := []struct {
+		sid      uint32
+		protocol string
+		tick     string
+		minted   string
+		holders  uint64
+	}{
+		{1, protocol, "a", "1000.5", 10},
+		{2, protocol, "b", "20.25", 3},
+		{3, protocol, "c", "0", 0},
+		{4, "bsc-20", "d", "99", 7},
+	}
+	for _, item := range items {
+		ins := []*model.Inscriptions{{SID: item.sid, Chain: chain, Protocol: item.protocol, Tick: item.tick}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		stats := []*model.InscriptionsStats{{SID: item.sid, Chain: chain, Protocol: item.protocol, Tick: item.tick,
+			Minted: decimal.RequireFromString(item.minted), Holders: item.holders}}
+		assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	}
+	// a deploy without stats still counts as a tick
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 5, Chain: chain, Protocol: protocol, Tick: "e"}})
+
+	summary, err := conn.GetProtocolSummary(chain, protocol)
+	assert.Nil(t, err)
+	assert.Equal(t, chain, summary.Chain)
+	assert.Equal(t, protocol, summary.Protocol)
+	assert.Equal(t, int64(4), summary.Ticks)
+	assert.True(t, decimal.RequireFromString("1020.75").Equal(summary.Minted), summary.Minted.String())
+	assert.Equal(t, uint64(13), summary.Holders)
+
+	summary, err = conn.GetProtocolSummary(chain, "prc-20")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), summary.Ticks)
+	assert.True(t, summary.Minted.IsZero())
+	assert.Equal(t, uint64(0), summary.Holders)
+}
+
+func TestBatchAddUTXO(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "btc", "brc-20", "ordi"
+	newUTXO := func(txHash string, vout uint32, amount int64) *model.UTXO {
+		return &model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1", RootHash: txHash, TxHash: txHash,
+			Vout: vout, Amount: decimal.NewFromInt(amount), Status: model.UTXOStatusUnspent}
+	}
+
+	skipped, err := conn.BatchAddUTXO(conn.SqlDB, []*model.UTXO{newUTXO("0xt1", 0, 10), newUTXO("0xt1", 1, 20)})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(skipped))
+
+	// the outpoint is unique per chain
+	dup := newUTXO("0xt1", 1, 99)
+	other := newUTXO("0xt1", 1, 30)
+	other.Chain = "fractal"
+	skipped, err = conn.BatchAddUTXO(conn.SqlDB, []*model.UTXO{dup, newUTXO("0xt2", 0, 40), other})
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.UTXO{dup}, skipped)
+	assert.NotNil(t, conn.SqlDB.Create(newUTXO("0xt1", 0, 10)).Error)
+
+	// the outputs of a tx left at the same vout are rejected, not skipped as duplicates
+	for _, items := range [][]*model.UTXO{{newUTXO("0xt3", 0, 1), newUTXO("0xt3", 0, 2)}, {newUTXO("", 0, 1)}} {
+		skipped, err = conn.BatchAddUTXO(conn.SqlDB, items)
+		assert.ErrorIs(t, err, ErrInvalidOutpoint)
+		assert.Nil(t, skipped)
+	}
+	utxo, err := conn.GetUTXOByOutpoint(chain, "0xt3", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, utxo)
+
+	utxo, err = conn.GetUTXOByOutpoint(chain, "0xt1", 1)
+	assert.Nil(t, err)
+	if assert.NotNil(t, utxo) {
+		assert.True(t, decimal.NewFromInt(20).Equal(utxo.Amount), utxo.Amount.String())
+	}
+	utxo, err = conn.GetUTXOByOutpoint("fractal", "0xt1", 1)
+	assert.Nil(t, err)
+	if assert.NotNil(t, utxo) {
+		assert.True(t, decimal.NewFromInt(30).Equal(utxo.Amount), utxo.Amount.String())
+	}
+
+	// spent utxos are found as well
+	ok, err := conn.MarkUTXOSpent(conn.SqlDB, chain, "0xt2", "0x1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	utxo, err = conn.GetUTXOByOutpoint(chain, "0xt2", 0)
+	assert.Nil(t, err)
+	if assert.NotNil(t, utxo) {
+		assert.Equal(t, int8(model.UTXOStatusSpent), utxo.Status)
+	}
+
+	utxo, err = conn.GetUTXOByOutpoint(chain, "0xt1", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, utxo)
+}
+
+func TestMarkUTXOSpent(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "avav"
+	var utxos []*model.UTXO
+	for i := 1; i <= 4; i++ {
+		utxos = append(utxos, &model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+			RootHash: fmt.Sprintf("0xr%d", i), TxHash: fmt.Sprintf("0xr%d", i), Amount: decimal.NewFromInt(10), Status: model.UTXOStatusUnspent})
+	}
+	assert.Nil(t, conn.SqlDB.Create(utxos).Error)
+
+	ok, err := conn.MarkUTXOSpent(conn.SqlDB, chain, "0xr1", "0x1")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// double spend & wrong owner don't transition
+	ok, err = conn.MarkUTXOSpent(conn.SqlDB, chain, "0xr1", "0x1")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = conn.MarkUTXOSpent(conn.SqlDB, chain, "0xr2", "0x2")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	cnt, err := conn.BatchMarkUTXOSpent(conn.SqlDB, chain, []string{"0xr1", "0xr2", "0xr3", "0xr9"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), cnt)
+
+	cnt, err = conn.BatchMarkUTXOSpent(conn.SqlDB, chain, []string{"0xr1", "0xr2", "0xr3"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), cnt)
+
+	unspent, err := conn.GetUTXOCount("0x1", chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), unspent)
+}
+
+func TestLockUTXOForSpend(t *testing.T) {
+	// sqlite has no row locks, the immediate transactions serialize the spends instead
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:           DatabaseTypeSqlite3,
+		Dsn:            filepath.Join(t.TempDir(), "indexer.db") + "?_txlock=immediate",
+		QueryTimeoutMs: 5000,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+	testLockUTXOForSpend(t, conn)
+}
+
+// testLockUTXOForSpend spends an utxo in two concurrent transactions, the second one must wait for the first one to
+// commit and see the utxo spent. Shared by the sqlite test and the env gated mysql & postgres ones.
+func testLockUTXOForSpend(t *testing.T, conn *DBClient) {
+	chain, protocol, tick := "btc", "brc-20", "ordi"
+
+	utxos := []*model.UTXO{{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1", RootHash: "0xr1", TxHash: "0xr1", Vout: 1,
+		Amount: decimal.NewFromInt(10), Status: model.UTXOStatusUnspent}}
+	assert.Nil(t, conn.SqlDB.Create(utxos).Error)
+
+	utxo, err := conn.LockUTXOForSpend(conn.SqlDB, chain, "0xr1", 0)
+	assert.Nil(t, err)
+	assert.Nil(t, utxo)
+
+	tx1 := conn.SqlDB.Begin()
+	assert.Nil(t, tx1.Error)
+	utxo, err = conn.LockUTXOForSpend(tx1, chain, "0xr1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(model.UTXOStatusUnspent), utxo.Status)
+	ok, err := conn.MarkUTXOSpent(tx1, chain, utxo.RootHash, utxo.Address)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// the second spend waits for the first one and sees the utxo spent
+	started := make(chan struct{})
+	done := make(chan *model.UTXO, 1)
+	go func() {
+		close(started)
+		var locked *model.UTXO
+		err := conn.SqlDB.Transaction(func(tx2 *gorm.DB) (err error) {
+			locked, err = conn.LockUTXOForSpend(tx2, chain, "0xr1", 1)
+			return err
+		})
+		assert.Nil(t, err)
+		done <- locked
+	}()
+
+	<-started
+	select {
+	case <-done:
+		t.Fatal("the second spend did not wait for the lock of the first one")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Nil(t, tx1.Commit().Error)
+	utxo = <-done
+	if assert.NotNil(t, utxo) {
+		assert.Equal(t, int8(model.UTXOStatusSpent), utxo.Status)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	start := time.Now().Add(-time.Second)
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a"}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: "a", TxHash: "0x1"}}
+	addTransactions(t, conn, conn.SqlDB, txs)
+	assert.True(t, ins[0].CreatedAt.After(start))
+	assert.True(t, ins[0].UpdatedAt.After(start))
+	assert.True(t, txs[0].CreatedAt.After(start))
+
+	// seed balances with an old updated_at, the raw batch update must move it forward
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x1", CreatedAt: old, UpdatedAt: old},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x2", CreatedAt: old, UpdatedAt: old},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x3"},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	assert.True(t, balances[2].UpdatedAt.After(start))
+
+	balances[0].Balance = decimal.NewFromInt(5)
+	assert.Nil(t, conn.BatchUpdateBalances(conn.SqlDB, chain, balances[:1]))
+
+	updated, err := conn.FindUserBalanceByTick(chain, protocol, "a", "0x1")
+	assert.Nil(t, err)
+	assert.True(t, updated.UpdatedAt.After(start))
+	assert.True(t, updated.CreatedAt.Equal(old))
+
+	// recently updated balances, paged by (updated_at, id)
+	rows, err := conn.GetBalancesUpdatedSince(chain, start, 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rows))
+	addresses := map[string]bool{}
+	for _, row := range rows {
+		addresses[row.Address] = true
+	}
+	assert.True(t, addresses["0x1"] && addresses["0x3"])
+
+	page, err := conn.GetBalancesUpdatedSince(chain, start, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+	next, err := conn.GetBalancesUpdatedSince(chain, page[0].UpdatedAt, page[0].ID, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(next))
+	assert.NotEqual(t, page[0].Address, next[0].Address)
+
+	rows, err = conn.GetBalancesUpdatedSince(chain, old, 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, "0x2", rows[0].Address)
+}
+
+func TestIterateBalances(t *testing.T) {
+	conn := newTestClient(t)
+	balances := make([]*model.Balances, 0, 251)
+	for i := 1; i <= 250; i++ {
+		balances = append(balances, &model.Balances{SID: uint64(i), Chain: "avalanche", Protocol: "asc-20", Tick: "a", Address: fmt.Sprintf("0x%d", i)})
+	}
+	balances = append(balances, &model.Balances{SID: 251, Chain: "bsc", Protocol: "bsc-20", Tick: "a", Address: "0x1"})
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	calls := 0
+	seen := map[uint64]bool{}
+	err := conn.IterateBalances("avalanche", 100, func(batch []model.Balances) error {
+		calls++
+		for _, item := range batch {
+			assert.Equal(t, "avalanche", item.Chain)
+			seen[item.SID] = true
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 250, len(seen))
+
+	// an error of the callback stops the iteration
+	stop := errors.New("stop")
+	calls = 0
+	err = conn.IterateBalances("avalanche", 100, func(batch []model.Balances) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIterateInscriptionStats(t *testing.T) {
+	conn := newTestClient(t)
+	stats := make([]*model.InscriptionsStats, 0, 25)
+	for i := 1; i <= 25; i++ {
+		chain := "avalanche"
+		if i%5 == 0 {
+			chain = "bsc"
+		}
+		stats = append(stats, &model.InscriptionsStats{SID: uint32(i), Chain: chain, Protocol: "asc-20", Tick: fmt.Sprintf("t%d", i)})
+	}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	calls := 0
+	seen := map[uint32]bool{}
+	err := conn.IterateInscriptionStats(context.Background(), 10, func(batch []model.InscriptionsStats) error {
+		calls++
+		for _, item := range batch {
+			seen[item.SID] = true
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 25, len(seen))
+
+	// cancelling within the first batch stops before the next one
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls = 0
+	err = conn.IterateInscriptionStats(ctx, 10, func(batch []model.InscriptionsStats) error {
+		calls++
+		cancel()
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetUtxosByAddressPage(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "avav"
+	utxos := make([]*model.UTXO, 0, 26)
+	for i := 1; i <= 25; i++ {
+		utxos = append(utxos, &model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+			RootHash: fmt.Sprintf("0xr%d", i), TxHash: fmt.Sprintf("0xr%d", i), Amount: decimal.RequireFromString("0.1"), Status: model.UTXOStatusUnspent})
+	}
+	utxos = append(utxos, &model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+		RootHash: "0xspent", TxHash: "0xspent", Amount: decimal.NewFromInt(100), Status: model.UTXOStatusSpent})
+	assert.Nil(t, conn.SqlDB.Create(utxos).Error)
+
+	seen := map[string]bool{}
+	for offset := 0; offset < 30; offset += 10 {
+		page, err := conn.GetUtxosByAddress(10, offset, "0x1", chain, protocol, tick)
+		assert.Nil(t, err)
+		expected := 10
+		if offset == 20 {
+			expected = 5
+		}
+		assert.Equal(t, expected, len(page))
+		for _, u := range page {
+			seen[u.RootHash] = true
+		}
+	}
+	assert.Equal(t, 25, len(seen))
+	assert.False(t, seen["0xspent"])
+
+	amount, cnt, err := conn.SumUTXOValue("0x1", chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), cnt)
+	assert.True(t, decimal.RequireFromString("2.5").Equal(decimal.RequireFromString(amount)), amount)
+
+	amount, cnt, err = conn.SumUTXOValue("0x2", chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), cnt)
+	assert.Equal(t, "0", amount)
+}
+
+func TestGetBalanceAtBlock(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	// the balance changes of 0xa, the float sums of the deltas are inexact, e.g. 0.1 + 0.2
+	changes := []struct {
+		block uint64
+		delta string
+	}{
+		{100, "0.1"},
+		{100, "0.2"},
+		{105, "-0.3"},
+		{110, "1000.7"},
+		{120, "-0.6"},
+	}
+	balance := decimal.Zero
+	expected := make(map[uint64]decimal.Decimal, len(changes))
+	for i, change := range changes {
+		hash := fmt.Sprintf("0x%d", i)
+		delta := decimal.RequireFromString(change.delta)
+		balance = balance.Add(delta)
+		expected[change.block] = balance
+
+		addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick,
+			TxHash: hash, BlockHeight: change.block, PositionInBlock: uint64(i)}})
+		assert.Nil(t, conn.BatchAddBalanceTx(conn.SqlDB, []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick,
+			TxHash: hash, BlockNumber: change.block, Address: "0xa", Amount: delta, Balance: balance, Available: balance}}))
+	}
+	// the changes of the other addresses are not summed
+	assert.Nil(t, conn.BatchAddBalanceTx(conn.SqlDB, []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick,
+		TxHash: "0x0", BlockNumber: 100, Address: "0xb", Amount: decimal.NewFromInt(7)}}))
+
+	at := func(block uint64) decimal.Decimal {
+		ret, err := conn.GetBalanceAtBlock(chain, protocol, tick, "0xa", block)
+		assert.Nil(t, err)
+		return decimal.RequireFromString(ret)
+	}
+	assert.True(t, at(99).IsZero())
+	assert.Equal(t, "0.3", at(100).String())
+	assert.Equal(t, "0.3", at(104).String())
+	assert.True(t, at(105).IsZero(), at(105).String())
+	assert.Equal(t, "1000.7", at(110).String())
+	assert.Equal(t, "1000.1", at(120).String())
+	for block, balance := range expected {
+		assert.True(t, balance.Equal(at(block)), "block %d", block)
+	}
+	assert.True(t, at(1000).Equal(balance))
+}
+
+func TestGetInscriptionsByDeployBlockRange(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	// deploys at the blocks 100, 110, ..., 200 inserted out of order
+	for i, block := range []uint64{200, 150, 100, 120, 180, 110, 190, 130, 170, 140, 160} {
+		tick, hash := fmt.Sprintf("t%d", block), fmt.Sprintf("0xd%d", block)
+		ins := []*model.Inscriptions{{SID: uint32(i + 1), Chain: chain, Protocol: protocol, Tick: tick, DeployHash: hash}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, BlockHeight: block, Op: "deploy"}}
+		addTransactions(t, conn, conn.SqlDB, txs)
+	}
+	// the other chains and the other ops of the deploy block are ignored
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 100, Chain: "bsc", Protocol: protocol, Tick: "t150", DeployHash: "0xbsc"}})
+	addTransactions(t, conn, conn.SqlDB, []*model.Transaction{
+		{Chain: "bsc", Protocol: protocol, Tick: "t150", TxHash: "0xbsc", BlockHeight: 150, Op: "deploy"},
+		{Chain: chain, Protocol: protocol, Tick: "t150", TxHash: "0xm150", BlockHeight: 150, Op: "mint"},
+	})
+
+	ticks := func(data []*model.Inscriptions) []string {
+		ret := make([]string, 0, len(data))
+		for _, item := range data {
+			ret = append(ret, item.Tick)
+		}
+		return ret
+	}
+
+	data, err := conn.GetInscriptionsByDeployBlockRange(chain, 120, 180)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"t120", "t130", "t140", "t150", "t160", "t170", "t180"}, ticks(data))
+
+	data, err = conn.GetInscriptionsByDeployBlockRange(chain, 185, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"t190", "t200"}, ticks(data))
+
+	_, err = conn.GetInscriptionsByDeployBlockRange(chain, 180, 120)
+	assert.NotNil(t, err)
+}
+
+func TestBatchUpdateBalancesVersionConflict(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1", Balance: decimal.NewFromInt(10)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0x2", Balance: decimal.NewFromInt(20)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	// two workers read the same balance
+	first, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+	stale, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+
+	first.Balance = decimal.NewFromInt(15)
+	assert.Nil(t, conn.BatchUpdateBalances(conn.SqlDB, chain, []*model.Balances{first}))
+	assert.Equal(t, uint(1), first.Version)
+
+	// the stale write is rejected as a whole, the fresh balance of the batch is not written either
+	other, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x2")
+	assert.Nil(t, err)
+	stale.Balance = decimal.NewFromInt(1)
+	other.Balance = decimal.NewFromInt(25)
+	err = conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+		return conn.BatchUpdateBalances(tx, chain, []*model.Balances{stale, other})
+	})
+	assert.True(t, errors.Is(err, ErrBalanceVersionConflict), err)
+	assert.Equal(t, uint(0), stale.Version)
+
+	stored, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+	assert.True(t, stored.Balance.Equal(decimal.NewFromInt(15)), stored.Balance.String())
+	assert.Equal(t, uint(1), stored.Version)
+	stored, err = conn.FindUserBalanceByTick(chain, protocol, tick, "0x2")
+	assert.Nil(t, err)
+	assert.True(t, stored.Balance.Equal(decimal.NewFromInt(20)), stored.Balance.String())
+	assert.Equal(t, uint(0), stored.Version)
+
+	// re-read & retry
+	fresh, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+	fresh.Balance = decimal.NewFromInt(1)
+	assert.Nil(t, conn.BatchUpdateBalances(conn.SqlDB, chain, []*model.Balances{fresh, other}))
+	assert.Equal(t, uint(2), fresh.Version)
+	assert.Equal(t, uint(1), other.Version)
+}
+
+func TestRecalculateHolders(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	// a: 2 holders of 3 balances, b: no holder, c: 1 holder
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x1", Balance: decimal.NewFromInt(10)},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x2", Balance: decimal.RequireFromString("0.5")},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "a", Address: "0x3", Balance: decimal.Zero},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: "c", Address: "0x1", Balance: decimal.NewFromInt(1)},
+		{SID: 5, Chain: "bsc", Protocol: protocol, Tick: "b", Address: "0x1", Balance: decimal.NewFromInt(1)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	// a & b drifted, c is accurate
+	stats := []*model.InscriptionsStats{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Holders: 5},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "b", Holders: 1},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "c", Holders: 1},
+	}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	drifted, err := conn.GetStatsNeedingHolderRecount(chain, 10)
+	assert.Nil(t, err)
+	ticks := make([]string, 0, len(drifted))
+	for _, item := range drifted {
+		ticks = append(ticks, item.Tick)
+	}
+	assert.Equal(t, []string{"a", "b"}, ticks)
+
+	drifted, err = conn.GetStatsNeedingHolderRecount(chain, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(drifted))
+
+	drifted, err = conn.GetStatsNeedingHolderRecount(chain, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(drifted))
+
+	for tick, expected := range map[string]int64{"a": 2, "b": 0} {
+		holders, err := conn.RecalculateHolders(conn.SqlDB, chain, protocol, tick)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, holders, tick)
+
+		item, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(expected), item.Holders, tick)
+	}
+
+	drifted, err = conn.GetStatsNeedingHolderRecount(chain, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(drifted))
+
+	_, err = conn.RecalculateHolders(nil, chain, protocol, "a")
+	assert.NotNil(t, err)
+}
+
+func TestGetUTXOsByAddressTick(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "btc", "brc-20"
+	newUTXO := func(txHash, address, tick string, status int8) *model.UTXO {
+		return &model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: address, RootHash: txHash, TxHash: txHash,
+			Amount: decimal.NewFromInt(10), Status: status}
+	}
+
+	// none
+	utxos, err := conn.GetUTXOsByAddressTick("0x1", "ordi")
+	assert.Nil(t, err)
+	assert.Nil(t, utxos)
+
+	_, err = conn.BatchAddUTXO(conn.SqlDB, []*model.UTXO{
+		newUTXO("0xt1", "0x1", "ordi", model.UTXOStatusUnspent),
+		newUTXO("0xt2", "0x1", "sats", model.UTXOStatusUnspent),
+		newUTXO("0xt3", "0x1", "sats", model.UTXOStatusUnspent),
+		newUTXO("0xt4", "0x1", "ordi", model.UTXOStatusSpent),
+		newUTXO("0xt5", "0x2", "ordi", model.UTXOStatusUnspent),
+	})
+	assert.Nil(t, err)
+
+	txHashes := func(utxos []*model.UTXO) []string {
+		ret := make([]string, 0, len(utxos))
+		for _, utxo := range utxos {
+			ret = append(ret, utxo.TxHash)
+		}
+		return ret
+	}
+
+	// one, the spent ones are left out
+	utxos, err = conn.GetUTXOsByAddressTick("0x1", "ordi")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xt1"}, txHashes(utxos))
+
+	// many, all the ticks without tick
+	utxos, err = conn.GetUTXOsByAddressTick("0x1", "sats")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xt2", "0xt3"}, txHashes(utxos))
+	utxos, err = conn.GetUTXOsByAddressTick("0x1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xt1", "0xt2", "0xt3"}, txHashes(utxos))
+
+	// the transaction sees its own writes
+	_, err = conn.GetUTXOsByAddressTickTx(nil, "0x1", "")
+	assert.NotNil(t, err)
+	tx := conn.SqlDB.Begin()
+	assert.Nil(t, tx.Error)
+	_, err = conn.BatchMarkUTXOSpent(tx, chain, []string{"0xt1"})
+	assert.Nil(t, err)
+	_, err = conn.BatchAddUTXO(tx, []*model.UTXO{newUTXO("0xt6", "0x1", "ordi", model.UTXOStatusUnspent)})
+	assert.Nil(t, err)
+	utxos, err = conn.GetUTXOsByAddressTickTx(tx, "0x1", "ordi")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xt6"}, txHashes(utxos))
+	assert.Nil(t, tx.Rollback().Error)
+
+	utxos, err = conn.GetUTXOsByAddressTick("0x1", "ordi")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xt1"}, txHashes(utxos))
+}
+
+func TestSelectUTXOs(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "btc", "brc-20", "ordi"
+
+	amounts := []string{"1.5", "10", "3.25", "0.25"}
+	for i, amount := range amounts {
+		assert.Nil(t, conn.SqlDB.Create(&model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+			RootHash: fmt.Sprintf("0xr%d", i), TxHash: fmt.Sprintf("0xr%d", i), Amount: decimal.RequireFromString(amount), Status: model.UTXOStatusUnspent}).Error)
+	}
+	// spent utxos and the other addresses are never selected
+	assert.Nil(t, conn.SqlDB.Create(&model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+		RootHash: "0xspent", TxHash: "0xspent", Amount: decimal.NewFromInt(100), Status: model.UTXOStatusSpent}).Error)
+	assert.Nil(t, conn.SqlDB.Create(&model.UTXO{Chain: chain, Protocol: protocol, Tick: tick, Address: "0x2",
+		RootHash: "0xother", TxHash: "0xother", Amount: decimal.NewFromInt(100), Status: model.UTXOStatusUnspent}).Error)
+
+	rootHashes := func(utxos []*model.UTXO) []string {
+		ret := make([]string, 0, len(utxos))
+		for _, utxo := range utxos {
+			ret = append(ret, utxo.RootHash)
+		}
+		return ret
+	}
+
+	// exact cover
+	utxos, total, err := conn.SelectUTXOs("0x1", chain, protocol, tick, "13.25")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xr1", "0xr2"}, rootHashes(utxos))
+	assert.True(t, decimal.RequireFromString("13.25").Equal(decimal.RequireFromString(total)), total)
+
+	// over cover
+	utxos, total, err = conn.SelectUTXOs("0x1", chain, protocol, tick, "13.3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0xr1", "0xr2", "0xr0"}, rootHashes(utxos))
+	assert.True(t, decimal.RequireFromString("14.75").Equal(decimal.RequireFromString(total)), total)
+
+	// insufficient funds
+	utxos, _, err = conn.SelectUTXOs("0x1", chain, protocol, tick, "15.01")
+	assert.True(t, errors.Is(err, ErrInsufficientUTXO), err)
+	assert.Nil(t, utxos)
+
+	_, _, err = conn.SelectUTXOs("0x1", chain, protocol, tick, "abc")
+	assert.NotNil(t, err)
+	_, _, err = conn.SelectUTXOs("0x1", chain, protocol, tick, "0")
+	assert.NotNil(t, err)
+}
+
+func TestGetRowsByIdLimit(t *testing.T) {
+	conn := newTestClient(t)
+	for i := 1; i <= 5; i++ {
+		chain := "avalanche"
+		if i%2 == 0 {
+			chain = "bsc"
+		}
+		ins := []*model.Inscriptions{{SID: uint32(i), Chain: chain, Protocol: "asc-20", Tick: fmt.Sprintf("t%d", i)}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+
+		status := model.UTXOStatusUnspent
+		if i == 3 {
+			status = model.UTXOStatusSpent
+		}
+		assert.Nil(t, conn.SqlDB.Create(&model.UTXO{Chain: chain, RootHash: fmt.Sprintf("0xr%d", i), TxHash: fmt.Sprintf("0xr%d", i), Status: int8(status)}).Error)
+	}
+
+	inscriptions, err := GetRowsByIdLimit[model.Inscriptions](conn, 1, 10, WhereChain("avalanche"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(inscriptions))
+	assert.Equal(t, "t3", inscriptions[0].Tick)
+	assert.Equal(t, "t5", inscriptions[1].Tick)
+
+	all, err := GetRowsByIdLimit[model.Inscriptions](conn, 0, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(all))
+
+	utxos, err := GetRowsByIdLimit[model.UTXO](conn, 0, 10, WhereUTXOUnspent, WhereChain("avalanche"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(utxos))
+	assert.Equal(t, "0xr1", utxos[0].RootHash)
+	assert.Equal(t, "0xr5", utxos[1].RootHash)
+
+	// the wrappers keep their behaviour
+	wrapped, err := conn.GetUTXOsByIdLimit(0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(wrapped))
+}
+
+func TestBatchAddInscriptionDuplicate(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", DeployHash: "0x1"}})
+
+	// a re-deploy of the tick and a duplicate inside the batch are skipped, the new tick is inserted
+	items := []*model.Inscriptions{
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "a", DeployHash: "0x2"},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: "b", DeployHash: "0x3"},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: "b", DeployHash: "0x4"},
+	}
+	skipped, err := conn.BatchAddInscription(conn.SqlDB, items)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(skipped))
+	assert.Equal(t, "0x2", skipped[0].DeployHash)
+	assert.Equal(t, "0x4", skipped[1].DeployHash)
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.Inscriptions{}).Where("chain = ? AND tick = ?", chain, "a").Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+
+	ins, err := conn.FindInscriptionByTick(chain, protocol, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x1", ins.DeployHash)
+	ins, err = conn.FindInscriptionByTick(chain, protocol, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x3", ins.DeployHash)
+}
+
+func TestPingHealthCheck(t *testing.T) {
+	conn := newTestClient(t)
+	ctx := context.Background()
+	assert.Nil(t, conn.Ping(ctx))
+
+	status, err := conn.HealthCheck(ctx)
+	assert.Nil(t, err)
+	assert.True(t, status.Healthy)
+	assert.True(t, status.OpenConnections > 0)
+	assert.Equal(t, status.OpenConnections, status.InUse+status.Idle)
+
+	sqlDB, err := conn.SqlDB.DB()
+	assert.Nil(t, err)
+	assert.Nil(t, sqlDB.Close())
+	assert.NotNil(t, conn.Ping(ctx))
+
+	status, err = conn.HealthCheck(ctx)
+	assert.NotNil(t, err)
+	assert.False(t, status.Healthy)
+	assert.NotEmpty(t, status.Error)
+}
+
+func TestClose(t *testing.T) {
+	conn := newTestClient(t)
+	primary := conn.Primary()
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "a"}})
+
+	assert.Nil(t, conn.Close())
+	assert.Nil(t, conn.Close())
+
+	_, err := conn.FindInscriptionByTick("avalanche", "asc-20", "a")
+	assert.True(t, errors.Is(err, ErrClientClosed))
+	_, err = primary.FindInscriptionByTick("avalanche", "asc-20", "a")
+	assert.True(t, errors.Is(err, ErrClientClosed))
+	_, err = conn.BatchAddInscription(conn.SqlDB, []*model.Inscriptions{{SID: 2, Chain: "avalanche", Protocol: "asc-20", Tick: "b"}})
+	assert.True(t, errors.Is(err, ErrClientClosed))
+	_, err = conn.QueryLastBlock("avalanche")
+	assert.True(t, errors.Is(err, ErrClientClosed))
+	assert.Equal(t, ErrClientClosed, conn.Ping(context.Background()))
+}
+
+func TestStorageErrors(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	// nil transaction
+	assert.ErrorIs(t, conn.SaveLastBlock(nil, &model.BlockStatus{Chain: chain}), ErrNilTx)
+	_, err := conn.SaveLastBlockMonotonic(nil, &model.BlockStatus{Chain: chain})
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.BatchMarkUTXOSpent(nil, chain, []string{"0x1"})
+	assert.ErrorIs(t, err, ErrNilTx)
+	assert.ErrorIs(t, conn.DeleteDataAboveBlock(nil, chain, 1), ErrNilTx)
+
+	// every writer rejects the nil transaction instead of dereferencing it
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: "asc-20", Tick: "avav"}}
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: "asc-20", Tick: "avav"}}
+	balances := []*model.Balances{{SID: 1, Chain: chain, Protocol: "asc-20", Tick: "avav", Address: "0xa"}}
+	utxos := []*model.UTXO{{Chain: chain, TxHash: "0x1", Address: "0xa"}}
+	txs := []*model.Transaction{{Chain: chain, TxHash: "0x1"}}
+	_, err = conn.BatchAddInscription(nil, ins)
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.BatchAddUTXO(nil, utxos)
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.BatchAddTransaction(nil, txs)
+	assert.ErrorIs(t, err, ErrNilTx)
+	err, _ = conn.BatchUpdatesBySID(nil, chain, model.Inscriptions{}.TableName(), map[string]string{"transfer_type": "%d"},
+		[]map[string]interface{}{{"sid": 1, "transfer_type": 1}})
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.MarkUTXOSpent(nil, chain, "0x1", "0xa")
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.LockUTXOForSpend(nil, chain, "0x1", 0)
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.RecalculateHolders(nil, chain, "asc-20", "avav")
+	assert.ErrorIs(t, err, ErrNilTx)
+	_, err = conn.PurgeChainData(nil, chain)
+	assert.ErrorIs(t, err, ErrNilTx)
+	for name, write := range map[string]func() error{
+		"CreateInBatches":             func() error { return conn.CreateInBatches(nil, txs, 10) },
+		"BatchUpdateInscription":      func() error { return conn.BatchUpdateInscription(nil, chain, ins) },
+		"SoftDeleteInscription":       func() error { return conn.SoftDeleteInscription(nil, chain, "asc-20", "avav") },
+		"RestoreInscription":          func() error { return conn.RestoreInscription(nil, chain, "asc-20", "avav") },
+		"BatchAddInscriptionStats":    func() error { return conn.BatchAddInscriptionStats(nil, stats) },
+		"BatchUpdateInscriptionStats": func() error { return conn.BatchUpdateInscriptionStats(nil, chain, stats) },
+		"BatchUpsertInscriptionStats": func() error { return conn.BatchUpsertInscriptionStats(nil, chain, stats) },
+		"UpdateInscriptionsStatsBySID": func() error {
+			return conn.UpdateInscriptionsStatsBySID(nil, chain, 1, map[string]interface{}{"holders": 1})
+		},
+		"BatchAddBalanceTx": func() error {
+			return conn.BatchAddBalanceTx(nil, []*model.BalanceTxn{{Chain: chain, TxHash: "0x1", Address: "0xa", Tick: "avav"}})
+		},
+		"BatchAddAddressTx": func() error {
+			return conn.BatchAddAddressTx(nil, []*model.AddressTxs{{Chain: chain, TxHash: "0x1", Address: "0xa", Event: model.TransactionEventMint}})
+		},
+		"BatchAddBalances":    func() error { return conn.BatchAddBalances(nil, balances) },
+		"BatchUpdateBalances": func() error { return conn.BatchUpdateBalances(nil, chain, balances) },
+		"BatchUpsertBalances": func() error { return conn.BatchUpsertBalances(nil, chain, balances) },
+		"LabelAddress": func() error {
+			return conn.LabelAddress(nil, &model.AddressLabel{Chain: chain, Address: "0xa", Category: model.LabelCategoryBurn})
+		},
+	} {
+		assert.ErrorIs(t, write(), ErrNilTx, name)
+	}
+
+	// not found stays nil, nil
+	found, err := conn.FindInscriptionByTick(chain, "asc-20", "unknown")
+	assert.Nil(t, err)
+	assert.Nil(t, found)
+
+	// the database errors keep their cause & get the chain
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = conn.SaveLastBlock(conn.SqlDB.WithContext(ctx), &model.BlockStatus{Chain: chain, BlockNumber: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "chain[avalanche]")
+	_, err = conn.SaveLastBlockMonotonic(conn.SqlDB.WithContext(ctx), &model.BlockStatus{Chain: chain, BlockNumber: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = conn.BatchAddTransaction(conn.SqlDB.WithContext(ctx), txs)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "insert 1 txs")
+	_, err = conn.BatchAddUTXO(conn.SqlDB.WithContext(ctx), utxos)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "chain[avalanche]")
+	err = conn.BatchUpsertBalances(conn.SqlDB.WithContext(ctx), chain, balances)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "chain[avalanche]")
+
+	assert.Nil(t, conn.SqlDB.Migrator().DropTable(&model.BlockStatus{}))
+	err = conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 1})
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrNilTx))
+	assert.False(t, errors.Is(err, ErrClientClosed))
+	assert.Contains(t, err.Error(), "chain[avalanche]")
+
+	// closed client
+	assert.Nil(t, conn.Close())
+	assert.ErrorIs(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 1}), ErrClientClosed)
+	_, err = conn.SaveLastBlockMonotonic(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 1})
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestAddressTxEvents(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick, address := "avalanche", "asc-20", "avav", "0x1"
+
+	events := []model.TxEvent{model.TransactionEventDeploy, model.TransactionEventMint, model.TransactionEventMint, model.TransactionEventTransfer}
+	for i, event := range events {
+		hash := fmt.Sprintf("0x%d", i)
+		txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash}}
+		addTransactions(t, conn, conn.SqlDB, txs)
+		addressTxs := []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: address, Event: event}}
+		assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, addressTxs))
+	}
+
+	invalid := []*model.AddressTxs{
+		{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0x8", Address: address, Event: model.TransactionEventMint},
+		{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0x9", Address: address, Event: 9},
+	}
+	assert.NotNil(t, conn.BatchAddAddressTx(conn.SqlDB, invalid))
+	assert.NotNil(t, conn.BatchAddAddressTx(conn.SqlDB, []*model.AddressTxs{{Chain: chain, TxHash: "0x10", Address: address}}))
+
+	expected := map[model.TxEvent]int64{
+		0:                              4,
+		model.TransactionEventDeploy:   1,
+		model.TransactionEventMint:     2,
+		model.TransactionEventTransfer: 1,
+		model.TransactionEventExchange: 0,
+	}
+	for event, cnt := range expected {
+		data, total, err := conn.GetTransactionsByAddress(10, 0, address, chain, protocol, tick, "", event, TxRangeFilter{}, TxSortNewest)
+		assert.Nil(t, err)
+		assert.Equal(t, cnt, total, "event %d", event)
+		assert.Equal(t, int(cnt), len(data), "event %d", event)
+		for _, item := range data {
+			if event != 0 {
+				assert.Equal(t, int8(event), item.Event)
+			}
+		}
+	}
+
+	_, _, err := conn.GetTransactionsByAddress(10, 0, address, chain, protocol, tick, "", 7, TxRangeFilter{}, TxSortNewest)
+	assert.NotNil(t, err)
+}
+
+func TestGetTransactionsByAddressSort(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick, address := "avalanche", "asc-20", "avav", "0x1"
+
+	// the equal amounts are ordered by id
+	amounts := []string{"5", "100.123456789012345678", "5", "20"}
+	for i, amount := range amounts {
+		hash := fmt.Sprintf("0x%d", i)
+		txs := []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Amount: decimal.RequireFromString(amount)}}
+		addTransactions(t, conn, conn.SqlDB, txs)
+		addressTxs := []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: address, Event: model.TransactionEventTransfer}}
+		assert.Nil(t, conn.BatchAddAddressTx(conn.SqlDB, addressTxs))
+	}
+
+	cases := []struct {
+		sort   TxSort
+		hashes []string
+	}{
+		{TxSortNewest, []string{"0x3", "0x2", "0x1", "0x0"}},
+		{TxSortOldest, []string{"0x0", "0x1", "0x2", "0x3"}},
+		{TxSortAmountDesc, []string{"0x1", "0x3", "0x2", "0x0"}},
+	}
+	for _, c := range cases {
+		data, total, err := conn.GetTransactionsByAddress(10, 0, address, chain, protocol, tick, "", 0, TxRangeFilter{}, c.sort)
+		assert.Nil(t, err, "sort %d", c.sort)
+		assert.Equal(t, int64(4), total, "sort %d", c.sort)
+		hashes := make([]string, 0, len(data))
+		for _, item := range data {
+			hashes = append(hashes, item.TxHash)
+		}
+		assert.Equal(t, c.hashes, hashes, "sort %d", c.sort)
+	}
+
+	page, _, err := conn.GetTransactionsByAddress(2, 2, address, chain, protocol, tick, "", 0, TxRangeFilter{}, TxSortAmountDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(page))
+	assert.Equal(t, "0x2", page[0].TxHash)
+
+	_, _, err = conn.GetTransactionsByAddress(10, 0, address, chain, protocol, tick, "", 0, TxRangeFilter{}, 3)
+	assert.NotNil(t, err)
+}
+
+func TestGetTransactionByPosition(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+	txs := []*model.Transaction{
+		{Chain: chain, TxHash: "0xa0", BlockHeight: 100, PositionInBlock: 0},
+		{Chain: chain, TxHash: "0xa1", BlockHeight: 100, PositionInBlock: 1},
+		{Chain: chain, TxHash: "0xa5", BlockHeight: 100, PositionInBlock: 5},
+		{Chain: chain, TxHash: "0xb1", BlockHeight: 101, PositionInBlock: 1},
+		{Chain: "bsc", TxHash: "0xc1", BlockHeight: 100, PositionInBlock: 1},
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+
+	for position, hash := range map[uint]string{0: "0xa0", 1: "0xa1", 5: "0xa5"} {
+		tx, err := conn.GetTransactionByPosition(chain, 100, position)
+		assert.Nil(t, err)
+		assert.Equal(t, hash, tx.TxHash)
+	}
+
+	tx, err := conn.GetTransactionByPosition(chain, 101, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "0xb1", tx.TxHash)
+
+	tx, err = conn.GetTransactionByPosition(chain, 100, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, tx)
+	tx, err = conn.GetTransactionByPosition(chain, 102, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, tx)
+}
+
+func TestGetTransactionsByBlock(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+	txs := []*model.Transaction{
+		{Chain: chain, TxHash: "0xa3", BlockHeight: 100, PositionInBlock: 3},
+		{Chain: chain, TxHash: "0xa0", BlockHeight: 100, PositionInBlock: 0},
+		{Chain: chain, TxHash: "0xa1", BlockHeight: 100, PositionInBlock: 1},
+		{Chain: chain, TxHash: "0xa1-2", BlockHeight: 100, PositionInBlock: 1}, // same position, ordered by id
+		{Chain: chain, TxHash: "0xb0", BlockHeight: 101, PositionInBlock: 0},
+		{Chain: "bsc", TxHash: "0xc0", BlockHeight: 100, PositionInBlock: 0},
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+
+	hashes := func(limit, offset int) []string {
+		data, total, err := conn.GetTransactionsByBlock(chain, 100, limit, offset)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), total)
+		hashes := make([]string, 0, len(data))
+		for _, tx := range data {
+			hashes = append(hashes, tx.TxHash)
+		}
+		return hashes
+	}
+	assert.Equal(t, []string{"0xa0", "0xa1", "0xa1-2", "0xa3"}, hashes(10, 0))
+	assert.Equal(t, []string{"0xa1", "0xa1-2"}, hashes(2, 1))
+	assert.Equal(t, []string{}, hashes(2, 4))
+
+	data, total, err := conn.GetTransactionsByBlock(chain, 102, 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.Equal(t, 0, len(data))
+}
+
+func TestGetTransfersBetween(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	txs := []*model.Transaction{
+		{TxHash: "0x1", BlockHeight: 1, From: "0xa", To: "0xb", Op: "transfer"},
+		{TxHash: "0x2", BlockHeight: 2, From: "0xb", To: "0xa", Op: "transfer"},
+		{TxHash: "0x3", BlockHeight: 3, From: "0xa", To: "0xb", Op: "transfer"},
+		{TxHash: "0x4", BlockHeight: 3, PositionInBlock: 1, From: "0xa", To: "0xc", Op: "transfer"},
+		{TxHash: "0x5", BlockHeight: 4, From: "0xa", To: "0xb", Op: "mint"},
+		{TxHash: "0x6", BlockHeight: 5, From: "0xa", To: "0xb", Op: "transfer", Tick: "other"},
+		{TxHash: "0x7", BlockHeight: 6, From: "0xa", To: "0xb", Op: "transfer", Chain: "btc"},
+	}
+	for _, tx := range txs {
+		if tx.Chain == "" {
+			tx.Chain = chain
+		}
+		if tx.Tick == "" {
+			tx.Tick = tick
+		}
+		tx.Protocol = protocol
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+
+	hashes := func(from, to string, limit, offset int) ([]string, int64) {
+		data, total, err := conn.GetTransfersBetween(chain, protocol, tick, from, to, limit, offset)
+		assert.Nil(t, err)
+		hashes := make([]string, 0, len(data))
+		for _, tx := range data {
+			hashes = append(hashes, tx.TxHash)
+		}
+		return hashes, total
+	}
+
+	found, total := hashes("0xa", "0xb", 10, 0)
+	assert.Equal(t, []string{"0x3", "0x1"}, found)
+	assert.Equal(t, int64(2), total)
+	found, total = hashes("0xb", "0xa", 10, 0)
+	assert.Equal(t, []string{"0x2"}, found)
+	assert.Equal(t, int64(1), total)
+
+	// an empty side matches any address
+	found, total = hashes("0xa", "", 10, 0)
+	assert.Equal(t, []string{"0x4", "0x3", "0x1"}, found)
+	assert.Equal(t, int64(3), total)
+	found, total = hashes("", "0xb", 10, 0)
+	assert.Equal(t, []string{"0x3", "0x1"}, found)
+	assert.Equal(t, int64(2), total)
+	found, total = hashes("", "", 2, 1)
+	assert.Equal(t, []string{"0x3", "0x2"}, found)
+	assert.Equal(t, int64(4), total)
+
+	found, total = hashes("0xc", "0xa", 10, 0)
+	assert.Equal(t, []string{}, found)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestFindTransactionsByHashes(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	// every other hash is stored, the stored ones span several chunks
+	hashes := make([]string, 0, 1000)
+	txs := make([]*model.Transaction, 0, 500)
+	for i := 0; i < 1000; i++ {
+		hash := fmt.Sprintf("0x%04d", i)
+		hashes = append(hashes, hash)
+		if i%2 == 0 {
+			txs = append(txs, &model.Transaction{Chain: chain, Protocol: "asc-20", TxHash: hash, BlockHeight: uint64(i)})
+		}
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+	addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: "bsc", TxHash: "0x0001"}})
+
+	// reversed with duplicates, the order of the input is irrelevant
+	query := make([]string, 0, len(hashes)+10)
+	for i := len(hashes) - 1; i >= 0; i-- {
+		query = append(query, hashes[i])
+	}
+	query = append(query, hashes[:10]...)
+
+	found, err := conn.FindTransactionsByHashes(chain, query)
+	assert.Nil(t, err)
+	assert.Equal(t, 500, len(found))
+	for i, hash := range hashes {
+		item, ok := found[hash]
+		assert.Equal(t, i%2 == 0, ok, hash)
+		if ok {
+			assert.Equal(t, uint64(i), item.BlockHeight)
+		}
+	}
+
+	found, err = conn.FindTransactionsByHashes(chain, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(found))
+}
+
+func TestGetAddressBalancesAllChains(t *testing.T) {
+	conn := newTestClient(t)
+	protocol, tick, address := "asc-20", "tick", "0xa"
+	amount := decimal.RequireFromString
+
+	balances := []*model.Balances{
+		{SID: 1, Chain: "avalanche", Protocol: protocol, Tick: tick, Address: address, Balance: amount("0.1")},
+		{SID: 2, Chain: "bsc", Protocol: protocol, Tick: tick, Address: address, Balance: amount("0.2")},
+		// another address & another tick of the address are left out
+		{SID: 3, Chain: "bsc", Protocol: protocol, Tick: tick, Address: "0xb", Balance: amount("5")},
+		{SID: 4, Chain: "avalanche", Protocol: protocol, Tick: "other", Address: address, Balance: amount("7")},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	items, total, err := conn.GetAddressBalancesAllChains(protocol, tick, address)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, "avalanche", items[0].Chain)
+	assert.True(t, amount("0.1").Equal(items[0].Balance), items[0].Balance.String())
+	assert.Equal(t, "bsc", items[1].Chain)
+	assert.True(t, amount("0.2").Equal(items[1].Balance), items[1].Balance.String())
+	// a float sum would be 0.30000000000000004
+	assert.True(t, amount("0.3").Equal(total), total.String())
+
+	items, total, err = conn.GetAddressBalancesAllChains(protocol, tick, "0xunknown")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+	assert.True(t, total.IsZero())
+}
+
+func TestGetBalancesByAddresses(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+
+	// every other address holds the tick, the holders span several chunks
+	addresses := make([]string, 0, 2000)
+	balances := make([]*model.Balances, 0, 1000)
+	for i := 0; i < 2000; i++ {
+		address := fmt.Sprintf("0x%04d", i)
+		addresses = append(addresses, address)
+		if i%2 == 0 {
+			balances = append(balances, &model.Balances{SID: uint64(i + 1), Chain: chain, Protocol: protocol, Tick: tick, Address: address,
+				Balance: decimal.NewFromInt(int64(i)), Available: decimal.NewFromInt(int64(i))})
+		}
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	others := []*model.Balances{
+		{SID: 5001, Chain: chain, Protocol: protocol, Tick: "other", Address: "0x0001", Balance: decimal.NewFromInt(1)},
+		{SID: 5002, Chain: "bsc", Protocol: protocol, Tick: tick, Address: "0x0003", Balance: decimal.NewFromInt(1)},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, others))
+
+	// the duplicates are queried once, 2000 addresses are 4 chunks
+	queries := countQueries(t, conn)
+	found, err := conn.GetBalancesByAddresses(chain, protocol, tick, append(addresses, addresses[:10]...))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), queries.Load())
+	assert.Equal(t, 1000, len(found))
+	for i, address := range addresses {
+		balance, ok := found[address]
+		assert.Equal(t, i%2 == 0, ok, address)
+		if ok {
+			assert.Equal(t, address, balance.Address)
+			assert.True(t, decimal.NewFromInt(int64(i)).Equal(balance.Balance), address)
+		}
+	}
+
+	found, err = conn.GetBalancesByAddresses(chain, protocol, tick, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(found))
+}
+
+func TestGetInscriptionsStatsBySIDs(t *testing.T) {
+	conn := newTestClient(t)
+	chain := "avalanche"
+
+	// every third sid is stored, the stored ones span several chunks
+	sids := make([]uint32, 0, 1500)
+	stats := make([]*model.InscriptionsStats, 0, 500)
+	for i := 1; i <= 1500; i++ {
+		sids = append(sids, uint32(i))
+		if i%3 == 0 {
+			stats = append(stats, &model.InscriptionsStats{SID: uint32(i), Chain: chain, Protocol: "asc-20", Tick: fmt.Sprintf("t%d", i),
+				Holders: uint64(i)})
+		}
+	}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	// the same sid on another chain is not returned
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, []*model.InscriptionsStats{{SID: 1, Chain: "bsc", Protocol: "asc-20", Tick: "t1"}}))
+
+	found, err := conn.GetInscriptionsStatsBySIDs(chain, append(sids, sids[:10]...))
+	assert.Nil(t, err)
+	assert.Equal(t, 500, len(found))
+	for _, sid := range sids {
+		item, ok := found[sid]
+		assert.Equal(t, sid%3 == 0, ok, sid)
+		if ok {
+			assert.Equal(t, uint64(sid), item.Holders)
+		}
+	}
+
+	found, err = conn.GetInscriptionsStatsBySIDs(chain, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(found))
+}
+
+func TestDecimalAmountsRoundTrip(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "a"
+
+	balances := []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0x1",
+		Available: decimal.RequireFromString("100.0"), Balance: decimal.RequireFromString("100.000")}}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: decimal.RequireFromString("0.1")}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	// arithmetic on the model amounts, the equal values compare equal whatever their textual form
+	balance, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+	assert.True(t, balance.Balance.Equal(decimal.NewFromInt(100)))
+	balance.Balance = balance.Balance.Sub(decimal.RequireFromString("40.25"))
+	balance.Available = balance.Available.Add(decimal.RequireFromString("0.75"))
+	assert.Nil(t, conn.BatchUpdateBalances(conn.SqlDB, chain, []*model.Balances{balance}))
+
+	balance, err = conn.FindUserBalanceByTick(chain, protocol, tick, "0x1")
+	assert.Nil(t, err)
+	assert.Equal(t, "59.75", balance.Balance.String())
+	assert.Equal(t, "100.75", balance.Available.String())
+
+	// the raw batch update binds the decimals, no formatting through the sql text
+	stats[0].Minted = stats[0].Minted.Add(decimal.RequireFromString("0.2"))
+	assert.Nil(t, conn.BatchUpdateInscriptionStats(conn.SqlDB, chain, stats))
+	stored, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.True(t, stored.Minted.Equal(decimal.RequireFromString("0.3")), stored.Minted.String())
+}
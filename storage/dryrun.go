@@ -0,0 +1,99 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"gorm.io/gorm"
+)
+
+// DryRunStatement a statement rendered by a DryRun session, Vars are the bound values of the placeholders of SQL
+type DryRunStatement struct {
+	SQL  string
+	Vars []interface{}
+}
+
+// DryRunRecorder collects the statements rendered by a DryRun session in execution order
+type DryRunRecorder struct {
+	mu         sync.Mutex
+	statements []DryRunStatement
+}
+
+// Statements returns the statements rendered so far
+func (r *DryRunRecorder) Statements() []DryRunStatement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DryRunStatement(nil), r.statements...)
+}
+
+func (r *DryRunRecorder) add(stmt DryRunStatement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, stmt)
+}
+
+type dryRunKey struct{}
+
+// DryRun returns a session to pass as dbTx to the Batch* writers, the statements are rendered, logged at debug level and
+// recorded instead of being run, e.g. to check the CASE statement of BatchUpdatesBySID. No row is changed and every
+// statement reports zero affected rows, so BatchAddInscription returns all the deploys as skipped.
+func (conn *DBClient) DryRun() (*gorm.DB, *DryRunRecorder) {
+	recorder := &DryRunRecorder{}
+	ctx := context.WithValue(context.Background(), dryRunKey{}, recorder)
+	return conn.SqlDB.Session(&gorm.Session{DryRun: true, NewDB: true, Context: ctx}), recorder
+}
+
+// recordDryRun registers the callbacks recording the statements of the DryRun sessions
+func recordDryRun(db *gorm.DB) error {
+	record := func(db *gorm.DB) {
+		if !db.DryRun || db.Statement.SQL.Len() < 1 {
+			return
+		}
+		recorder, ok := db.Statement.Context.Value(dryRunKey{}).(*DryRunRecorder)
+		if !ok {
+			return
+		}
+
+		stmt := DryRunStatement{SQL: db.Statement.SQL.String(), Vars: append([]interface{}(nil), db.Statement.Vars...)}
+		recorder.add(stmt)
+		log.Debug("dry run statement", "sql", db.Dialector.Explain(stmt.SQL, stmt.Vars...))
+	}
+
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().After("*").Register("storage:dry_run", record),
+		callback.Query().After("*").Register("storage:dry_run", record),
+		callback.Update().After("*").Register("storage:dry_run", record),
+		callback.Delete().After("*").Register("storage:dry_run", record),
+		callback.Row().After("*").Register("storage:dry_run", record),
+		callback.Raw().After("*").Register("storage:dry_run", record),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
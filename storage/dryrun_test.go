@@ -0,0 +1,85 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestDryRun(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+	stats := []*model.InscriptionsStats{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Holders: 1, TxCnt: 1},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: "b", Holders: 2, TxCnt: 2},
+	}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	balances := []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: "a", Address: "0xa", Balance: decimal.NewFromInt(1)}}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	dryRun, recorder := conn.DryRun()
+	updates := []*model.InscriptionsStats{
+		{SID: 1, Chain: chain, Minted: decimal.NewFromInt(10), Holders: 5, TxCnt: 6},
+		{SID: 2, Chain: chain, Minted: decimal.NewFromInt(20), Holders: 7, TxCnt: 8},
+	}
+	assert.Nil(t, conn.BatchUpdateInscriptionStats(dryRun, chain, updates))
+	_, err := conn.BatchAddTransaction(dryRun, []*model.Transaction{{Chain: chain, TxHash: "0x1"}})
+	assert.Nil(t, err)
+	balances[0].Balance = decimal.NewFromInt(2)
+	assert.Nil(t, conn.BatchUpdateBalances(dryRun, chain, balances))
+	assert.Equal(t, uint(0), balances[0].Version)
+
+	statements := recorder.Statements()
+	assert.Equal(t, 3, len(statements))
+	assert.Equal(t, "UPDATE `inscriptions_stats` SET  `holders` = CASE sid WHEN ? THEN ? WHEN ? THEN ? ELSE `holders` END,"+
+		" `minted` = CASE sid WHEN ? THEN ? WHEN ? THEN ? ELSE `minted` END,"+
+		" `tx_cnt` = CASE sid WHEN ? THEN ? WHEN ? THEN ? ELSE `tx_cnt` END,"+
+		" `updated_at` = ? WHERE chain = ? AND sid IN (?,?)", statements[0].SQL)
+	assert.Equal(t, []interface{}{uint32(1), uint64(5), uint32(2), uint64(7)}, statements[0].Vars[:4])
+	assert.Equal(t, []interface{}{chain, uint32(1), uint32(2)}, statements[0].Vars[len(statements[0].Vars)-3:])
+	assert.True(t, strings.HasPrefix(statements[1].SQL, "INSERT INTO `txs`"), statements[1].SQL)
+	assert.True(t, strings.Contains(statements[2].SQL, "AND `version` = CASE sid WHEN ? THEN ? ELSE `version` END"), statements[2].SQL)
+
+	// nothing was written
+	for i, tick := range []string{"a", "b"} {
+		saved, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+		assert.Nil(t, err)
+		assert.Equal(t, stats[i].Holders, saved.Holders)
+		assert.Equal(t, stats[i].TxCnt, saved.TxCnt)
+	}
+	tx, err := conn.FindTransaction(chain, "0x1")
+	assert.Nil(t, err)
+	assert.Nil(t, tx)
+	balance, err := conn.FindUserBalanceByTick(chain, protocol, "a", "0xa")
+	assert.Nil(t, err)
+	assert.True(t, balance.Balance.Equal(decimal.NewFromInt(1)))
+	assert.Equal(t, uint(0), balance.Version)
+
+	// the statements of the client itself are not recorded
+	assert.Equal(t, 3, len(recorder.Statements()))
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+)
+
+// HealthStatus the connection pool stats and the query check of the database, json encodable for a readiness probe
+type HealthStatus struct {
+	Healthy         bool   `json:"healthy"`
+	OpenConnections int    `json:"open_connections"`
+	InUse           int    `json:"in_use"`
+	Idle            int    `json:"idle"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Ping verifies the connection to the database is alive
+func (conn *DBClient) Ping(ctx context.Context) error {
+	if conn.isClosed() {
+		return ErrClientClosed
+	}
+
+	sqlDB, err := conn.SqlDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// HealthCheck returns the pool stats of the database and the result of a trivial query,
+// the error is the one of the query and is also set in the status.
+func (conn *DBClient) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	if conn.isClosed() {
+		return &HealthStatus{Error: ErrClientClosed.Error()}, ErrClientClosed
+	}
+
+	sqlDB, err := conn.SqlDB.DB()
+	if err != nil {
+		return &HealthStatus{Error: err.Error()}, err
+	}
+
+	stats := sqlDB.Stats()
+	status := &HealthStatus{
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+	}
+
+	var one int
+	if err = sqlDB.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		status.Error = err.Error()
+		return status, err
+	}
+	status.Healthy = true
+	return status, nil
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// labelCategories the valid categories of the address labels
+var labelCategories = map[string]struct{}{
+	model.LabelCategoryExchange: {},
+	model.LabelCategoryBurn:     {},
+	model.LabelCategoryDeployer: {},
+}
+
+// labeledBalance a balance with the label of its address read by the join of addressLabelsJoin
+type labeledBalance struct {
+	model.Balances
+	Label string `gorm:"column:label"`
+}
+
+// addressLabelsJoin the left join of the labels of the addresses of the balances alias, the label is NULL for the
+// addresses without one
+func (conn *DBClient) addressLabelsJoin(alias string) string {
+	return "left join " + conn.table(model.AddressLabel{}) + " as l on (l.chain = " + alias + ".chain and l.address = " + alias + ".address)"
+}
+
+// LabelAddress sets the label of the address of the chain, the label and the category of an already labeled address
+// are replaced. The holders listings return the label along with the balances.
+func (conn *DBClient) LabelAddress(dbTx *gorm.DB, label *model.AddressLabel) (err error) {
+	defer conn.observe("LabelAddress", time.Now(), &err)
+
+	if dbTx == nil {
+		return ErrNilTx
+	}
+	if label == nil || label.Chain == "" || label.Address == "" {
+		return errors.New("invalid address label")
+	}
+	if _, ok := labelCategories[label.Category]; !ok {
+		return fmt.Errorf("invalid label category[%s]", label.Category)
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"label", "category", "updated_at"}),
+	}
+	if err = dbTx.Clauses(dbresolver.Write, onConflict).Create(label).Error; err != nil {
+		return fmt.Errorf("label address[%s] of chain[%s] failed: %w", label.Address, label.Chain, err)
+	}
+	return nil
+}
+
+// GetLabels returns the labels of the addresses of the chain keyed by address, all the labels of the chain when
+// addresses is empty. The addresses are queried in chunks of findByHashesChunkSize, the unlabeled ones are absent.
+func (conn *DBClient) GetLabels(chain string, addresses []string) (map[string]*model.AddressLabel, error) {
+	ctx, cancel := conn.queryContext(queryFast)
+	defer cancel()
+	return conn.GetLabelsContext(ctx, chain, addresses)
+}
+
+// GetLabelsContext is the context aware variant of GetLabels.
+func (conn *DBClient) GetLabelsContext(ctx context.Context, chain string, addresses []string) (map[string]*model.AddressLabel, error) {
+	var items []*model.AddressLabel
+	if len(addresses) == 0 {
+		if err := conn.SqlDB.WithContext(ctx).Where("chain = ?", chain).Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+	for start := 0; start < len(addresses); start += findByHashesChunkSize {
+		end := start + findByHashesChunkSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		var chunk []*model.AddressLabel
+		err := unprepared(conn.SqlDB.WithContext(ctx)).Where("chain = ? AND address IN ?", chain, addresses[start:end]).Find(&chunk).Error
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, chunk...)
+	}
+
+	labels := make(map[string]*model.AddressLabel, len(items))
+	for _, item := range items {
+		labels[item.Address] = item
+	}
+	return labels, nil
+}
+
+// GetCirculatingSupply the sum of the balances of the tick held by the addresses other than the exclude addresses
+// and the addresses labeled as burn addresses, "0" for an unknown tick. The balances are summed as exact decimals on
+// mysql and postgres, sqlite sums them as they are stored.
+func (conn *DBClient) GetCirculatingSupply(chain, protocol, tick string, excludeAddresses []string) (string, error) {
+	ctx, cancel := conn.queryContext(queryAnalytical)
+	defer cancel()
+	return conn.GetCirculatingSupplyContext(ctx, chain, protocol, tick, excludeAddresses)
+}
+
+// GetCirculatingSupplyContext is the context aware variant of GetCirculatingSupply.
+func (conn *DBClient) GetCirculatingSupplyContext(ctx context.Context, chain, protocol, tick string, excludeAddresses []string) (
+	string, error) {
+	supply, err := conn.circulatingSupply(ctx, chain, protocol, tick, excludeAddresses)
+	if err != nil {
+		return "", err
+	}
+	return supply.String(), nil
+}
+
+// circulatingSupply is GetCirculatingSupplyContext returning the decimal
+func (conn *DBClient) circulatingSupply(ctx context.Context, chain, protocol, tick string, excludeAddresses []string) (
+	decimal.Decimal, error) {
+	ret := &struct {
+		Supply decimal.Decimal `gorm:"column:supply"`
+	}{}
+	query := conn.SqlDB.WithContext(ctx).Table(conn.table(model.Balances{})+" as b").
+		Select("COALESCE("+conn.decimalSum("b.balance")+", 0) as supply").
+		Joins(conn.addressLabelsJoin("b")).
+		Where("b.chain = ? and b.protocol = ? and b.tick = ?", chain, protocol, tick).
+		Where("(l.category IS NULL OR l.category <> ?)", model.LabelCategoryBurn)
+	if len(excludeAddresses) > 0 {
+		query = unprepared(query).Where("b.address NOT IN ?", excludeAddresses)
+	}
+	if err := query.Take(ret).Error; err != nil {
+		return decimal.Zero, err
+	}
+	return ret.Supply, nil
+}
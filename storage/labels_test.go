@@ -0,0 +1,138 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestAddressLabels(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.RequireFromString
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount("1000")}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("1000"), Holders: 3}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xexchange", Balance: amount("600"), Available: amount("600")},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xdead", Balance: amount("300.5"), Available: amount("300.5")},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xuser", Balance: amount("99.5"), Available: amount("99.5")},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+
+	labels := []*model.AddressLabel{
+		{Chain: chain, Address: "0xexchange", Label: "exchange", Category: model.LabelCategoryExchange},
+		{Chain: chain, Address: "0xdead", Label: "burn", Category: model.LabelCategoryBurn},
+		{Chain: "btc", Address: "0xuser", Label: "btc exchange", Category: model.LabelCategoryExchange},
+	}
+	for _, label := range labels {
+		assert.Nil(t, conn.LabelAddress(conn.SqlDB, label))
+	}
+	// labeling again replaces the label
+	assert.Nil(t, conn.LabelAddress(conn.SqlDB, &model.AddressLabel{Chain: chain, Address: "0xexchange", Label: "hot wallet",
+		Category: model.LabelCategoryExchange}))
+	assert.NotNil(t, conn.LabelAddress(conn.SqlDB, &model.AddressLabel{Chain: chain, Address: "0xuser", Label: "user", Category: "vip"}))
+	assert.NotNil(t, conn.LabelAddress(conn.SqlDB, &model.AddressLabel{Chain: chain, Label: "none", Category: model.LabelCategoryBurn}))
+
+	found, err := conn.GetLabels(chain, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(found))
+	assert.Equal(t, "hot wallet", found["0xexchange"].Label)
+	assert.Equal(t, model.LabelCategoryBurn, found["0xdead"].Category)
+	found, err = conn.GetLabels(chain, []string{"0xdead", "0xuser"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, "burn", found["0xdead"].Label)
+
+	// the holders carry the label of their address, the label of the other chain is not joined
+	holders, total, err := conn.GetHoldersByTick(10, 0, chain, protocol, tick, "", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, 3, len(holders))
+	for i, expected := range []struct{ address, label, balance string }{
+		{"0xexchange", "hot wallet", "600"}, {"0xdead", "burn", "300.5"}, {"0xuser", "", "99.5"},
+	} {
+		assert.Equal(t, expected.address, holders[i].Address)
+		assert.Equal(t, expected.label, holders[i].Label)
+		assert.True(t, amount(expected.balance).Equal(holders[i].Balance), holders[i].Balance.String())
+		assert.Equal(t, uint64(i+1), holders[i].SID)
+	}
+
+	holdings, err := conn.GetRichList(chain, protocol, 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(holdings))
+	assert.Equal(t, "hot wallet", holdings[0].Label)
+	assert.Equal(t, "burn", holdings[1].Label)
+	assert.Equal(t, "", holdings[2].Label)
+}
+
+func TestGetCirculatingSupply(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.RequireFromString
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount("1000")}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("1000"), Holders: 4}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+	balances := []*model.Balances{
+		{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xdead", Balance: amount("300.5"), Available: amount("300.5")},
+		{SID: 2, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xtreasury", Balance: amount("200"), Available: amount("200")},
+		{SID: 3, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount("399.25"), Available: amount("399.25")},
+		{SID: 4, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xb", Balance: amount("100.25"), Available: amount("100.25")},
+	}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	assert.Nil(t, conn.LabelAddress(conn.SqlDB, &model.AddressLabel{Chain: chain, Address: "0xdead", Label: "burn",
+		Category: model.LabelCategoryBurn}))
+	// the burn label of the other chain does not apply
+	assert.Nil(t, conn.LabelAddress(conn.SqlDB, &model.AddressLabel{Chain: "btc", Address: "0xa", Label: "burn",
+		Category: model.LabelCategoryBurn}))
+
+	for _, c := range []struct {
+		exclude  []string
+		expected string
+	}{
+		{nil, "699.5"},
+		{[]string{"0xtreasury"}, "499.5"},
+		{[]string{"0xtreasury", "0xdead", "0xb"}, "399.25"},
+	} {
+		supply, err := conn.GetCirculatingSupply(chain, protocol, tick, c.exclude)
+		assert.Nil(t, err)
+		assert.True(t, amount(c.expected).Equal(amount(supply)), "exclude:%v supply:%s", c.exclude, supply)
+	}
+
+	supply, err := conn.GetCirculatingSupply(chain, protocol, "none", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", supply)
+
+	market, err := conn.GetTickMarketStats(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.True(t, amount("1000").Equal(market.Minted))
+	assert.True(t, amount("699.5").Equal(market.CirculatingSupply), market.CirculatingSupply.String())
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrOptimizeRunning is returned by Optimize while another Optimize of the client (or of its Primary copies) runs
+var ErrOptimizeRunning = errors.New("optimize already running")
+
+// Optimize reclaims the free space and refreshes the planner statistics of the indexer tables: VACUUM & ANALYZE of the
+// whole database on sqlite, OPTIMIZE TABLE & ANALYZE TABLE on mysql and VACUUM ANALYZE on postgres. The statements
+// are issued one by one on the primary, a canceled ctx interrupts the running one and skips the rest.
+// VACUUM locks the sqlite database and OPTIMIZE TABLE rebuilds the innodb table, run it outside of the busy hours.
+func (conn *DBClient) Optimize(ctx context.Context) (err error) {
+	defer conn.observe("Optimize", time.Now(), &err)
+
+	if conn.isClosed() {
+		return ErrClientClosed
+	}
+	if !conn.optimizing.CompareAndSwap(false, true) {
+		return ErrOptimizeRunning
+	}
+	defer conn.optimizing.Store(false)
+
+	var statements []string
+	dbType := conn.SqlDB.Dialector.Name()
+	switch dbType {
+	case "sqlite":
+		statements = []string{"VACUUM", "ANALYZE"}
+	case DatabaseTypeMysql, DatabaseTypePostgres:
+		for _, m := range migrateModels() {
+			table := conn.quote(conn.table(m.(schema.Tabler)))
+			if dbType == DatabaseTypeMysql {
+				statements = append(statements, "OPTIMIZE TABLE "+table, "ANALYZE TABLE "+table)
+			} else {
+				statements = append(statements, "VACUUM ANALYZE "+table)
+			}
+		}
+	default:
+		return fmt.Errorf("optimize of database type[%s] not supported", dbType)
+	}
+
+	db := conn.SqlDB.WithContext(ctx).Clauses(dbresolver.Write)
+	for _, statement := range statements {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err = db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("%s failed: %w", statement, err)
+		}
+		log.Info("optimize statement done", "statement", statement, "duration", time.Since(start))
+	}
+	return nil
+}
+
+// PruneSpentUTXOs deletes the spent utxos of the chain created by the txs below the block height and returns the
+// number of rows deleted. The rows go in batches of limit rows (the insert batch size when limit <= 0), every batch is
+// a statement of its own on the primary so the utxos table is never locked for long. The DELETE checks the status
+// again, an unspent utxo is never deleted.
+// The utxos have no height of their own, the height of the creating tx is used. DeleteDataAboveBlock does not restore
+// the spent utxos anyway, a prune below the reorg depth loses nothing a rollback needs.
+func (conn *DBClient) PruneSpentUTXOs(chain string, olderThanBlock uint64, limit int) (deleted int64, err error) {
+	defer conn.observe("PruneSpentUTXOs", time.Now(), &err)
+
+	if conn.isClosed() {
+		return 0, ErrClientClosed
+	}
+	if limit <= 0 {
+		limit = conn.insertBatchSize()
+	}
+
+	db := conn.SqlDB.Clauses(dbresolver.Write).Session(&gorm.Session{})
+	// mysql rejects a LIMIT in an IN subquery of a DELETE, the ids of the batch are read first
+	created := "tx_hash IN (SELECT tx_hash FROM " + conn.table(model.Transaction{}) + " WHERE chain = ? AND block_height < ?)"
+	for {
+		var ids []uint64
+		err = db.Model(&model.UTXO{}).Where("chain = ? AND status = ?", chain, model.UTXOStatusSpent).
+			Where(created, chain, olderThanBlock).Order("id").Limit(limit).Pluck("id", &ids).Error
+		if err != nil {
+			return deleted, err
+		}
+		if len(ids) < 1 {
+			return deleted, nil
+		}
+
+		ret := unprepared(db).Where("id IN ? AND status = ?", ids, model.UTXOStatusSpent).Delete(&model.UTXO{})
+		if ret.Error != nil {
+			return deleted, ret.Error
+		}
+		deleted += ret.RowsAffected
+		if len(ids) < limit {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestOptimize(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	txs := make([]*model.Transaction, 0, 500)
+	for i := 0; i < 500; i++ {
+		txs = append(txs, &model.Transaction{Chain: chain, Protocol: protocol, Tick: "tick", TxHash: fmt.Sprintf("0x%064d", i),
+			Amount: decimal.NewFromInt(int64(i)), Op: "mint"})
+	}
+	addTransactions(t, conn, conn.SqlDB, txs)
+	assert.Nil(t, conn.SqlDB.Where("chain = ?", chain).Delete(&model.Transaction{}).Error)
+
+	var free int64
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA freelist_count").Scan(&free).Error)
+	assert.Greater(t, free, int64(0))
+
+	assert.Nil(t, conn.Optimize(context.Background()))
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA freelist_count").Scan(&free).Error)
+	assert.Equal(t, int64(0), free)
+
+	// a single run at a time, the Primary copies included
+	conn.optimizing.Store(true)
+	assert.ErrorIs(t, conn.Primary().Optimize(context.Background()), ErrOptimizeRunning)
+	conn.optimizing.Store(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, conn.Optimize(ctx), context.Canceled)
+	assert.Nil(t, conn.Optimize(context.Background()))
+}
+
+func TestPruneSpentUTXOs(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	txs := make([]*model.Transaction, 0)
+	utxos := make([]*model.UTXO, 0)
+	for block := uint64(1); block <= 10; block++ {
+		hash := fmt.Sprintf("0x%d", block)
+		txs = append(txs, &model.Transaction{Chain: chain, Protocol: protocol, Tick: "tick", TxHash: hash, BlockHeight: block, Op: "transfer"})
+		for vout, status := range []int{model.UTXOStatusSpent, model.UTXOStatusUnspent, model.UTXOStatusSpent} {
+			utxos = append(utxos, &model.UTXO{Sn: fmt.Sprintf("%s:%d", hash, vout), Chain: chain, Protocol: protocol, Tick: "tick",
+				Address: "0xa", Amount: decimal.NewFromInt(1), RootHash: hash, TxHash: hash, Vout: uint32(vout), Status: int8(status)})
+		}
+	}
+	// the same height on another chain stays untouched
+	txs = append(txs, &model.Transaction{Chain: "btc", TxHash: "0xbtc", BlockHeight: 1})
+	utxos = append(utxos, &model.UTXO{Chain: "btc", TxHash: "0xbtc", Status: model.UTXOStatusSpent})
+	addTransactions(t, conn, conn.SqlDB, txs)
+	assert.Nil(t, conn.SqlDB.Create(utxos).Error)
+
+	count := func(chain string, status int) int64 {
+		var cnt int64
+		assert.Nil(t, conn.SqlDB.Model(&model.UTXO{}).Where("chain = ? AND status = ?", chain, status).Count(&cnt).Error)
+		return cnt
+	}
+
+	// the 12 spent utxos of the blocks 1 to 6 in batches of 5
+	deleted, err := conn.PruneSpentUTXOs(chain, 7, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), deleted)
+	assert.Equal(t, int64(8), count(chain, model.UTXOStatusSpent))
+	assert.Equal(t, int64(10), count(chain, model.UTXOStatusUnspent))
+	assert.Equal(t, int64(1), count("btc", model.UTXOStatusSpent))
+
+	var remaining []string
+	assert.Nil(t, conn.SqlDB.Model(&model.UTXO{}).Where("chain = ? AND status = ?", chain, model.UTXOStatusSpent).
+		Order("id").Limit(1).Pluck("tx_hash", &remaining).Error)
+	assert.Equal(t, []string{"0x7"}, remaining)
+
+	// nothing left below the height, the default batch size prunes the rest
+	deleted, err = conn.PruneSpentUTXOs(chain, 7, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), deleted)
+	deleted, err = conn.PruneSpentUTXOs(chain, 100, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), deleted)
+	assert.Equal(t, int64(0), count(chain, model.UTXOStatusSpent))
+	assert.Equal(t, int64(10), count(chain, model.UTXOStatusUnspent))
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "indexer",
+		Subsystem: "storage",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of the storage queries in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "db_type"})
+
+	queryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Subsystem: "storage",
+		Name:      "query_errors_total",
+		Help:      "Number of the failed storage queries.",
+	}, []string{"method", "db_type"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "indexer",
+		Subsystem: "storage",
+		Name:      "cache_lookups_total",
+		Help:      "Number of the CachedDBClient lookups by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	statementBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "indexer",
+		Subsystem: "storage",
+		Name:      "batch_update_statement_bytes",
+		Help:      "Size of the executed BatchUpdatesBySID statements in bytes by table.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 9),
+	}, []string{"table"})
+)
+
+// RegisterMetrics registers the storage query metrics, they are only recorded by clients with enable_metrics set
+func RegisterMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{queryDuration, queryErrors, cacheLookups, statementBytes} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe records the duration and the error of the method call, meant to be deferred with a named error result
+func (conn *DBClient) observe(method string, start time.Time, err *error) {
+	if !conn.metricsEnabled {
+		return
+	}
+
+	dbType := conn.SqlDB.Dialector.Name()
+	queryDuration.WithLabelValues(method, dbType).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		queryErrors.WithLabelValues(method, dbType).Inc()
+	}
+}
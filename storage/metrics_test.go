@@ -0,0 +1,85 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	assert.Nil(t, RegisterMetrics(reg))
+
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:          DatabaseTypeSqlite3,
+		Dsn:           filepath.Join(t.TempDir(), "indexer.db"),
+		EnableMetrics: true,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.SqlDB.AutoMigrate(&model.Inscriptions{}, &model.InscriptionsStats{}))
+
+	_, _, err = conn.GetInscriptions(10, 0, InscriptionFilter{Chain: "avalanche"}, SortById, OrderByModeDesc)
+	assert.Nil(t, err)
+
+	// the histogram of the method & db type got a sample
+	families, err := reg.Gather()
+	assert.Nil(t, err)
+	var samples uint64
+	for _, family := range families {
+		if family.GetName() != "indexer_storage_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == "GetInscriptions" && labels["db_type"] == "sqlite" {
+				samples += metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	assert.Equal(t, uint64(1), samples)
+
+	// a failing write counts as an error, the txs table is not migrated
+	errCnt := testutil.ToFloat64(queryErrors.WithLabelValues("BatchAddTransaction", "sqlite"))
+	_, err = conn.BatchAddTransaction(conn.SqlDB, []*model.Transaction{{Chain: "avalanche"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, errCnt+1, testutil.ToFloat64(queryErrors.WithLabelValues("BatchAddTransaction", "sqlite")))
+}
+
+func TestQueryMetricsDisabled(t *testing.T) {
+	conn := newTestClient(t)
+	before := testutil.ToFloat64(queryErrors.WithLabelValues("BatchAddTransaction", "sqlite"))
+	assert.Nil(t, conn.SqlDB.Migrator().DropTable(&model.Transaction{}))
+	_, err := conn.BatchAddTransaction(conn.SqlDB, []*model.Transaction{{Chain: "avalanche"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, before, testutil.ToFloat64(queryErrors.WithLabelValues("BatchAddTransaction", "sqlite")))
+}
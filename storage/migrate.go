@@ -0,0 +1,171 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
+)
+
+// schemaVersion the version of the schema created by AutoMigrateAll, the number of the latest db/migrations file
+const schemaVersion uint32 = 13
+
+// mysqlTableOptions matches the table options of db/init_mysql.sql
+const mysqlTableOptions = "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci"
+
+// migrateModels the models managed by AutoMigrateAll
+func migrateModels() []interface{} {
+	return []interface{}{&model.Inscriptions{}, &model.InscriptionsStats{}, &model.Balances{}, &model.Transaction{},
+		&model.BalanceTxn{}, &model.AddressTxs{}, &model.UTXO{}, &model.BlockStatus{}, &model.AddressLabel{}}
+}
+
+// migrateStep the data change of a db/migrations file the AutoMigrate of the models can't express. It runs when the
+// database is below the version, before the AutoMigrate of the models or after it. The steps must be idempotent, a
+// database created without a schema_version row runs all of them.
+type migrateStep struct {
+	version uint32
+	before  bool
+	name    string
+	run     func(conn *DBClient, db *gorm.DB) error
+}
+
+// migrateSteps the steps in the order of their version
+var migrateSteps = []migrateStep{
+	{version: 8, before: true, name: "add utxos vout", run: addUTXOVout},
+	{version: 11, before: true, name: "dedup txs", run: dedupTransactions},
+	{version: 13, name: "backfill balance_txn block_number", run: backfillBalanceTxBlockNumber},
+}
+
+// addUTXOVout adds the vout of migration 008 to an existing utxos table before AutoMigrate creates the unique key of
+// the outpoint, the rows are numbered in the order of their id within their tx. The numbers come from a grouped
+// derived table, mysql materializes it and so allows it on the updated table.
+func addUTXOVout(conn *DBClient, db *gorm.DB) error {
+	utxos := conn.table(model.UTXO{})
+	migrator := db.Table(utxos).Migrator()
+	if !migrator.HasTable(utxos) || migrator.HasColumn(&model.UTXO{}, "Vout") {
+		return nil
+	}
+	if err := migrator.AddColumn(&model.UTXO{}, "Vout"); err != nil {
+		return err
+	}
+
+	numbers := "SELECT a.id, COUNT(*) AS vout FROM " + utxos + " a JOIN " + utxos + " b ON b.chain = a.chain AND " +
+		"b.tx_hash = a.tx_hash AND b.id < a.id GROUP BY a.id"
+	return db.Exec("UPDATE " + utxos + " SET vout = COALESCE((SELECT n.vout FROM (" + numbers + ") n WHERE n.id = " +
+		utxos + ".id), 0)").Error
+}
+
+// dedupTransactions removes the txs stored twice by a reprocessed block before AutoMigrate creates the unique key of
+// migration 011, the row with the lowest id is kept. Like in addUTXOVout the kept ids come from a grouped derived
+// table.
+func dedupTransactions(conn *DBClient, db *gorm.DB) error {
+	txs := conn.table(model.Transaction{})
+	migrator := db.Table(txs).Migrator()
+	if !migrator.HasTable(txs) || migrator.HasIndex(&model.Transaction{}, "uq_txs_chain_tx_hash") {
+		return nil
+	}
+	return db.Exec("DELETE FROM " + txs + " WHERE id NOT IN (SELECT k.id FROM (SELECT MIN(id) AS id FROM " + txs +
+		" GROUP BY chain, tx_hash) k)").Error
+}
+
+// backfillBalanceTxBlockNumber sets the block number of the balance txs stored before migration 013 to the height of
+// their tx
+func backfillBalanceTxBlockNumber(conn *DBClient, db *gorm.DB) error {
+	txs := conn.table(model.Transaction{})
+	return db.Table(conn.table(model.BalanceTxn{})).Where("block_number = 0").
+		Update("block_number", gorm.Expr("COALESCE((SELECT t.block_height FROM "+txs+" t WHERE t.chain = "+
+			conn.table(model.BalanceTxn{})+".chain AND t.tx_hash = "+conn.table(model.BalanceTxn{})+".tx_hash), 0)")).Error
+}
+
+// runMigrateSteps runs the steps of the phase above the current version of the database
+func (conn *DBClient) runMigrateSteps(db *gorm.DB, current uint32, before bool) error {
+	for _, step := range migrateSteps {
+		if step.version <= current || step.before != before {
+			continue
+		}
+		if err := step.run(conn, db); err != nil {
+			log.Error("migrate step failed", "step", step.name, "err", err)
+			return err
+		}
+		log.Info("migrate step done", "step", step.name, "version", step.version)
+	}
+	return nil
+}
+
+// AutoMigrateAll creates or updates the tables of all the models and records the schema version in schema_version.
+// It is a no-op when the database is already at the current schema version. The tables are created with the table
+// prefix of the client but the index names are not prefixed, sqlite and postgres scope the index names to the database
+// (schema) so the indexers sharing one there can't all be migrated by AutoMigrateAll.
+func (conn *DBClient) AutoMigrateAll() error {
+	db := conn.SqlDB
+	if db.Dialector.Name() == DatabaseTypeMysql {
+		db = db.Set("gorm:table_options", mysqlTableOptions)
+	}
+
+	if err := db.Table(conn.table(model.SchemaVersion{})).AutoMigrate(&model.SchemaVersion{}); err != nil {
+		log.Error("migrate schema_version table failed", "err", err)
+		return err
+	}
+
+	// Find instead of Take, an empty table on the first run is not logged as a query error
+	var current []*model.SchemaVersion
+	err := db.Clauses(dbresolver.Write).Order("version desc").Limit(1).Find(&current).Error
+	if err != nil {
+		return err
+	}
+	var currentVersion uint32
+	if len(current) > 0 {
+		currentVersion = current[0].Version
+	}
+	if currentVersion >= schemaVersion {
+		return nil
+	}
+
+	if err = conn.runMigrateSteps(db, currentVersion, true); err != nil {
+		return err
+	}
+	// the table of every model is named explicitly, gorm does not run the prefix callbacks for the migrator
+	for _, m := range migrateModels() {
+		if err = db.Table(conn.table(m.(schema.Tabler))).AutoMigrate(m); err != nil {
+			log.Error("migrate tables failed", "err", err)
+			return err
+		}
+	}
+	if err = conn.runMigrateSteps(db, currentVersion, false); err != nil {
+		return err
+	}
+
+	// concurrent runners may record the same version, the first one wins
+	version := &model.SchemaVersion{Version: schemaVersion, AppliedAt: time.Now()}
+	if err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(version).Error; err != nil {
+		return err
+	}
+	log.Info("schema migrated", "version", schemaVersion)
+	return nil
+}
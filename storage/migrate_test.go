@@ -0,0 +1,153 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+// queryPathIndexes the indexes declared on the models for the query paths
+var queryPathIndexes = []struct {
+	table interface{}
+	name  string
+}{
+	{&model.Transaction{}, "idx_txs_tx_hash"},
+	{&model.AddressTxs{}, "idx_address_txs_address"},
+	{&model.AddressTxs{}, "idx_address_txs_tx_hash"},
+	{&model.Balances{}, "idx_balances_tick_balance"},
+	{&model.UTXO{}, "idx_utxos_address"},
+	{&model.Transaction{}, "uq_txs_chain_tx_hash"},
+}
+
+func TestAutoMigrateAll(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type: DatabaseTypeSqlite3,
+		Dsn:  filepath.Join(t.TempDir(), "indexer.db"),
+	})
+	assert.Nil(t, err)
+
+	assert.Nil(t, conn.AutoMigrateAll())
+	migrator := conn.SqlDB.Migrator()
+	for _, table := range append(migrateModels(), &model.SchemaVersion{}) {
+		assert.True(t, migrator.HasTable(table), "%T", table)
+	}
+	for _, index := range queryPathIndexes {
+		assert.True(t, migrator.HasIndex(index.table, index.name), index.name)
+	}
+
+	var versions []*model.SchemaVersion
+	assert.Nil(t, conn.SqlDB.Find(&versions).Error)
+	assert.Equal(t, 1, len(versions))
+	assert.Equal(t, schemaVersion, versions[0].Version)
+
+	// the second run sees the current version and leaves the schema alone
+	assert.Nil(t, migrator.DropTable(&model.UTXO{}))
+	assert.Nil(t, conn.AutoMigrateAll())
+	assert.False(t, migrator.HasTable(&model.UTXO{}))
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.SchemaVersion{}).Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+}
+
+func TestAutoMigrateAllSteps(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type: DatabaseTypeSqlite3,
+		Dsn:  filepath.Join(t.TempDir(), "indexer.db"),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+
+	// a database at version 12 holding balance txs without their block number
+	assert.Nil(t, conn.SqlDB.Where("1 = 1").Delete(&model.SchemaVersion{}).Error)
+	assert.Nil(t, conn.SqlDB.Create(&model.SchemaVersion{Version: 12}).Error)
+	addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: "avalanche", TxHash: "0x1", BlockHeight: 100},
+		{Chain: "btc", TxHash: "0x1", BlockHeight: 7}})
+	balanceTxs := []*model.BalanceTxn{{Chain: "avalanche", TxHash: "0x1", Address: "0xa"}, {Chain: "btc", TxHash: "0x1", Address: "0xa"},
+		{Chain: "avalanche", TxHash: "0xnotx", Address: "0xa"}}
+	assert.Nil(t, conn.SqlDB.Create(balanceTxs).Error)
+
+	assert.Nil(t, conn.AutoMigrateAll())
+	var blocks []uint64
+	assert.Nil(t, conn.SqlDB.Model(&model.BalanceTxn{}).Order("id").Pluck("block_number", &blocks).Error)
+	assert.Equal(t, []uint64{100, 7, 0}, blocks)
+
+	var versions []uint32
+	assert.Nil(t, conn.SqlDB.Model(&model.SchemaVersion{}).Order("version").Pluck("version", &versions).Error)
+	assert.Equal(t, []uint32{12, schemaVersion}, versions)
+}
+
+func TestAutoMigrateAllUTXOVout(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type: DatabaseTypeSqlite3,
+		Dsn:  filepath.Join(t.TempDir(), "indexer.db"),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+
+	// a database at version 7 holding several utxos of a tx, before they had a vout
+	migrator := conn.SqlDB.Migrator()
+	assert.Nil(t, migrator.DropIndex(&model.UTXO{}, "uq_utxos_chain_tx_vout"))
+	assert.Nil(t, migrator.DropColumn(&model.UTXO{}, "Vout"))
+	assert.Nil(t, conn.SqlDB.Where("1 = 1").Delete(&model.SchemaVersion{}).Error)
+	assert.Nil(t, conn.SqlDB.Create(&model.SchemaVersion{Version: 7}).Error)
+	for _, outpoint := range [][2]string{{"btc", "0xt1"}, {"btc", "0xt2"}, {"fractal", "0xt1"}, {"btc", "0xt1"}, {"btc", "0xt1"}} {
+		utxo := &model.UTXO{Chain: outpoint[0], TxHash: outpoint[1], Address: "0xa", Status: model.UTXOStatusUnspent}
+		assert.Nil(t, conn.SqlDB.Omit("Vout").Create(utxo).Error)
+	}
+
+	assert.Nil(t, conn.AutoMigrateAll())
+	var vouts []uint32
+	assert.Nil(t, conn.SqlDB.Model(&model.UTXO{}).Order("id").Pluck("vout", &vouts).Error)
+	assert.Equal(t, []uint32{0, 0, 0, 1, 2}, vouts)
+	assert.True(t, migrator.HasIndex(&model.UTXO{}, "uq_utxos_chain_tx_vout"))
+}
+
+func TestAutoMigrateAllDedupTransactions(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type: DatabaseTypeSqlite3,
+		Dsn:  filepath.Join(t.TempDir(), "indexer.db"),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+
+	// a database at version 10 holding the txs of a reprocessed block twice
+	migrator := conn.SqlDB.Migrator()
+	assert.Nil(t, migrator.DropIndex(&model.Transaction{}, "uq_txs_chain_tx_hash"))
+	assert.Nil(t, conn.SqlDB.Where("1 = 1").Delete(&model.SchemaVersion{}).Error)
+	assert.Nil(t, conn.SqlDB.Create(&model.SchemaVersion{Version: 10}).Error)
+	txs := []*model.Transaction{{Chain: "avalanche", TxHash: "0x1"}, {Chain: "btc", TxHash: "0x1"}, {Chain: "avalanche", TxHash: "0x1"},
+		{Chain: "avalanche", TxHash: "0x2"}, {Chain: "avalanche", TxHash: "0x2"}}
+	assert.Nil(t, conn.SqlDB.Create(txs).Error)
+
+	assert.Nil(t, conn.AutoMigrateAll())
+	var ids []uint64
+	assert.Nil(t, conn.SqlDB.Model(&model.Transaction{}).Order("id").Pluck("id", &ids).Error)
+	assert.Equal(t, []uint64{txs[0].ID, txs[1].ID, txs[3].ID}, ids)
+	assert.True(t, migrator.HasIndex(&model.Transaction{}, "uq_txs_chain_tx_hash"))
+}
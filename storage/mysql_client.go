@@ -27,16 +27,73 @@ import (
 	"github.com/uxuycom/indexer/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 func NewMysqlClient(cfg *config.DatabaseConfig, gormCfg *gorm.Config) (*DBClient, error) {
-	db, err := gorm.Open(mysql.Open(cfg.Dsn), gormCfg)
+	db, err := gorm.Open(mysqlOpen(cfg)(cfg.Dsn), gormCfg)
 	if err != nil {
 		log.Error("connect to mysql failed", "err", err)
 		return nil, err
 	}
+
+	if err = setConnPool(db, cfg); err != nil {
+		log.Error("set mysql connection pool failed", "err", err)
+		return nil, err
+	}
+
+	if err = useReplicas(db, cfg, mysqlOpen(cfg)); err != nil {
+		log.Error("register mysql replicas failed", "err", err)
+		return nil, err
+	}
 	conn := &DBClient{
-		SqlDB: db,
+		SqlDB:             db,
+		metricsEnabled:    cfg.EnableMetrics,
+		batchSize:         cfg.BatchSize,
+		maxStatementBytes: cfg.MaxStatementKB * 1024,
+		validateBalanceTx: cfg.ValidateBalanceTxs,
+		closed:            new(atomic.Bool),
+		optimizing:        new(atomic.Bool),
+		readOnly:          newReadOnlyPool(cfg, gormCfg, mysqlOpen(cfg), mysqlReadOnlyDsn),
+		tablePrefix:       cfg.TablePrefix,
+
+		fastTimeout:       time.Duration(cfg.FastQueryTimeoutMs) * time.Millisecond,
+		analyticalTimeout: time.Duration(cfg.AnalyticalQueryTimeoutMs) * time.Millisecond,
+	}
+	if err = conn.guardClosed(); err != nil {
+		log.Error("register mysql closed check failed", "err", err)
+		return nil, err
+	}
+	if err = useTablePrefix(db, cfg.TablePrefix); err != nil {
+		log.Error("register mysql table prefix failed", "err", err)
+		return nil, err
+	}
+	if err = recordDryRun(db); err != nil {
+		log.Error("register mysql dry run recorder failed", "err", err)
+		return nil, err
 	}
 	return conn, nil
 }
+
+// mysqlOpen returns the mysql dialector constructor applying the query timeout of the config to the dsn. The driver
+// sends the max_execution_time system variable on every new connection, the server aborts the SELECT statements
+// running longer.
+func mysqlOpen(cfg *config.DatabaseConfig) func(dsn string) gorm.Dialector {
+	return func(dsn string) gorm.Dialector {
+		if cfg.QueryTimeoutMs > 0 && !strings.Contains(dsn, "max_execution_time=") {
+			dsn = dsnWithParam(dsn, "max_execution_time", strconv.FormatUint(uint64(cfg.QueryTimeoutMs), 10))
+		}
+		return mysql.Open(dsn)
+	}
+}
+
+// mysqlReadOnlyDsn makes the sessions of the dsn read only, the driver sets the system variable on every new connection
+func mysqlReadOnlyDsn(dsn string) string {
+	if strings.Contains(dsn, "transaction_read_only=") {
+		return dsn
+	}
+	return dsnWithParam(dsn, "transaction_read_only", "1")
+}
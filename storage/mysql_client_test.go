@@ -0,0 +1,102 @@
+//go:build mysql
+
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/datatypes"
+)
+
+// run with: INDEXER_MYSQL_DSN="root@tcp(127.0.0.1:3306)/indexer_test?parseTime=true" go test -tags mysql ./storage
+func newMysqlTestClient(t *testing.T) *DBClient {
+	dsn := os.Getenv("INDEXER_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("INDEXER_MYSQL_DSN not set & ignore this test case")
+	}
+
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type: DatabaseTypeMysql,
+		Dsn:  dsn,
+	})
+	if err != nil {
+		t.Fatalf("connect to mysql failed. err:%v", err)
+	}
+
+	_ = conn.SqlDB.Migrator().DropTable(append(migrateModels(), &model.SchemaVersion{})...)
+	if err = conn.AutoMigrateAll(); err != nil {
+		t.Fatalf("migrate tables failed. err:%v", err)
+	}
+	return conn
+}
+
+func TestMysqlQueryPathIndexes(t *testing.T) {
+	conn := newMysqlTestClient(t)
+	migrator := conn.SqlDB.Migrator()
+	for _, index := range queryPathIndexes {
+		assert.True(t, migrator.HasIndex(index.table, index.name), index.name)
+	}
+}
+
+func TestMysqlInscriptionExtra(t *testing.T) {
+	conn := newMysqlTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	ins := &model.Inscriptions{SID: 1, Chain: chain, Protocol: protocol, Tick: "self"}
+	assert.Nil(t, ins.SetExtraValue("rule", map[string]interface{}{"max_per_mint": "1000", "self_mint": true}))
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{ins, {SID: 2, Chain: chain, Protocol: protocol, Tick: "plain"}})
+
+	stored, err := conn.FindInscriptionByTick(chain, protocol, "self")
+	assert.Nil(t, err)
+	var rule map[string]interface{}
+	found, err := stored.ExtraValue("rule", &rule)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "1000", rule["max_per_mint"])
+
+	var ticks []string
+	err = conn.SqlDB.Model(&model.Inscriptions{}).Where(datatypes.JSONQuery("extra").Equals("1000", "rule", "max_per_mint")).
+		Pluck("tick", &ticks).Error
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"self"}, ticks)
+
+	// the estimates of information_schema lag behind the writes, only the tables are checked
+	counts, err := conn.GetTableCounts(chain, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(counts))
+
+	// the sessions of the read only handle reject writes
+	assert.NotNil(t, conn.ReadOnlyDB().Create(&model.BlockStatus{Chain: "btc", BlockNumber: 1}).Error)
+	assert.Nil(t, conn.ReadOnlyDB().Model(&model.BlockStatus{}).Where("chain = ?", "btc").Pluck("chain", &ticks).Error)
+	assert.Equal(t, 0, len(ticks))
+}
+
+func TestMysqlLockUTXOForSpend(t *testing.T) {
+	testLockUTXOForSpend(t, newMysqlTestClient(t))
+}
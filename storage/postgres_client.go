@@ -0,0 +1,116 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/uxuycom/indexer/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+func NewPostgresClient(cfg *config.DatabaseConfig, gormCfg *gorm.Config) (*DBClient, error) {
+	if cfg == nil {
+		return nil, errors.New("invalid configuration file")
+	}
+	if gormCfg == nil {
+		return nil, errors.New("invalid configuration file")
+	}
+	db, err := gorm.Open(postgresOpen(cfg)(cfg.Dsn), gormCfg)
+	if err != nil {
+		log.Error("connect to postgres failed", "err", err)
+		return nil, err
+	}
+
+	if err = setConnPool(db, cfg); err != nil {
+		log.Error("set postgres connection pool failed", "err", err)
+		return nil, err
+	}
+
+	if err = useReplicas(db, cfg, postgresOpen(cfg)); err != nil {
+		log.Error("register postgres replicas failed", "err", err)
+		return nil, err
+	}
+	conn := &DBClient{
+		SqlDB:             db,
+		metricsEnabled:    cfg.EnableMetrics,
+		batchSize:         cfg.BatchSize,
+		maxStatementBytes: cfg.MaxStatementKB * 1024,
+		validateBalanceTx: cfg.ValidateBalanceTxs,
+		closed:            new(atomic.Bool),
+		optimizing:        new(atomic.Bool),
+		readOnly:          newReadOnlyPool(cfg, gormCfg, postgresOpen(cfg), postgresReadOnlyDsn),
+		tablePrefix:       cfg.TablePrefix,
+
+		fastTimeout:       time.Duration(cfg.FastQueryTimeoutMs) * time.Millisecond,
+		analyticalTimeout: time.Duration(cfg.AnalyticalQueryTimeoutMs) * time.Millisecond,
+	}
+	if err = conn.guardClosed(); err != nil {
+		log.Error("register postgres closed check failed", "err", err)
+		return nil, err
+	}
+	if err = useTablePrefix(db, cfg.TablePrefix); err != nil {
+		log.Error("register postgres table prefix failed", "err", err)
+		return nil, err
+	}
+	if err = recordDryRun(db); err != nil {
+		log.Error("register postgres dry run recorder failed", "err", err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// postgresOpen returns the postgres dialector constructor applying the sslmode and the statement timeout of the config
+// to the dsn, the timeout is sent as a runtime parameter on every new connection
+func postgresOpen(cfg *config.DatabaseConfig) func(dsn string) gorm.Dialector {
+	return func(dsn string) gorm.Dialector {
+		dsn = postgresDsn(dsn, "sslmode", cfg.SslMode)
+		if cfg.QueryTimeoutMs > 0 {
+			dsn = postgresDsn(dsn, "statement_timeout", strconv.FormatUint(uint64(cfg.QueryTimeoutMs), 10))
+		}
+		return postgres.Open(dsn)
+	}
+}
+
+// postgresDsn appends the parameter to the dsn, a parameter already present in the dsn takes precedence.
+// Both the url form (postgres://...) and the keyword/value form (host=... user=...) are supported.
+func postgresDsn(dsn string, key, value string) string {
+	if value == "" || strings.Contains(dsn, key+"=") {
+		return dsn
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return dsnWithParam(dsn, key, value)
+	}
+	return strings.TrimSpace(dsn) + " " + key + "=" + value
+}
+
+// postgresReadOnlyDsn makes the transactions of the dsn read only by default, sent as a runtime parameter
+func postgresReadOnlyDsn(dsn string) string {
+	return postgresDsn(dsn, "default_transaction_read_only", "on")
+}
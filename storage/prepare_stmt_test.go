@@ -0,0 +1,151 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+func TestPrepareStmt(t *testing.T) {
+	for _, prepare := range []bool{false, true} {
+		t.Run(fmt.Sprintf("prepare_stmt=%v", prepare), func(t *testing.T) {
+			conn, err := NewDbClient(&config.DatabaseConfig{
+				Type:        DatabaseTypeSqlite3,
+				Dsn:         filepath.Join(t.TempDir(), "indexer.db"),
+				PrepareStmt: prepare,
+			})
+			assert.Nil(t, err)
+			assert.Nil(t, conn.AutoMigrateAll())
+			chain, protocol := "avalanche", "asc-20"
+
+			// every block has another number of rows, the statements only differ by their placeholders
+			for block := 1; block <= 3; block++ {
+				err = conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+					var ins []*model.Inscriptions
+					var txs []*model.Transaction
+					var stats []*model.InscriptionsStats
+					for i := 0; i < block; i++ {
+						sid := uint32(block*10 + i)
+						tick := fmt.Sprintf("t%d", sid)
+						ins = append(ins, &model.Inscriptions{SID: sid, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: decimal.NewFromInt(100),
+							DeployHash: "0x" + tick})
+						txs = append(txs, &model.Transaction{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0x" + tick, BlockHeight: uint64(block)})
+						stats = append(stats, &model.InscriptionsStats{SID: sid, Chain: chain, Protocol: protocol, Tick: tick})
+					}
+					if skipped, err := conn.BatchAddInscription(tx, ins); err != nil || len(skipped) > 0 {
+						return fmt.Errorf("add inscriptions failed, skipped:%d err:%v", len(skipped), err)
+					}
+					if _, err := conn.BatchAddTransaction(tx, txs); err != nil {
+						return err
+					}
+					if err := conn.BatchAddInscriptionStats(tx, stats); err != nil {
+						return err
+					}
+					for _, item := range stats {
+						item.Minted = decimal.NewFromInt(int64(block))
+						item.Holders = uint64(block)
+					}
+					if err := conn.BatchUpdateInscriptionStats(tx, chain, stats); err != nil {
+						return err
+					}
+					return conn.SaveLastBlock(tx, &model.BlockStatus{Chain: chain, BlockNumber: uint64(block)})
+				})
+				assert.Nil(t, err)
+			}
+
+			ins, err := conn.FindInscriptionByTick(chain, protocol, "t31")
+			assert.Nil(t, err)
+			assert.Equal(t, uint32(31), ins.SID)
+			data, total, err := conn.GetInscriptions(10, 0, InscriptionFilter{Chain: chain, Protocol: protocol, FromMinted: "2", SinceDeployTime: ins.DeployTime}, SortByMinted, OrderByModeDesc)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(5), total)
+			assert.Equal(t, 5, len(data))
+			stats, err := conn.GetInscriptionsStatsBySIDs(chain, []uint32{10, 20, 21, 30})
+			assert.Nil(t, err)
+			assert.Equal(t, 4, len(stats))
+			assert.Equal(t, uint64(3), stats[30].Holders)
+			txs, err := conn.GetTxsByHashes(chain, []string{"0xt10", "0xt32", "0xnone"})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(txs))
+			height, err := conn.QueryLastBlock(chain)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(3), height.Int64())
+
+			assert.Nil(t, conn.DeleteDataAboveBlock(conn.SqlDB, chain, 1))
+			height, err = conn.QueryLastBlock(chain)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(1), height.Int64())
+			ins, err = conn.FindInscriptionByTick(chain, protocol, "t31")
+			assert.Nil(t, err)
+			assert.Nil(t, ins)
+
+			cache, ok := conn.SqlDB.ConnPool.(*gorm.PreparedStmtDB)
+			assert.Equal(t, prepare, ok)
+			if !ok {
+				return
+			}
+			// the statements varying with the number of the values are left out of the cache
+			assert.NotEmpty(t, cache.Stmts)
+			for query := range cache.Stmts {
+				assert.False(t, strings.Contains(strings.ToUpper(query), " IN (?"), query)
+				assert.False(t, strings.Contains(query, "CASE sid"), query)
+				assert.False(t, strings.Contains(query, "),("), query)
+			}
+
+			// the lists of the callers of any length share the cached statements
+			lookup := func(n int) {
+				values := make([]string, 0, n)
+				for i := 0; i < n; i++ {
+					values = append(values, fmt.Sprintf("0xt%d", 10+i))
+				}
+				_, err := conn.LastBlocks(append(values, chain))
+				assert.Nil(t, err)
+				_, err = conn.GetTxsByHashes(chain, values)
+				assert.Nil(t, err)
+				_, err = conn.GetInscriptionsByChain(chain, values)
+				assert.Nil(t, err)
+				_, err = conn.GetInscriptionHolderCount(chain, protocol, "t10", values...)
+				assert.Nil(t, err)
+				_, err = conn.GetCirculatingSupply(chain, protocol, "t10", values)
+				assert.Nil(t, err)
+				_, err = conn.GetInscriptionFacets(InscriptionFilter{Chains: append(values, chain)}, FacetByChain)
+				assert.Nil(t, err)
+			}
+			lookup(1)
+			cached := len(cache.Stmts)
+			for n := 2; n <= 4; n++ {
+				lookup(n)
+				assert.Equal(t, cached, len(cache.Stmts), "lists of %d values", n)
+			}
+		})
+	}
+}
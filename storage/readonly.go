@@ -0,0 +1,118 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/uxuycom/indexer/config"
+	"gorm.io/gorm"
+)
+
+// readOnlyPool the connection pool of ReadOnlyDB, opened on first use. Every connection of the pool is read only
+// at the database level so the handle can not write whatever sql is run through it.
+type readOnlyPool struct {
+	once sync.Once
+	open func() (*gorm.DB, error)
+	db   *gorm.DB
+	err  error
+}
+
+// newReadOnlyPool returns the pool connecting to the replicas of the config, to the source database without replicas.
+// readOnlyDsn makes the connections of the dsn read only.
+func newReadOnlyPool(cfg *config.DatabaseConfig, gormCfg *gorm.Config, open func(dsn string) gorm.Dialector,
+	readOnlyDsn func(dsn string) string) *readOnlyPool {
+	return &readOnlyPool{
+		open: func() (*gorm.DB, error) {
+			dsns := cfg.Replicas
+			if len(dsns) < 1 {
+				dsns = []string{cfg.Dsn}
+			}
+
+			roCfg := *cfg
+			roCfg.Dsn = readOnlyDsn(dsns[0])
+			roCfg.Replicas = make([]string, 0, len(dsns)-1)
+			for _, dsn := range dsns[1:] {
+				roCfg.Replicas = append(roCfg.Replicas, readOnlyDsn(dsn))
+			}
+
+			db, err := gorm.Open(open(roCfg.Dsn), gormCfg)
+			if err != nil {
+				return nil, err
+			}
+			if err = setConnPool(db, &roCfg); err != nil {
+				return nil, err
+			}
+			if err = useReplicas(db, &roCfg, open); err != nil {
+				return nil, err
+			}
+			if err = useTablePrefix(db, cfg.TablePrefix); err != nil {
+				return nil, err
+			}
+			return db, nil
+		},
+	}
+}
+
+func (p *readOnlyPool) get() (*gorm.DB, error) {
+	p.once.Do(func() {
+		p.db, p.err = p.open()
+	})
+	return p.db, p.err
+}
+
+// close closes the pool if it was opened, a pool not opened yet is never opened afterwards
+func (p *readOnlyPool) close() error {
+	p.once.Do(func() {
+		p.err = ErrClientClosed
+	})
+	if p.db == nil {
+		return nil
+	}
+
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// ReadOnlyDB returns a handle for ad-hoc SELECT queries, e.g. analytics the client has no method for. The queries run
+// on the replicas when configured and the connections are read only at the database level, any write fails.
+// Errors opening the read only pool are returned by the queries of the handle.
+func (conn *DBClient) ReadOnlyDB() *gorm.DB {
+	err := ErrClientClosed
+	if conn.readOnly == nil {
+		err = errors.New("read only db is not configured")
+	} else if !conn.isClosed() {
+		var db *gorm.DB
+		if db, err = conn.readOnly.get(); err == nil {
+			return db.Session(&gorm.Session{NewDB: true})
+		}
+	}
+
+	tx := conn.SqlDB.Session(&gorm.Session{NewDB: true})
+	_ = tx.AddError(err)
+	return tx
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestReadOnlyDB(t *testing.T) {
+	conn := newTestClient(t)
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "avalanche", BlockNumber: 100}))
+
+	// the writes of the client are visible through the read only handle
+	var height uint64
+	err := conn.ReadOnlyDB().Model(&model.BlockStatus{}).Where("chain = ?", "avalanche").Select("block_number").Scan(&height).Error
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), height)
+
+	err = conn.ReadOnlyDB().Exec("INSERT INTO block (chain, block_number) VALUES (?, ?)", "btc", 1).Error
+	assert.NotNil(t, err)
+	err = conn.ReadOnlyDB().Create(&model.BlockStatus{Chain: "btc", BlockNumber: 1}).Error
+	assert.NotNil(t, err)
+	err = conn.ReadOnlyDB().Model(&model.BlockStatus{}).Where("chain = ?", "avalanche").Update("block_number", 1).Error
+	assert.NotNil(t, err)
+
+	last, err := conn.QueryLastBlock("avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), last.Int64())
+
+	assert.Nil(t, conn.Close())
+	err = conn.ReadOnlyDB().Model(&model.BlockStatus{}).Select("block_number").Scan(&height).Error
+	assert.True(t, errors.Is(err, ErrClientClosed), err)
+}
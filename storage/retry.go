@@ -0,0 +1,91 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+
+	pgErrSerializationFailure = "40001"
+	pgErrDeadlockDetected     = "40P01"
+)
+
+// retryTxBackoff the wait before the first retry of WithRetryTx, doubled on every further retry
+var retryTxBackoff = 100 * time.Millisecond
+
+// WithRetryTx runs fn inside a transaction and retries the whole transaction with exponential backoff
+// when it fails with a transient lock error (deadlock, lock wait timeout, database busy).
+// fn runs at most maxRetries+1 times, other errors are returned immediately.
+func (conn *DBClient) WithRetryTx(fn func(tx *gorm.DB) error, maxRetries int) error {
+	return conn.withRetryTx(context.Background(), fn, maxRetries)
+}
+
+// withRetryTx is WithRetryTx with the transactions bound to ctx, the wait before a retry ends with ctx as well
+func (conn *DBClient) withRetryTx(ctx context.Context, fn func(tx *gorm.DB) error, maxRetries int) error {
+	backoff := retryTxBackoff
+	for attempt := 0; ; attempt++ {
+		err := conn.SqlDB.WithContext(ctx).Transaction(fn)
+		if err == nil || attempt >= maxRetries || !isRetryableTxErr(err) {
+			return err
+		}
+
+		log.Warn("transaction failed with transient error, retrying", "retry", attempt+1, "max", maxRetries, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryableTxErr reports whether the error is a transient lock error of mysql, postgres or sqlite
+func isRetryableTxErr(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgErrDeadlockDetected || pgErr.Code == pgErrSerializationFailure
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// faultyDriver wraps the sqlite driver and fails the first inserts with the configured error
+type faultyDriver struct {
+	sqlite3.SQLiteDriver
+	failures int32
+	err      error
+}
+
+func (d *faultyDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), driver: d}, nil
+}
+
+func (d *faultyDriver) fault(query string) error {
+	if strings.HasPrefix(strings.ToUpper(query), "INSERT") && atomic.AddInt32(&d.failures, -1) >= 0 {
+		return d.err
+	}
+	return nil
+}
+
+type faultyConn struct {
+	*sqlite3.SQLiteConn
+	driver *faultyDriver
+}
+
+func (c *faultyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.driver.fault(query); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func (c *faultyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.driver.fault(query); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+func newFaultyClient(t *testing.T, failures int32, err error) *DBClient {
+	name := fmt.Sprintf("sqlite3_faulty_%s", t.Name())
+	sql.Register(name, &faultyDriver{failures: failures, err: err})
+
+	db, e := gorm.Open(sqlite.Dialector{DriverName: name, DSN: filepath.Join(t.TempDir(), "indexer.db")}, &gorm.Config{})
+	if e != nil {
+		t.Fatalf("open faulty sqlite db failed. err:%v", e)
+	}
+	if e = db.AutoMigrate(&model.Inscriptions{}); e != nil {
+		t.Fatalf("migrate tables failed. err:%v", e)
+	}
+
+	backoff := retryTxBackoff
+	retryTxBackoff = time.Millisecond
+	t.Cleanup(func() { retryTxBackoff = backoff })
+	return &DBClient{SqlDB: db}
+}
+
+func TestWithRetryTx(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	conn := newFaultyClient(t, 2, busy)
+
+	attempts := 0
+	err := conn.WithRetryTx(func(tx *gorm.DB) error {
+		attempts++
+		_, err := conn.BatchAddInscription(tx, []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "avav"}})
+		return err
+	}, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+
+	ins, err := conn.FindInscriptionByTick("avalanche", "asc-20", "avav")
+	assert.Nil(t, err)
+	assert.NotNil(t, ins)
+}
+
+func TestWithRetryTxExhausted(t *testing.T) {
+	conn := newFaultyClient(t, 10, sqlite3.Error{Code: sqlite3.ErrLocked})
+
+	attempts := 0
+	err := conn.WithRetryTx(func(tx *gorm.DB) error {
+		attempts++
+		_, err := conn.BatchAddInscription(tx, []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "avav"}})
+		return err
+	}, 2)
+	assert.True(t, isRetryableTxErr(err))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWithRetryTxNonRetryable(t *testing.T) {
+	conn := newFaultyClient(t, 1, sqlite3.Error{Code: sqlite3.ErrConstraint})
+
+	attempts := 0
+	err := conn.WithRetryTx(func(tx *gorm.DB) error {
+		attempts++
+		_, err := conn.BatchAddInscription(tx, []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "avav"}})
+		return err
+	}, 3)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithRetryTxCancelled(t *testing.T) {
+	conn := newFaultyClient(t, 0, nil)
+	retryTxBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.withRetryTx(ctx, func(tx *gorm.DB) error {
+			cancel()
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}, 3)
+	}()
+
+	// the cancelled caller does not wait for the backoff
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the retry waited for the backoff of the cancelled context")
+	}
+}
+
+func TestIsRetryableTxErr(t *testing.T) {
+	assert.True(t, isRetryableTxErr(&mysql.MySQLError{Number: 1213}))
+	assert.True(t, isRetryableTxErr(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1205})))
+	assert.False(t, isRetryableTxErr(&mysql.MySQLError{Number: 1062}))
+	assert.False(t, isRetryableTxErr(gorm.ErrRecordNotFound))
+	assert.False(t, isRetryableTxErr(nil))
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+type tickKey struct {
+	Protocol string
+	Tick     string
+}
+
+type balanceKey struct {
+	tickKey
+	Address string
+}
+
+// DeleteDataAboveBlock rolls back the indexed data of the chain above the block height after a chain reorg.
+// The txs, address_txs, balance_txn and utxos rows produced by the txs above the height are removed, the balances are
+// restored from the latest balance_txn left and the affected inscriptions stats (minted, holders, tx_cnt) are
+// recomputed. Inscriptions deployed above the height are removed together with their stats. Everything runs in one
+// transaction (a savepoint when dbTx is already a transaction).
+//
+// UTXOs spent above the height are not restored, the spending tx is not recorded on the utxo row.
+func (conn *DBClient) DeleteDataAboveBlock(dbTx *gorm.DB, chain string, blockNumber uint64) error {
+	if dbTx == nil {
+		return ErrNilTx
+	}
+
+	err := dbTx.Transaction(func(tx *gorm.DB) error {
+		rows := make([]struct {
+			Protocol string
+			Tick     string
+			Cnt      uint64
+		}, 0)
+		err := tx.Model(&model.Transaction{}).Select("protocol, tick, COUNT(*) as cnt").
+			Where("chain = ? AND block_height > ?", chain, blockNumber).Group("protocol, tick").Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		if len(rows) > 0 {
+			txCnt := make(map[tickKey]uint64, len(rows))
+			for _, row := range rows {
+				txCnt[tickKey{Protocol: row.Protocol, Tick: row.Tick}] = row.Cnt
+			}
+			if err = conn.rollbackTxs(tx, chain, blockNumber, txCnt); err != nil {
+				return err
+			}
+		}
+
+		// block hash is unknown for the lower height, it is refreshed by the next SaveLastBlock
+		return tx.Table(conn.table(model.BlockStatus{})).Where("chain = ? AND block_number > ?", chain, blockNumber).
+			Updates(map[string]interface{}{"block_number": blockNumber, "block_hash": ""}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("delete data of chain[%s] above block[%d] failed: %w", chain, blockNumber, err)
+	}
+	return nil
+}
+
+// aboveBlock the predicate of the rows produced by the txs of the chain above the height, matched on the hash column.
+// The txs are selected by a subquery, a deep rollback would exceed the bind variable limits with a list of hashes.
+// The txs rows are deleted last, the predicate holds until then. Its arguments are the chain and the height.
+func (conn *DBClient) aboveBlock(column string) string {
+	return column + " IN (SELECT tx_hash FROM " + conn.table(model.Transaction{}) + " WHERE chain = ? AND block_height > ?)"
+}
+
+func (conn *DBClient) rollbackTxs(tx *gorm.DB, chain string, blockNumber uint64, txCnt map[tickKey]uint64) error {
+	// inscriptions deployed in the range are removed as a whole
+	deploys := make([]*model.Inscriptions, 0)
+	err := tx.Unscoped().Where("chain = ?", chain).Where(conn.aboveBlock("deploy_hash"), chain, blockNumber).Find(&deploys).Error
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[tickKey]bool, len(deploys))
+	for _, ins := range deploys {
+		deleted[tickKey{Protocol: ins.Protocol, Tick: ins.Tick}] = true
+
+		conds := []interface{}{"chain = ? AND protocol = ? AND tick = ?", chain, ins.Protocol, ins.Tick}
+		if err = tx.Unscoped().Delete(&model.Inscriptions{}, conds...).Error; err != nil {
+			return err
+		}
+		if err = tx.Delete(&model.InscriptionsStats{}, conds...).Error; err != nil {
+			return err
+		}
+		if err = tx.Delete(&model.Balances{}, conds...).Error; err != nil {
+			return err
+		}
+	}
+
+	// the balances touched by the deleted balance txs
+	balanceTxs := make([]*model.BalanceTxn, 0)
+	err = tx.Where("chain = ?", chain).Where(conn.aboveBlock("tx_hash"), chain, blockNumber).Find(&balanceTxs).Error
+	if err != nil {
+		return err
+	}
+
+	touched := make(map[balanceKey]bool, len(balanceTxs))
+	minted := make(map[tickKey]decimal.Decimal, len(balanceTxs))
+	for _, item := range balanceTxs {
+		tk := tickKey{Protocol: item.Protocol, Tick: item.Tick}
+		if deleted[tk] {
+			continue
+		}
+
+		touched[balanceKey{tickKey: tk, Address: item.Address}] = true
+		if item.Event == model.TransactionEventMint {
+			minted[tk] = minted[tk].Add(item.Amount)
+		}
+	}
+
+	for key := range touched {
+		if err = conn.revertBalance(tx, chain, key, blockNumber); err != nil {
+			return err
+		}
+	}
+
+	for key, cnt := range txCnt {
+		if deleted[key] {
+			continue
+		}
+		if err = conn.revertInscriptionStats(tx, chain, key, minted[key], cnt, blockNumber); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range []interface{}{&model.AddressTxs{}, &model.BalanceTxn{}, &model.UTXO{}} {
+		err = tx.Where("chain = ?", chain).Where(conn.aboveBlock("tx_hash"), chain, blockNumber).Delete(value).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("chain = ? AND block_height > ?", chain, blockNumber).Delete(&model.Transaction{}).Error
+}
+
+// revertBalance restores the balance and the available of the address from the latest balance tx below the height,
+// every balance tx stores both after the tx. They move independently (an inscribed transfer only moves the available),
+// subtracting the deltas of the deleted txs from both would corrupt the available. The balance row is deleted when
+// all of its balance txs are rolled back.
+func (conn *DBClient) revertBalance(tx *gorm.DB, chain string, key balanceKey, blockNumber uint64) error {
+	balance := &model.Balances{}
+	err := tx.Where("chain = ? AND protocol = ? AND tick = ? AND address = ?", chain, key.Protocol, key.Tick, key.Address).Take(balance).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	last := make([]*model.BalanceTxn, 0, 1)
+	err = tx.Where("chain = ? AND protocol = ? AND tick = ? AND address = ?", chain, key.Protocol, key.Tick, key.Address).
+		Where("NOT "+conn.aboveBlock("tx_hash"), chain, blockNumber).Order("id desc").Limit(1).Find(&last).Error
+	if err != nil {
+		return err
+	}
+	// the balance row was created in the rolled back range
+	if len(last) < 1 {
+		return tx.Delete(balance).Error
+	}
+
+	return tx.Model(balance).Updates(map[string]interface{}{
+		"balance":   last[0].Balance,
+		"available": last[0].Available,
+		"version":   gorm.Expr("version + 1"),
+	}).Error
+}
+
+func (conn *DBClient) revertInscriptionStats(tx *gorm.DB, chain string, key tickKey, minted decimal.Decimal, txCnt uint64, blockNumber uint64) error {
+	stats := &model.InscriptionsStats{}
+	err := tx.Where("chain = ? AND protocol = ? AND tick = ?", chain, key.Protocol, key.Tick).Take(stats).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var holders int64
+	err = tx.Model(&model.Balances{}).Where("chain = ? AND protocol = ? AND tick = ? AND balance > 0", chain, key.Protocol, key.Tick).Count(&holders).Error
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"minted":  stats.Minted.Sub(minted),
+		"holders": holders,
+	}
+
+	if stats.TxCnt > txCnt {
+		updates["tx_cnt"] = stats.TxCnt - txCnt
+	} else {
+		updates["tx_cnt"] = 0
+	}
+
+	if stats.MintFirstBlock > blockNumber {
+		updates["mint_first_block"] = 0
+	}
+	if stats.MintLastBlock > blockNumber {
+		updates["mint_last_block"] = 0
+		updates["mint_completed_time"] = nil
+	}
+	return tx.Model(stats).Updates(updates).Error
+}
+
+// PurgeChainData deletes all the indexed data of the chain before a full re-index and returns the deleted rows per
+// table. Every statement is scoped by the chain so the other chains may keep indexing meanwhile. Everything runs in
+// one transaction (a savepoint when dbTx is already a transaction). The address labels are not indexed data and are kept.
+func (conn *DBClient) PurgeChainData(dbTx *gorm.DB, chain string) (map[string]int64, error) {
+	if dbTx == nil {
+		return nil, ErrNilTx
+	}
+
+	tables := []interface {
+		TableName() string
+	}{&model.AddressTxs{}, &model.BalanceTxn{}, &model.UTXO{}, &model.Transaction{}, &model.Balances{},
+		&model.InscriptionsStats{}, &model.Inscriptions{}, &model.BlockStatus{}}
+
+	deleted := make(map[string]int64, len(tables))
+	err := dbTx.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			result := tx.Unscoped().Where("chain = ?", chain).Delete(table)
+			if result.Error != nil {
+				return result.Error
+			}
+			deleted[table.TableName()] = result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("purge data of chain[%s] failed: %w", chain, err)
+	}
+	return deleted, nil
+}
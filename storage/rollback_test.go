@@ -0,0 +1,273 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+type testBlock struct {
+	txs        []*model.Transaction
+	balanceTxs []*model.BalanceTxn
+	addressTxs []*model.AddressTxs
+	balances   []*model.Balances
+	stats      *model.InscriptionsStats
+	status     *model.BlockStatus
+}
+
+func (b *testBlock) apply(t *testing.T, conn *DBClient) {
+	err := conn.SqlDB.Transaction(func(tx *gorm.DB) error {
+		if _, err := conn.BatchAddTransaction(tx, b.txs); err != nil {
+			return err
+		}
+		if err := conn.BatchAddBalanceTx(tx, b.balanceTxs); err != nil {
+			return err
+		}
+		if err := conn.BatchAddAddressTx(tx, b.addressTxs); err != nil {
+			return err
+		}
+		for _, balance := range b.balances {
+			balance.ID = balance.SID
+			if err := tx.Save(balance).Error; err != nil {
+				return err
+			}
+		}
+		b.stats.ID = b.stats.SID
+		if err := tx.Save(b.stats).Error; err != nil {
+			return err
+		}
+		return conn.SaveLastBlock(tx, b.status)
+	})
+	assert.Nil(t, err)
+}
+
+func TestDeleteDataAboveBlock(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.RequireFromString
+
+	newTx := func(hash string, block uint64, op string) *model.Transaction {
+		return &model.Transaction{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, BlockHeight: block, Op: op}
+	}
+	newBalanceTx := func(hash, address string, event model.TxEvent, delta, balance string) *model.BalanceTxn {
+		return &model.BalanceTxn{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: address, Event: event,
+			Amount: amount(delta), Balance: amount(balance), Available: amount(balance)}
+	}
+	newBalance := func(sid uint64, address, balance string) *model.Balances {
+		return &model.Balances{SID: sid, Chain: chain, Protocol: protocol, Tick: tick, Address: address, Balance: amount(balance), Available: amount(balance)}
+	}
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount("1000"), DeployHash: "0xd1"}}
+	addInscriptions(t, conn, conn.SqlDB, ins)
+
+	completed := time.Now()
+	blocks := []*testBlock{
+		{
+			txs:        []*model.Transaction{newTx("0xd1", 1, "deploy"), newTx("0xm1", 1, "mint")},
+			balanceTxs: []*model.BalanceTxn{newBalanceTx("0xm1", "0xa", model.TransactionEventMint, "100", "100")},
+			addressTxs: []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm1", Address: "0xa", Amount: amount("100"), Event: model.TransactionEventMint}},
+			balances:   []*model.Balances{newBalance(1, "0xa", "100")},
+			stats:      &model.InscriptionsStats{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("100"), Holders: 1, TxCnt: 2, MintFirstBlock: 1},
+			status:     &model.BlockStatus{Chain: chain, BlockNumber: 1, BlockHash: "0xb1"},
+		},
+		{
+			txs:        []*model.Transaction{newTx("0xm2", 2, "mint")},
+			balanceTxs: []*model.BalanceTxn{newBalanceTx("0xm2", "0xb", model.TransactionEventMint, "900", "900")},
+			balances:   []*model.Balances{newBalance(2, "0xb", "900")},
+			stats: &model.InscriptionsStats{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("1000"), Holders: 2, TxCnt: 3,
+				MintFirstBlock: 1, MintLastBlock: 2, MintCompletedTime: &completed},
+			status: &model.BlockStatus{Chain: chain, BlockNumber: 2, BlockHash: "0xb2"},
+		},
+		{
+			txs: []*model.Transaction{newTx("0xt3", 3, "transfer")},
+			balanceTxs: []*model.BalanceTxn{
+				newBalanceTx("0xt3", "0xa", model.TransactionEventTransfer, "-40", "60"),
+				newBalanceTx("0xt3", "0xb", model.TransactionEventTransfer, "40", "940"),
+			},
+			balances: []*model.Balances{newBalance(1, "0xa", "60"), newBalance(2, "0xb", "940")},
+			stats: &model.InscriptionsStats{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("1000"), Holders: 2, TxCnt: 4,
+				MintFirstBlock: 1, MintLastBlock: 2, MintCompletedTime: &completed},
+			status: &model.BlockStatus{Chain: chain, BlockNumber: 3, BlockHash: "0xb3"},
+		},
+	}
+	for _, block := range blocks {
+		block.apply(t, conn)
+	}
+
+	// the other chain must stay untouched
+	addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: "btc", TxHash: "0xother", BlockHeight: 5}})
+
+	assert.Nil(t, conn.DeleteDataAboveBlock(conn.SqlDB, chain, 1))
+
+	balanceA, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xa")
+	assert.Nil(t, err)
+	assert.True(t, balanceA.Balance.Equal(amount("100")), balanceA.Balance.String())
+	assert.True(t, balanceA.Available.Equal(amount("100")), balanceA.Available.String())
+
+	balanceB, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xb")
+	assert.Nil(t, err)
+	assert.Nil(t, balanceB)
+
+	stats, err := conn.FindInscriptionStatsByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.True(t, stats.Minted.Equal(amount("100")), stats.Minted.String())
+	assert.Equal(t, uint64(1), stats.Holders)
+	assert.Equal(t, uint64(2), stats.TxCnt)
+	assert.Equal(t, uint64(1), stats.MintFirstBlock)
+	assert.Equal(t, uint64(0), stats.MintLastBlock)
+	assert.Nil(t, stats.MintCompletedTime)
+
+	var cnt int64
+	assert.Nil(t, conn.SqlDB.Model(&model.Transaction{}).Where("chain = ?", chain).Count(&cnt).Error)
+	assert.Equal(t, int64(2), cnt)
+	assert.Nil(t, conn.SqlDB.Model(&model.BalanceTxn{}).Where("chain = ?", chain).Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+	assert.Nil(t, conn.SqlDB.Model(&model.Transaction{}).Where("chain = ?", "btc").Count(&cnt).Error)
+	assert.Equal(t, int64(1), cnt)
+
+	height, err := conn.QueryLastBlock(chain)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), height.Int64())
+
+	ins1, err := conn.FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.NotNil(t, ins1)
+}
+
+func TestDeleteDataAboveBlockRestoresAvailable(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.RequireFromString
+
+	newBalanceTx := func(hash, address string, delta, balance, available string) *model.BalanceTxn {
+		return &model.BalanceTxn{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: address,
+			Event: model.TransactionEventTransfer, Amount: amount(delta), Balance: amount(balance), Available: amount(available)}
+	}
+	newBalance := func(sid uint64, address, balance, available string) *model.Balances {
+		return &model.Balances{SID: sid, Chain: chain, Protocol: protocol, Tick: tick, Address: address, Balance: amount(balance),
+			Available: amount(available)}
+	}
+	newStats := func(txCnt uint64) *model.InscriptionsStats {
+		return &model.InscriptionsStats{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Minted: amount("100"), Holders: 1, TxCnt: txCnt}
+	}
+
+	addInscriptions(t, conn, conn.SqlDB, []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick,
+		TotalSupply: amount("100"), DeployHash: "0xd0"}})
+	blocks := []*testBlock{
+		{
+			txs:        []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xm1", BlockHeight: 1, Op: "mint"}},
+			balanceTxs: []*model.BalanceTxn{newBalanceTx("0xm1", "0xa", "100", "100", "100")},
+			balances:   []*model.Balances{newBalance(1, "0xa", "100", "100")},
+			stats:      newStats(1),
+			status:     &model.BlockStatus{Chain: chain, BlockNumber: 1},
+		},
+		{
+			// the inscribed transfer only moves the available
+			txs:        []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xi2", BlockHeight: 2, Op: "transfer"}},
+			balanceTxs: []*model.BalanceTxn{newBalanceTx("0xi2", "0xa", "0", "100", "60")},
+			balances:   []*model.Balances{newBalance(1, "0xa", "100", "60")},
+			stats:      newStats(2),
+			status:     &model.BlockStatus{Chain: chain, BlockNumber: 2},
+		},
+		{
+			// sending the inscribed transfer leaves the available as it is
+			txs: []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: "0xt3", BlockHeight: 3, Op: "transfer"}},
+			balanceTxs: []*model.BalanceTxn{newBalanceTx("0xt3", "0xa", "-40", "60", "60"),
+				newBalanceTx("0xt3", "0xb", "40", "40", "40")},
+			balances: []*model.Balances{newBalance(1, "0xa", "60", "60"), newBalance(2, "0xb", "40", "40")},
+			stats:    newStats(3),
+			status:   &model.BlockStatus{Chain: chain, BlockNumber: 3},
+		},
+	}
+	for _, block := range blocks {
+		block.apply(t, conn)
+	}
+
+	assert.Nil(t, conn.DeleteDataAboveBlock(conn.SqlDB, chain, 2))
+
+	balanceA, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xa")
+	assert.Nil(t, err)
+	assert.True(t, balanceA.Balance.Equal(amount("100")), balanceA.Balance.String())
+	assert.True(t, balanceA.Available.Equal(amount("60")), balanceA.Available.String())
+
+	// the receiver has no balance tx left
+	balanceB, err := conn.FindUserBalanceByTick(chain, protocol, tick, "0xb")
+	assert.Nil(t, err)
+	assert.Nil(t, balanceB)
+}
+
+func TestPurgeChainData(t *testing.T) {
+	conn := newTestClient(t)
+	protocol, tick := "asc-20", "tick"
+	amount := decimal.NewFromInt(100)
+
+	seed := func(chain string, sid uint64) {
+		hash := chain + "-0xm1"
+		ins := []*model.Inscriptions{{SID: uint32(sid), Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount, DeployHash: hash}}
+		addInscriptions(t, conn, conn.SqlDB, ins)
+		block := &testBlock{
+			txs: []*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, BlockHeight: 1, Op: "mint"}},
+			balanceTxs: []*model.BalanceTxn{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: "0xa",
+				Event: model.TransactionEventMint, Amount: amount, Balance: amount, Available: amount}},
+			addressTxs: []*model.AddressTxs{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash, Address: "0xa",
+				Amount: amount, Event: model.TransactionEventMint}},
+			balances: []*model.Balances{{SID: sid, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount, Available: amount}},
+			stats:    &model.InscriptionsStats{SID: uint32(sid), Chain: chain, Protocol: protocol, Tick: tick, Minted: amount, Holders: 1, TxCnt: 1},
+			status:   &model.BlockStatus{Chain: chain, BlockNumber: 1, BlockHash: "0xb1"},
+		}
+		block.apply(t, conn)
+		utxos := []*model.UTXO{{Sn: hash, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Amount: amount,
+			RootHash: hash, TxHash: hash, Status: model.UTXOStatusUnspent}}
+		assert.Nil(t, conn.SqlDB.Create(utxos).Error)
+	}
+	seed("avalanche", 1)
+	seed("btc", 2)
+
+	// soft deleted inscriptions are purged as well
+	assert.Nil(t, conn.SoftDeleteInscription(conn.SqlDB, "avalanche", protocol, tick))
+
+	deleted, err := conn.PurgeChainData(conn.SqlDB, "avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"address_txs": 1, "balance_txn": 1, "utxos": 1, "txs": 1, "balances": 1,
+		"inscriptions_stats": 1, "inscriptions": 1, "block": 1}, deleted)
+
+	tables := []interface{}{&model.AddressTxs{}, &model.BalanceTxn{}, &model.UTXO{}, &model.Transaction{}, &model.Balances{},
+		&model.InscriptionsStats{}, &model.Inscriptions{}, &model.BlockStatus{}}
+	for _, table := range tables {
+		var cnt int64
+		assert.Nil(t, conn.SqlDB.Unscoped().Model(table).Where("chain = ?", "avalanche").Count(&cnt).Error)
+		assert.Equal(t, int64(0), cnt, "%T", table)
+		assert.Nil(t, conn.SqlDB.Unscoped().Model(table).Where("chain = ?", "btc").Count(&cnt).Error)
+		assert.Equal(t, int64(1), cnt, "%T", table)
+	}
+
+	height, err := conn.QueryLastBlock("btc")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), height.Int64())
+}
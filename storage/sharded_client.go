@@ -0,0 +1,110 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/uxuycom/indexer/config"
+)
+
+// DefaultShard the shard key of the database serving the chains without a shard of their own
+const DefaultShard = "default"
+
+var ErrShardNotFound = errors.New("storage shard not found")
+
+// ShardedDbClient routes each chain to its own database.
+// All the data of a chain, including its block status, lives in the shard of the chain, so the methods taking a chain
+// are served by the client returned from For. Queries across chains, e.g. GetInscriptions with an empty chain, are not
+// supported in sharded mode as they only see the rows of a single shard.
+type ShardedDbClient struct {
+	shards   map[string]*DBClient
+	fallback *DBClient
+	clients  []*DBClient
+}
+
+// NewShardedDbClient connects to the databases of the chain -> config map, the DefaultShard entry is optional.
+// Chains configured with the same database type and dsn share one client.
+func NewShardedDbClient(cfgs map[string]config.DatabaseConfig) (*ShardedDbClient, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("invalid configuration file")
+	}
+
+	sharded := &ShardedDbClient{shards: make(map[string]*DBClient, len(cfgs))}
+	byDsn := make(map[string]*DBClient, len(cfgs))
+	for chain, cfg := range cfgs {
+		key := cfg.Type + "|" + cfg.Dsn
+		conn, ok := byDsn[key]
+		if !ok {
+			cfg := cfg
+			var err error
+			conn, err = NewDbClient(&cfg)
+			if err == nil && conn == nil {
+				err = fmt.Errorf("unsupported database type %q", cfg.Type)
+			}
+			if err != nil {
+				log.Error("connect to shard failed", "chain", chain, "err", err)
+				_ = sharded.Close()
+				return nil, err
+			}
+			byDsn[key] = conn
+			sharded.clients = append(sharded.clients, conn)
+		}
+
+		if chain == DefaultShard {
+			sharded.fallback = conn
+			continue
+		}
+		sharded.shards[chain] = conn
+	}
+	return sharded, nil
+}
+
+// For returns the client of the chain shard, the default shard when the chain has no shard of its own
+func (s *ShardedDbClient) For(chain string) (*DBClient, error) {
+	if conn, ok := s.shards[chain]; ok {
+		return conn, nil
+	}
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+	return nil, fmt.Errorf("%w: chain %s", ErrShardNotFound, chain)
+}
+
+// Clients returns the distinct clients of all the shards
+func (s *ShardedDbClient) Clients() []*DBClient {
+	return s.clients
+}
+
+// Close closes the clients of all the shards
+func (s *ShardedDbClient) Close() error {
+	var errs []error
+	for _, conn := range s.clients {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestShardedDbClient(t *testing.T) {
+	dir := t.TempDir()
+	shardCfg := func(name string) config.DatabaseConfig {
+		return config.DatabaseConfig{Type: DatabaseTypeSqlite3, Dsn: filepath.Join(dir, name+".db")}
+	}
+	sharded, err := NewShardedDbClient(map[string]config.DatabaseConfig{
+		"avalanche":  shardCfg("a"),
+		"btc":        shardCfg("b"),
+		"fractal":    shardCfg("b"),
+		DefaultShard: shardCfg("default"),
+	})
+	assert.Nil(t, err)
+	defer sharded.Close()
+	assert.Equal(t, 3, len(sharded.Clients()))
+	for _, conn := range sharded.Clients() {
+		assert.Nil(t, conn.AutoMigrateAll())
+	}
+
+	shardA, err := sharded.For("avalanche")
+	assert.Nil(t, err)
+	shardB, err := sharded.For("btc")
+	assert.Nil(t, err)
+	assert.NotSame(t, shardA, shardB)
+
+	fractal, err := sharded.For("fractal")
+	assert.Nil(t, err)
+	assert.Same(t, shardB, fractal)
+
+	unknown, err := sharded.For("eth")
+	assert.Nil(t, err)
+	assert.NotSame(t, shardA, unknown)
+	assert.NotSame(t, shardB, unknown)
+
+	ins := []*model.Inscriptions{{SID: 1, Chain: "avalanche", Protocol: "asc-20", Tick: "tick", TotalSupply: decimal.NewFromInt(100)}}
+	addInscriptions(t, shardA, shardA.SqlDB, ins)
+	assert.Nil(t, shardA.SaveLastBlock(shardA.SqlDB, &model.BlockStatus{Chain: "avalanche", BlockNumber: 10}))
+
+	for _, conn := range []*DBClient{shardB, unknown} {
+		found, err := conn.FindInscriptionByTick("avalanche", "asc-20", "tick")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		height, err := conn.QueryLastBlock("avalanche")
+		assert.Nil(t, err)
+		assert.Nil(t, height)
+	}
+
+	found, err := shardA.FindInscriptionByTick("avalanche", "asc-20", "tick")
+	assert.Nil(t, err)
+	assert.NotNil(t, found)
+	height, err := shardA.QueryLastBlock("avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), height.Int64())
+}
+
+func TestShardedDbClientWithoutDefault(t *testing.T) {
+	sharded, err := NewShardedDbClient(map[string]config.DatabaseConfig{
+		"avalanche": {Type: DatabaseTypeSqlite3, Dsn: filepath.Join(t.TempDir(), "a.db")},
+	})
+	assert.Nil(t, err)
+	defer sharded.Close()
+
+	_, err = sharded.For("btc")
+	assert.True(t, errors.Is(err, ErrShardNotFound), err)
+
+	_, err = NewShardedDbClient(map[string]config.DatabaseConfig{"avalanche": {Type: "oracle"}})
+	assert.NotNil(t, err)
+}
@@ -23,12 +23,25 @@
 package storage
 
 import (
+	"database/sql/driver"
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/log"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/uxuycom/indexer/config"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// default sqlite pragmas
+const (
+	DefaultSqliteJournalMode   = "WAL"
+	DefaultSqliteSynchronous   = "NORMAL"
+	DefaultSqliteBusyTimeoutMs = 5000
 )
 
 func NewSqliteClient(cfg *config.DatabaseConfig, gormCfg *gorm.Config) (*DBClient, error) {
@@ -38,16 +51,134 @@ func NewSqliteClient(cfg *config.DatabaseConfig, gormCfg *gorm.Config) (*DBClien
 	if gormCfg == nil {
 		return nil, errors.New("invalid configuration file")
 	}
-	db, err := gorm.Open(sqlite.Open(cfg.Dsn), gormCfg)
+	db, err := gorm.Open(sqliteOpen(cfg)(cfg.Dsn), gormCfg)
 	if err != nil {
 		log.Error("connect to sqlite failed", "err", err)
 		return nil, err
 	}
 
+	if err = setConnPool(db, cfg); err != nil {
+		log.Error("set sqlite connection pool failed", "err", err)
+		return nil, err
+	}
+
+	if err = useReplicas(db, cfg, sqliteOpen(cfg)); err != nil {
+		log.Error("register sqlite replicas failed", "err", err)
+		return nil, err
+	}
+
 	conn := &DBClient{
-		SqlDB: db,
+		SqlDB:             db,
+		metricsEnabled:    cfg.EnableMetrics,
+		batchSize:         cfg.BatchSize,
+		maxStatementBytes: cfg.MaxStatementKB * 1024,
+		validateBalanceTx: cfg.ValidateBalanceTxs,
+		closed:            new(atomic.Bool),
+		optimizing:        new(atomic.Bool),
+		readOnly:          newReadOnlyPool(cfg, gormCfg, sqliteOpen(cfg), sqliteReadOnlyDsn),
+		tablePrefix:       cfg.TablePrefix,
+
+		fastTimeout:       time.Duration(cfg.FastQueryTimeoutMs) * time.Millisecond,
+		analyticalTimeout: time.Duration(cfg.AnalyticalQueryTimeoutMs) * time.Millisecond,
+	}
+	if err = conn.guardClosed(); err != nil {
+		log.Error("register sqlite closed check failed", "err", err)
+		return nil, err
+	}
+	if err = useTablePrefix(db, cfg.TablePrefix); err != nil {
+		log.Error("register sqlite table prefix failed", "err", err)
+		return nil, err
+	}
+	if err = recordDryRun(db); err != nil {
+		log.Error("register sqlite dry run recorder failed", "err", err)
+		return nil, err
 	}
 
 	log.Info("connect to sqlite success")
 	return conn, nil
 }
+
+// sqliteOpen returns the sqlite dialector constructor applying the pragmas of the config to the dsn. The driver runs
+// them on every new connection of the pool, the ones already set by the dsn are kept.
+// A write waiting for the lock of another connection fails with SQLITE_BUSY after the busy timeout.
+func sqliteOpen(cfg *config.DatabaseConfig) func(dsn string) gorm.Dialector {
+	journalMode, synchronous, busyTimeoutMs := sqlitePragmas(cfg)
+	return func(dsn string) gorm.Dialector {
+		if !strings.Contains(dsn, "_journal_mode=") && !strings.Contains(dsn, "_journal=") {
+			dsn = dsnWithParam(dsn, "_journal_mode", journalMode)
+		}
+		if !strings.Contains(dsn, "_synchronous=") && !strings.Contains(dsn, "_sync=") {
+			dsn = dsnWithParam(dsn, "_synchronous", synchronous)
+		}
+		if !strings.Contains(dsn, "_timeout=") {
+			dsn = dsnWithParam(dsn, "_busy_timeout", strconv.FormatUint(uint64(busyTimeoutMs), 10))
+		}
+		return sqlite.Open(dsn)
+	}
+}
+
+// sqlitePragmas the pragmas of the config, empty values fall back to the defaults. The busy timeout falls back to the
+// query timeout first.
+func sqlitePragmas(cfg *config.DatabaseConfig) (journalMode, synchronous string, busyTimeoutMs uint32) {
+	journalMode = DefaultSqliteJournalMode
+	if cfg.SqliteJournalMode != "" {
+		journalMode = cfg.SqliteJournalMode
+	}
+	synchronous = DefaultSqliteSynchronous
+	if cfg.SqliteSynchronous != "" {
+		synchronous = cfg.SqliteSynchronous
+	}
+	busyTimeoutMs = DefaultSqliteBusyTimeoutMs
+	if cfg.SqliteBusyTimeoutMs > 0 {
+		busyTimeoutMs = cfg.SqliteBusyTimeoutMs
+	} else if cfg.QueryTimeoutMs > 0 {
+		busyTimeoutMs = cfg.QueryTimeoutMs
+	}
+	return
+}
+
+// sqliteReadOnlyDsn enables the query_only pragma on the connections of the dsn
+func sqliteReadOnlyDsn(dsn string) string {
+	if strings.Contains(dsn, "_query_only=") {
+		return dsn
+	}
+	return dsnWithParam(dsn, "_query_only", "1")
+}
+
+// aggregateTime a time read from a MIN / MAX of a datetime column. Sqlite returns the aggregate as text, the driver
+// only converts the values of columns declared as datetime. The texts compare in time order as long as all the times
+// are stored with the same time zone.
+type aggregateTime struct {
+	time.Time
+}
+
+func (t *aggregateTime) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("unsupported time value type[%T]", value)
+	}
+
+	text = strings.TrimSuffix(text, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if parsed, err := time.ParseInLocation(format, text, time.UTC); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time value[%s]", text)
+}
+
+func (t aggregateTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestSqlitePragmas(t *testing.T) {
+	conn := newTestClient(t)
+	sqlDB, err := conn.SqlDB.DB()
+	assert.Nil(t, err)
+
+	// every connection of the pool gets the pragmas, not only the first one
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c, err := sqlDB.Conn(ctx)
+		assert.Nil(t, err)
+		defer c.Close()
+
+		var journalMode string
+		var synchronous, busyTimeout int
+		assert.Nil(t, c.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode))
+		assert.Nil(t, c.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous))
+		assert.Nil(t, c.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout))
+		assert.Equal(t, "wal", journalMode)
+		assert.Equal(t, 1, synchronous) // NORMAL
+		assert.Equal(t, DefaultSqliteBusyTimeoutMs, busyTimeout)
+	}
+
+	// the reads do not wait for the open write transaction and see the last committed data
+	assert.Nil(t, conn.SaveLastBlock(conn.SqlDB, &model.BlockStatus{Chain: "avalanche", BlockNumber: 1}))
+	tx := conn.SqlDB.Begin()
+	assert.Nil(t, tx.Error)
+	defer tx.Rollback()
+	assert.Nil(t, conn.SaveLastBlock(tx, &model.BlockStatus{Chain: "avalanche", BlockNumber: 2}))
+
+	done := make(chan error, 4)
+	for i := 0; i < cap(done); i++ {
+		go func() {
+			height, err := conn.QueryLastBlock("avalanche")
+			if err == nil {
+				assert.Equal(t, int64(1), height.Int64())
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < cap(done); i++ {
+		assert.Nil(t, <-done)
+	}
+	assert.Nil(t, tx.Commit().Error)
+
+	height, err := conn.QueryLastBlock("avalanche")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), height.Int64())
+}
+
+func TestSqlitePragmasConfig(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:                DatabaseTypeSqlite3,
+		Dsn:                 filepath.Join(t.TempDir(), "indexer.db"),
+		SqliteJournalMode:   "DELETE",
+		SqliteSynchronous:   "FULL",
+		SqliteBusyTimeoutMs: 250,
+		QueryTimeoutMs:      100,
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	var journalMode string
+	var synchronous, busyTimeout int
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA synchronous").Scan(&synchronous).Error)
+	assert.Nil(t, conn.SqlDB.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	assert.Equal(t, "delete", journalMode)
+	assert.Equal(t, 2, synchronous) // FULL
+	assert.Equal(t, 250, busyTimeout)
+}
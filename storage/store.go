@@ -0,0 +1,210 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+// Reader the read methods of the indexer store, every method runs with the query timeout of its bucket, see
+// SetQueryTimeouts. The finders return nil without an error when nothing matches.
+type Reader interface {
+	QueryLastBlock(chain string) (*big.Int, error)
+	GetBlockStatus(chain string) (*model.BlockStatus, error)
+	GetIndexingLag(chain string) (time.Duration, error)
+	LastBlocks(chains []string) (map[string]*big.Int, error)
+	FindLastBlock(chain string) (*model.Block, error)
+
+	FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error)
+	FindInscriptionBySID(chain string, sid uint32) (*model.Inscriptions, error)
+	FindInscriptionStatsBySID(chain string, sid uint32) (*model.InscriptionsStats, error)
+	FindInscriptionStatsInfoByBaseId(insId uint32) (*model.InscriptionsStats, error)
+	FindInscriptionStatsByTick(chain, protocol, tick string) (*model.InscriptionsStats, error)
+	FindInscriptionsStatsByTick(chain string, protocol string, tick string) (*model.InscriptionsStats, error)
+	GetInscriptionsStatsBySIDs(chain string, sids []uint32) (map[uint32]*model.InscriptionsStats, error)
+	GetInscriptions(limit, offset int, filter InscriptionFilter, sort SortField, sortMode int) ([]*model.InscriptionOverView, int64, error)
+	GetInscriptionsWithDeployer(limit, offset int, filter InscriptionFilter, sort SortField, sortMode int) (
+		[]*model.InscriptionDeployerView, int64, error)
+	GetInscriptionsByCursor(lastId uint64, limit int, chain, protocol, tick, deployBy string, sort SortField) (
+		[]*model.InscriptionOverView, uint64, error)
+	GetInscriptionFacets(filter InscriptionFilter, facet InscriptionFacet) (map[string]int64, error)
+	GetInscriptionsByChain(chain string, hashes []string) ([]*model.Inscriptions, error)
+	GetInscriptionsByDeployBlockRange(chain string, fromBlock, toBlock uint64) ([]*model.Inscriptions, error)
+	GetInscriptionsByIdLimit(chain string, start uint64, limit int) ([]model.Inscriptions, error)
+	GetInscriptionStatsByIdLimit(chain string, start uint64, limit int) ([]model.InscriptionsStats, error)
+	IterateInscriptionStats(ctx context.Context, batchSize int, fn func(batch []model.InscriptionsStats) error) error
+	GetStatsNeedingHolderRecount(chain string, limit int) ([]*model.InscriptionsStats, error)
+	GetTickMarketStats(chain, protocol, tick string) (*model.TickMarketStats, error)
+	GetProtocolSummary(chain, protocol string) (*model.ProtocolSummary, error)
+	GetIndexedChains() ([]string, error)
+	GetIndexedProtocols(chain string) ([]string, error)
+	GetDeployerStats(chain, protocol string, limit, offset int) ([]*model.DeployerStats, error)
+
+	FindTransaction(chain string, hash string) (*model.Transaction, error)
+	FindTransactionsByHashes(chain string, hashes []string) (map[string]*model.Transaction, error)
+	GetTxsByHashes(chain string, hashes []string) ([]*model.Transaction, error)
+	GetTransactionByPosition(chain string, blockNumber uint64, txIndex uint) (*model.Transaction, error)
+	GetTransactionsByBlock(chain string, blockNumber uint64, limit, offset int) ([]*model.Transaction, int64, error)
+	GetTransfersBetween(chain, protocol, tick, from, to string, limit, offset int) ([]*model.Transaction, int64, error)
+	GetTransactionsByAddress(limit, offset int, address, chain, protocol, tick, key string, event model.TxEvent, filter TxRangeFilter,
+		sort TxSort) ([]*model.AddressTransaction, int64, error)
+	GetAddressTxs(limit, offset int, address, chain, protocol, tick string, event int8) ([]*model.AddressTransaction, int64, error)
+	FindAddressTxByHash(chain, hash string) (*model.AddressTxs, error)
+	GetActivityMetrics(chain string, since time.Time) (*model.ActivityMetrics, error)
+	GetEventCounts(chain, protocol, tick string) (map[model.TxEvent]int64, error)
+
+	FindUserBalanceByTick(chain, protocol, tick, addr string) (*model.Balances, error)
+	FindBalanceBySID(chain string, sid uint64) (*model.Balances, error)
+	GetBalancesByAddresses(chain, protocol, tick string, addresses []string) (map[string]*model.Balances, error)
+	GetInscriptionsByAddress(limit, offset int, address string) ([]*model.Balances, error)
+	GetAddressInscriptions(limit, offset int, address, chain, protocol, tick string, sort int) (
+		[]*model.BalanceInscription, int64, error)
+	GetBalancesByAddress(limit, offset int, address, chain, protocol, tick string) ([]*model.Balances, int64, error)
+	GetAddressBalancesAllChains(protocol, tick, address string) ([]*model.Balances, decimal.Decimal, error)
+	GetBalanceHistory(chain, protocol, tick, address string, limit, offset int, filter TxRangeFilter) ([]*model.BalanceTxn, int64, error)
+	GetBalanceAtBlock(chain, protocol, tick, address string, blockNumber uint64) (string, error)
+	GetBalancesUpdatedSince(chain string, since time.Time, lastId uint64, limit int) ([]*model.Balances, error)
+	GetBalancesByIdLimit(chain string, start uint64, limit int) ([]model.Balances, error)
+	IterateBalances(chain string, batchSize int, fn func(batch []model.Balances) error) error
+	GetAddressStats(chain, address string) (*model.AddressStats, error)
+	GetHoldersByTick(limit, offset int, chain, protocol, tick, minBalance string, sortMode int) ([]*model.Balances, int64, error)
+	GetTopHoldersByTick(limit, offset int, chain, protocol, tick string) ([]*model.TickHolder, int64, error)
+	GetInscriptionHolderCount(chain, protocol, tick string, ignore ...string) (int64, error)
+	GetRichList(chain, protocol string, limit, offset int) ([]*model.AddressHolding, error)
+	GetLabels(chain string, addresses []string) (map[string]*model.AddressLabel, error)
+	GetCirculatingSupply(chain, protocol, tick string, excludeAddresses []string) (string, error)
+
+	GetUTXOByOutpoint(chain, txid string, vout uint32) (*model.UTXO, error)
+	GetUTXOCount(address, chain, protocol, tick string) (int64, error)
+	GetUTXOsByIdLimit(start uint64, limit int) ([]model.UTXO, error)
+	GetUtxosByAddress(limit, offset int, address, chain, protocol, tick string) ([]*model.UTXO, error)
+	GetUTXOsByAddressTick(address, tick string) ([]*model.UTXO, error)
+	SumUTXOValue(address, chain, protocol, tick string) (string, int64, error)
+	SelectUTXOs(address, chain, protocol, tick, targetAmount string) ([]*model.UTXO, string, error)
+
+	GetTableCounts(chain string, approximate bool) (map[string]int64, error)
+}
+
+// Writer the write methods of the indexer store. The methods taking a dbTx write in the transaction of the callback
+// of WithRetryTx, callers never open the transaction on the gorm db themselves. RunInTx runs the same writes without
+// the gorm db.
+type Writer interface {
+	RunInTx(ctx context.Context, fn func(store TxStore) error) error
+	WithRetryTx(fn func(tx *gorm.DB) error, maxRetries int) error
+	SaveBlockResult(result *model.BlockResult) error
+	SaveLastBlock(tx *gorm.DB, status *model.BlockStatus) error
+	SaveLastBlockMonotonic(tx *gorm.DB, status *model.BlockStatus) (bool, error)
+
+	BatchAddInscription(dbTx *gorm.DB, ins []*model.Inscriptions) ([]*model.Inscriptions, error)
+	BatchUpdateInscription(dbTx *gorm.DB, chain string, items []*model.Inscriptions) error
+	SoftDeleteInscription(dbTx *gorm.DB, chain, protocol, tick string) error
+	RestoreInscription(dbTx *gorm.DB, chain, protocol, tick string) error
+	FindInscriptionByTickTx(dbTx *gorm.DB, chain, protocol, tick string) (*model.Inscriptions, error)
+	GetMintableSupply(dbTx *gorm.DB, chain, protocol, tick string) (string, error)
+
+	BatchAddInscriptionStats(dbTx *gorm.DB, ins []*model.InscriptionsStats) error
+	BatchUpdateInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) error
+	BatchUpsertInscriptionStats(dbTx *gorm.DB, chain string, items []*model.InscriptionsStats) error
+	UpdateInscriptionsStatsBySID(dbTx *gorm.DB, chain string, id uint32, updates map[string]interface{}) error
+	BatchUpdatesBySID(dbTx *gorm.DB, chain string, tblName string, fields map[string]string, values []map[string]interface{}) (error, int64)
+	RecalculateHolders(dbTx *gorm.DB, chain, protocol, tick string) (int64, error)
+
+	BatchAddTransaction(dbTx *gorm.DB, items []*model.Transaction) (int64, error)
+	BatchAddBalanceTx(dbTx *gorm.DB, items []*model.BalanceTxn) error
+	BatchAddAddressTx(dbTx *gorm.DB, items []*model.AddressTxs) error
+	BatchAddBalances(dbTx *gorm.DB, items []*model.Balances) error
+	BatchUpdateBalances(dbTx *gorm.DB, chain string, items []*model.Balances) error
+	BatchUpsertBalances(dbTx *gorm.DB, chain string, items []*model.Balances) error
+
+	BatchAddUTXO(dbTx *gorm.DB, items []*model.UTXO) ([]*model.UTXO, error)
+	LockUTXOForSpend(dbTx *gorm.DB, chain, txid string, vout uint32) (*model.UTXO, error)
+	MarkUTXOSpent(dbTx *gorm.DB, chain, rootHash, address string) (bool, error)
+	BatchMarkUTXOSpent(dbTx *gorm.DB, chain string, rootHashes []string) (int64, error)
+	GetUTXOsByAddressTickTx(dbTx *gorm.DB, address, tick string) ([]*model.UTXO, error)
+
+	LabelAddress(dbTx *gorm.DB, label *model.AddressLabel) error
+	DeleteDataAboveBlock(dbTx *gorm.DB, chain string, blockNumber uint64) error
+	PurgeChainData(dbTx *gorm.DB, chain string) (map[string]int64, error)
+}
+
+// TxStore the writes of the Writer bound to the transaction of RunInTx
+type TxStore interface {
+	SaveLastBlock(status *model.BlockStatus) error
+	SaveLastBlockMonotonic(status *model.BlockStatus) (bool, error)
+
+	BatchAddInscription(ins []*model.Inscriptions) ([]*model.Inscriptions, error)
+	BatchUpdateInscription(chain string, items []*model.Inscriptions) error
+	SoftDeleteInscription(chain, protocol, tick string) error
+	RestoreInscription(chain, protocol, tick string) error
+	FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error)
+	GetMintableSupply(chain, protocol, tick string) (string, error)
+
+	BatchAddInscriptionStats(ins []*model.InscriptionsStats) error
+	BatchUpdateInscriptionStats(chain string, items []*model.InscriptionsStats) error
+	BatchUpsertInscriptionStats(chain string, items []*model.InscriptionsStats) error
+	UpdateInscriptionsStatsBySID(chain string, id uint32, updates map[string]interface{}) error
+	BatchUpdatesBySID(chain string, tblName string, fields map[string]string, values []map[string]interface{}) (error, int64)
+	RecalculateHolders(chain, protocol, tick string) (int64, error)
+
+	BatchAddTransaction(items []*model.Transaction) (int64, error)
+	BatchAddBalanceTx(items []*model.BalanceTxn) error
+	BatchAddAddressTx(items []*model.AddressTxs) error
+	BatchAddBalances(items []*model.Balances) error
+	BatchUpdateBalances(chain string, items []*model.Balances) error
+	BatchUpsertBalances(chain string, items []*model.Balances) error
+
+	BatchAddUTXO(items []*model.UTXO) ([]*model.UTXO, error)
+	LockUTXOForSpend(chain, txid string, vout uint32) (*model.UTXO, error)
+	MarkUTXOSpent(chain, rootHash, address string) (bool, error)
+	BatchMarkUTXOSpent(chain string, rootHashes []string) (int64, error)
+	GetUTXOsByAddressTick(address, tick string) ([]*model.UTXO, error)
+
+	LabelAddress(label *model.AddressLabel) error
+	DeleteDataAboveBlock(chain string, blockNumber uint64) error
+	PurgeChainData(chain string) (map[string]int64, error)
+}
+
+// Store the indexer store, the callers depend on it instead of the DBClient so the tests can inject a fake
+type Store interface {
+	Reader
+	Writer
+
+	GetLock() (bool, error)
+	ReleaseLock() (int64, error)
+	Ping(ctx context.Context) error
+	HealthCheck(ctx context.Context) (*HealthStatus, error)
+	Close() error
+}
+
+var (
+	_ Store = (*DBClient)(nil)
+	_ Store = (*CachedDBClient)(nil)
+
+	_ TxStore = (*txStore)(nil)
+)
@@ -0,0 +1,70 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// queryCategory the timeout bucket of a read method
+type queryCategory int
+
+const (
+	// queryFast the point lookups and the index backed listings: the Find* methods, QueryLastBlock, GetBlockStatus,
+	// GetIndexingLag, LastBlocks, GetInscriptions, GetInscriptionsWithDeployer, GetInscriptionsByCursor,
+	// GetIndexedChains, GetIndexedProtocols, the *ByIdLimit pages, GetInscriptionsByAddress, GetTransactionsByAddress,
+	// GetTransactionsByBlock, GetTransactionByPosition, GetBalanceHistory, GetInscriptionsByDeployBlockRange,
+	// GetAddressTxs, GetTxsByHashes, GetAddressInscriptions, GetBalancesByAddress, GetAddressBalancesAllChains,
+	// GetBalancesByAddresses, GetBalancesUpdatedSince, GetHoldersByTick, GetUTXOByOutpoint, GetUTXOCount,
+	// GetUtxosByAddress, GetUTXOsByAddressTick, SelectUTXOs, GetInscriptionsStatsBySIDs, GetTransfersBetween,
+	// GetInscriptionsByChain and GetLabels
+	queryFast queryCategory = iota
+	// queryAnalytical the aggregations scanning many rows: GetTickMarketStats, GetProtocolSummary, GetAddressStats,
+	// GetInscriptionHolderCount, GetStatsNeedingHolderRecount, GetRichList, GetTopHoldersByTick, SumUTXOValue,
+	// GetBalanceAtBlock, GetActivityMetrics, GetEventCounts, GetDeployerStats, GetTableCounts, GetInscriptionFacets and
+	// GetCirculatingSupply
+	queryAnalytical
+)
+
+// SetQueryTimeouts overrides the deadlines of the read methods called without a context, taken from
+// FastQueryTimeoutMs and AnalyticalQueryTimeoutMs of the config by default. A zero timeout disables the deadline.
+// The Context variants use the context of the caller as it is, FindInscriptionByTickTx and GetUTXOsByAddressTickTx the
+// one of the transaction and IterateBalances runs without deadline.
+// It must be called before the client is shared, the copies returned by Primary keep the timeouts of the client.
+func (conn *DBClient) SetQueryTimeouts(fast, analytical time.Duration) {
+	conn.fastTimeout = fast
+	conn.analyticalTimeout = analytical
+}
+
+// queryContext the context of a read method of the category called without a context
+func (conn *DBClient) queryContext(category queryCategory) (context.Context, context.CancelFunc) {
+	timeout := conn.fastTimeout
+	if category == queryAnalytical {
+		timeout = conn.analyticalTimeout
+	}
+	if timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
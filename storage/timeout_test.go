@@ -0,0 +1,82 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/config"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestQueryTimeouts(t *testing.T) {
+	conn, err := NewDbClient(&config.DatabaseConfig{
+		Type:                     DatabaseTypeSqlite3,
+		Dsn:                      filepath.Join(t.TempDir(), "indexer.db"),
+		FastQueryTimeoutMs:       60000,
+		AnalyticalQueryTimeoutMs: 60000,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, conn.AutoMigrateAll())
+	assert.Equal(t, time.Minute, conn.fastTimeout)
+	assert.Equal(t, time.Minute, conn.analyticalTimeout)
+	addTransactions(t, conn, conn.SqlDB, []*model.Transaction{{Chain: "avalanche", TxHash: "0x1"}})
+
+	findTx := func() error {
+		_, err := conn.FindTransaction("avalanche", "0x1")
+		return err
+	}
+	richList := func() error {
+		_, err := conn.GetRichList("avalanche", "asc-20", 10, 0)
+		return err
+	}
+	page := func() error {
+		_, err := GetRowsByIdLimit[model.Transaction](conn, 0, 10, WhereChain("avalanche"))
+		return err
+	}
+	assert.Nil(t, findTx())
+	assert.Nil(t, richList())
+	assert.Nil(t, page())
+
+	// a deadline too short for any query only fails the methods of its bucket
+	conn.SetQueryTimeouts(time.Nanosecond, time.Minute)
+	assert.ErrorIs(t, findTx(), context.DeadlineExceeded)
+	assert.ErrorIs(t, page(), context.DeadlineExceeded)
+	assert.Nil(t, richList())
+
+	conn.SetQueryTimeouts(time.Minute, time.Nanosecond)
+	assert.Nil(t, findTx())
+	assert.ErrorIs(t, richList(), context.DeadlineExceeded)
+
+	// the context of the caller replaces the deadlines
+	_, err = conn.GetRichListContext(context.Background(), "avalanche", "asc-20", 10, 0)
+	assert.Nil(t, err)
+
+	conn.SetQueryTimeouts(0, 0)
+	assert.Nil(t, findTx())
+	assert.Nil(t, richList())
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+
+	"github.com/uxuycom/indexer/model"
+	"gorm.io/gorm"
+)
+
+// txStore the TxStore of a RunInTx transaction, the writes go through the client that opened it
+type txStore struct {
+	conn Writer
+	tx   *gorm.DB
+}
+
+// RunInTx runs fn inside a transaction of the primary, the writes of the store are rolled back when fn returns an
+// error or panics.
+func (conn *DBClient) RunInTx(ctx context.Context, fn func(store TxStore) error) error {
+	return conn.SqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&txStore{conn: conn, tx: tx})
+	})
+}
+
+func (s *txStore) SaveLastBlock(status *model.BlockStatus) error {
+	return s.conn.SaveLastBlock(s.tx, status)
+}
+
+func (s *txStore) SaveLastBlockMonotonic(status *model.BlockStatus) (bool, error) {
+	return s.conn.SaveLastBlockMonotonic(s.tx, status)
+}
+
+func (s *txStore) BatchAddInscription(ins []*model.Inscriptions) ([]*model.Inscriptions, error) {
+	return s.conn.BatchAddInscription(s.tx, ins)
+}
+
+func (s *txStore) BatchUpdateInscription(chain string, items []*model.Inscriptions) error {
+	return s.conn.BatchUpdateInscription(s.tx, chain, items)
+}
+
+func (s *txStore) SoftDeleteInscription(chain, protocol, tick string) error {
+	return s.conn.SoftDeleteInscription(s.tx, chain, protocol, tick)
+}
+
+func (s *txStore) RestoreInscription(chain, protocol, tick string) error {
+	return s.conn.RestoreInscription(s.tx, chain, protocol, tick)
+}
+
+func (s *txStore) FindInscriptionByTick(chain, protocol, tick string) (*model.Inscriptions, error) {
+	return s.conn.FindInscriptionByTickTx(s.tx, chain, protocol, tick)
+}
+
+func (s *txStore) GetMintableSupply(chain, protocol, tick string) (string, error) {
+	return s.conn.GetMintableSupply(s.tx, chain, protocol, tick)
+}
+
+func (s *txStore) BatchAddInscriptionStats(ins []*model.InscriptionsStats) error {
+	return s.conn.BatchAddInscriptionStats(s.tx, ins)
+}
+
+func (s *txStore) BatchUpdateInscriptionStats(chain string, items []*model.InscriptionsStats) error {
+	return s.conn.BatchUpdateInscriptionStats(s.tx, chain, items)
+}
+
+func (s *txStore) BatchUpsertInscriptionStats(chain string, items []*model.InscriptionsStats) error {
+	return s.conn.BatchUpsertInscriptionStats(s.tx, chain, items)
+}
+
+func (s *txStore) UpdateInscriptionsStatsBySID(chain string, id uint32, updates map[string]interface{}) error {
+	return s.conn.UpdateInscriptionsStatsBySID(s.tx, chain, id, updates)
+}
+
+func (s *txStore) BatchUpdatesBySID(chain string, tblName string, fields map[string]string, values []map[string]interface{}) (error, int64) {
+	return s.conn.BatchUpdatesBySID(s.tx, chain, tblName, fields, values)
+}
+
+func (s *txStore) RecalculateHolders(chain, protocol, tick string) (int64, error) {
+	return s.conn.RecalculateHolders(s.tx, chain, protocol, tick)
+}
+
+func (s *txStore) BatchAddTransaction(items []*model.Transaction) (int64, error) {
+	return s.conn.BatchAddTransaction(s.tx, items)
+}
+
+func (s *txStore) BatchAddBalanceTx(items []*model.BalanceTxn) error {
+	return s.conn.BatchAddBalanceTx(s.tx, items)
+}
+
+func (s *txStore) BatchAddAddressTx(items []*model.AddressTxs) error {
+	return s.conn.BatchAddAddressTx(s.tx, items)
+}
+
+func (s *txStore) BatchAddBalances(items []*model.Balances) error {
+	return s.conn.BatchAddBalances(s.tx, items)
+}
+
+func (s *txStore) BatchUpdateBalances(chain string, items []*model.Balances) error {
+	return s.conn.BatchUpdateBalances(s.tx, chain, items)
+}
+
+func (s *txStore) BatchUpsertBalances(chain string, items []*model.Balances) error {
+	return s.conn.BatchUpsertBalances(s.tx, chain, items)
+}
+
+func (s *txStore) BatchAddUTXO(items []*model.UTXO) ([]*model.UTXO, error) {
+	return s.conn.BatchAddUTXO(s.tx, items)
+}
+
+func (s *txStore) LockUTXOForSpend(chain, txid string, vout uint32) (*model.UTXO, error) {
+	return s.conn.LockUTXOForSpend(s.tx, chain, txid, vout)
+}
+
+func (s *txStore) MarkUTXOSpent(chain, rootHash, address string) (bool, error) {
+	return s.conn.MarkUTXOSpent(s.tx, chain, rootHash, address)
+}
+
+func (s *txStore) BatchMarkUTXOSpent(chain string, rootHashes []string) (int64, error) {
+	return s.conn.BatchMarkUTXOSpent(s.tx, chain, rootHashes)
+}
+
+func (s *txStore) GetUTXOsByAddressTick(address, tick string) ([]*model.UTXO, error) {
+	return s.conn.GetUTXOsByAddressTickTx(s.tx, address, tick)
+}
+
+func (s *txStore) LabelAddress(label *model.AddressLabel) error {
+	return s.conn.LabelAddress(s.tx, label)
+}
+
+func (s *txStore) DeleteDataAboveBlock(chain string, blockNumber uint64) error {
+	return s.conn.DeleteDataAboveBlock(s.tx, chain, blockNumber)
+}
+
+func (s *txStore) PurgeChainData(chain string) (map[string]int64, error) {
+	return s.conn.PurgeChainData(s.tx, chain)
+}
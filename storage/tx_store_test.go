@@ -0,0 +1,84 @@
+// Copyright (c) 2023-2024 The UXUY Developer Team
+// License:
+// MIT License
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//SOFTWARE
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/uxuycom/indexer/model"
+)
+
+func TestRunInTx(t *testing.T) {
+	conn := newTestClient(t)
+	chain, protocol, tick := "avalanche", "asc-20", "tick"
+	amount := decimal.NewFromInt(100)
+
+	write := func(store TxStore, hash string) error {
+		ins := []*model.Inscriptions{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, TotalSupply: amount, DeployHash: hash}}
+		if _, err := store.BatchAddInscription(ins); err != nil {
+			return err
+		}
+		if _, err := store.BatchAddTransaction([]*model.Transaction{{Chain: chain, Protocol: protocol, Tick: tick, TxHash: hash}}); err != nil {
+			return err
+		}
+		balances := []*model.Balances{{SID: 1, Chain: chain, Protocol: protocol, Tick: tick, Address: "0xa", Balance: amount, Available: amount}}
+		if err := store.BatchAddBalances(balances); err != nil {
+			return err
+		}
+		return store.SaveLastBlock(&model.BlockStatus{Chain: chain, BlockNumber: 1})
+	}
+	counts := func() map[string]int64 {
+		cnt, err := conn.GetTableCounts(chain, false)
+		assert.Nil(t, err)
+		var blocks int64
+		assert.Nil(t, conn.SqlDB.Model(&model.BlockStatus{}).Where("chain = ?", chain).Count(&blocks).Error)
+		return map[string]int64{"inscriptions": cnt["inscriptions"], "txs": cnt["txs"], "balances": cnt["balances"], "block": blocks}
+	}
+
+	// an error of the callback rolls back all the writes
+	failed := errors.New("failed")
+	err := conn.RunInTx(context.Background(), func(store TxStore) error {
+		if err := write(store, "0xd1"); err != nil {
+			return err
+		}
+		found, err := store.FindInscriptionByTick(chain, protocol, tick)
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+		return failed
+	})
+	assert.Equal(t, failed, err)
+	assert.Equal(t, map[string]int64{"inscriptions": 0, "txs": 0, "balances": 0, "block": 0}, counts())
+
+	assert.Nil(t, conn.RunInTx(context.Background(), func(store TxStore) error {
+		return write(store, "0xd2")
+	}))
+	assert.Equal(t, map[string]int64{"inscriptions": 1, "txs": 1, "balances": 1, "block": 1}, counts())
+
+	ins, err := conn.FindInscriptionByTick(chain, protocol, tick)
+	assert.Nil(t, err)
+	assert.Equal(t, "0xd2", ins.DeployHash)
+}
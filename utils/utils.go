@@ -68,3 +68,14 @@ func Keccak256(str string) string {
 	bytes := h.Hash([]byte(str))
 	return hex.EncodeToString(bytes)
 }
+
+// LikeEscapeChar the escape character of the patterns of EscapeLike, set it in the ESCAPE clause of the LIKE
+// since sqlite has no default escape character
+const LikeEscapeChar = `\`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike escapes the LIKE metacharacters % _ and \ of the user input so that they are matched literally
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}